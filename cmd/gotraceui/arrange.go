@@ -0,0 +1,694 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"golang.org/x/exp/slices"
+)
+
+// Users can pin timelines to the top of the canvas, hide individual timelines or groups of them, and reorder timelines
+// by dragging their labels. The arrangement is remembered per trace.
+
+const (
+	pinnedSeparatorHeightDp  unit.Dp = 2
+	reorderIndicatorHeightDp unit.Dp = 2
+
+	// Processors that were busy for less than this fraction of the trace are considered idle.
+	idleProcessorThreshold = 0.01
+)
+
+// timelineKey returns a string that identifies the timeline across runs of gotraceui.
+func timelineKey(tl *Timeline) string {
+	switch item := tl.item.(type) {
+	case *GC:
+		return "gc"
+	case *STW:
+		return "stw"
+	case *ptrace.Machine:
+		return fmt.Sprintf("m%d", item.ID)
+	case *ptrace.Processor:
+		return fmt.Sprintf("p%d", item.ID)
+	case *ptrace.Goroutine:
+		return fmt.Sprintf("g%d", item.ID)
+	case *ptrace.Task:
+		return fmt.Sprintf("task%d", item.ID)
+	default:
+		panic(fmt.Sprintf("unhandled type %T", item))
+	}
+}
+
+func isRuntimeGoroutine(g *ptrace.Goroutine) bool {
+	if g.Function == nil {
+		return false
+	}
+	pkg := functionPackage(g.Function.Fn)
+	return pkg == "runtime" || strings.HasPrefix(pkg, "runtime/")
+}
+
+func (cv *Canvas) isIdleProcessor(p *ptrace.Processor) bool {
+	if cv.arrangement.idleProcessors == nil {
+		cv.arrangement.idleProcessors = map[*ptrace.Processor]struct{}{}
+		var d float64
+		if len(cv.trace.Events) > 0 {
+			d = float64(cv.trace.Events[len(cv.trace.Events)-1].Ts)
+		}
+		for _, p := range cv.trace.Processors {
+			var busy float64
+			for i := 0; i < p.Spans.Len(); i++ {
+				busy += float64(p.Spans.AtPtr(i).Duration())
+			}
+			if busy < d*idleProcessorThreshold {
+				cv.arrangement.idleProcessors[p] = struct{}{}
+			}
+		}
+	}
+	_, ok := cv.arrangement.idleProcessors[p]
+	return ok
+}
+
+// timelineHidden reports whether the timeline is hidden, either individually or as part of a group. Pinned timelines
+// are never hidden.
+func (cv *Canvas) timelineHidden(tl *Timeline) bool {
+	if tl.pinned {
+		return false
+	}
+	if tl.hidden {
+		return true
+	}
+	if tl.shown {
+		return false
+	}
+	switch item := tl.item.(type) {
+	case *ptrace.Processor:
+		return cv.arrangement.hideIdleProcessors && cv.isIdleProcessor(item)
+	case *ptrace.Goroutine:
+		return cv.arrangement.hideRuntimeGoroutines && isRuntimeGoroutine(item)
+	default:
+		return false
+	}
+}
+
+// addTimelines appends timelines to the canvas, in their default order.
+func (cv *Canvas) addTimelines(tls []*Timeline) {
+	cv.allTimelines = append(cv.allTimelines, tls...)
+	cv.arrangeTimelines()
+}
+
+// arrangeTimelines computes the lists of pinned and scrolled timelines from allTimelines.
+func (cv *Canvas) arrangeTimelines() {
+	cv.pinnedTimelines = cv.pinnedTimelines[:0]
+	cv.timelines = cv.timelines[:0]
+	for _, tl := range cv.allTimelines {
+		if tl.pinned {
+			cv.pinnedTimelines = append(cv.pinnedTimelines, tl)
+		} else if !cv.timelineHidden(tl) {
+			cv.timelines = append(cv.timelines, tl)
+		}
+	}
+
+	// Force recomputation of the timelines' positions and of the canvas's height.
+	cv.timelineEnds = cv.timelineEnds[:0]
+	cv.cachedHeight = 0
+}
+
+func (cv *Canvas) arrangementChanged() {
+	cv.arrangeTimelines()
+	cv.arrangement.changed = true
+}
+
+func (cv *Canvas) PinTimeline(tl *Timeline, pinned bool) {
+	tl.pinned = pinned
+	cv.arrangementChanged()
+}
+
+func (cv *Canvas) HideTimeline(tl *Timeline) {
+	tl.pinned = false
+	tl.hidden = true
+	tl.shown = false
+	cv.arrangementChanged()
+}
+
+func (cv *Canvas) ShowTimeline(tl *Timeline) {
+	tl.hidden = false
+	tl.shown = true
+	cv.arrangementChanged()
+}
+
+// ShowAllTimelines shows all hidden timelines, including hidden groups.
+func (cv *Canvas) ShowAllTimelines() {
+	for _, tl := range cv.allTimelines {
+		tl.hidden = false
+		tl.shown = false
+	}
+	cv.arrangement.hideIdleProcessors = false
+	cv.arrangement.hideRuntimeGoroutines = false
+	cv.arrangementChanged()
+}
+
+func (cv *Canvas) ToggleIdleProcessors() {
+	cv.arrangement.hideIdleProcessors = !cv.arrangement.hideIdleProcessors
+	cv.arrangementChanged()
+}
+
+func (cv *Canvas) ToggleRuntimeGoroutines() {
+	cv.arrangement.hideRuntimeGoroutines = !cv.arrangement.hideRuntimeGoroutines
+	cv.arrangementChanged()
+}
+
+// ResetTimelineArrangement unpins and shows all timelines and restores their default order.
+func (cv *Canvas) ResetTimelineArrangement() {
+	for _, tl := range cv.allTimelines {
+		tl.pinned = false
+	}
+	if cv.arrangement.reordered {
+		cv.arrangement.reordered = false
+		cv.arrangement.goroutineSort = goroutineSortNone
+		cv.applyDefaultOrder()
+	}
+	cv.ShowAllTimelines()
+}
+
+// applyDefaultOrder sorts the timelines in their default order: GC, STW, machines, processors, goroutines and tasks.
+func (cv *Canvas) applyDefaultOrder() {
+	rank := func(tl *Timeline) (int, uint64) {
+		switch item := tl.item.(type) {
+		case *GC:
+			return 0, 0
+		case *STW:
+			return 1, 0
+		case *ptrace.Machine:
+			return 2, uint64(item.SeqID)
+		case *ptrace.Processor:
+			return 3, uint64(item.SeqID)
+		case *ptrace.Goroutine:
+			return 4, uint64(item.SeqID)
+		case *ptrace.Task:
+			return 5, uint64(item.SeqID)
+		default:
+			panic(fmt.Sprintf("unhandled type %T", item))
+		}
+	}
+	slices.SortStableFunc(cv.allTimelines, func(a, b *Timeline) bool {
+		ka, sa := rank(a)
+		kb, sb := rank(b)
+		if ka != kb {
+			return ka < kb
+		}
+		return sa < sb
+	})
+}
+
+// moveTimeline moves tl before or after target in the order of all timelines.
+func (cv *Canvas) moveTimeline(tl, target *Timeline, after bool) {
+	if tl == target {
+		return
+	}
+	i := slices.Index(cv.allTimelines, tl)
+	cv.allTimelines = slices.Delete(cv.allTimelines, i, i+1)
+	j := slices.Index(cv.allTimelines, target)
+	if after {
+		j++
+	}
+	cv.allTimelines = slices.Insert(cv.allTimelines, j, tl)
+	cv.arrangement.reordered = true
+	if _, ok := tl.item.(*ptrace.Goroutine); ok {
+		// The goroutines are no longer sorted.
+		cv.arrangement.goroutineSort = goroutineSortNone
+	}
+	cv.arrangementChanged()
+}
+
+// MoveTimelineToTop moves tl to the top of the pinned or the scrolled timelines, whichever it is part of.
+func (cv *Canvas) MoveTimelineToTop(tl *Timeline) {
+	list := cv.timelines
+	if tl.pinned {
+		list = cv.pinnedTimelines
+	}
+	if len(list) > 0 {
+		cv.moveTimeline(tl, list[0], false)
+	}
+}
+
+// reorderTarget returns the index in a list of timelines that the timeline at index from moves to when its label,
+// grabbed at start, gets dragged by dy pixels. ends are the timelines' end offsets.
+func reorderTarget(ends []int, from int, start, dy float32) int {
+	top := 0
+	if from > 0 {
+		top = ends[from-1]
+	}
+	y := float32(top) + start + dy
+	if y < 0 {
+		return 0
+	}
+	to := sort.Search(len(ends), func(i int) bool {
+		return float32(ends[i]) > y
+	})
+	if to == len(ends) {
+		to = len(ends) - 1
+	}
+	return to
+}
+
+// reorderList returns the list that tl is part of, and the end offsets of that list's timelines.
+func (cv *Canvas) reorderList(tl *Timeline) ([]*Timeline, []int) {
+	if tl.pinned {
+		return cv.pinnedTimelines, cv.pinnedEnds
+	}
+	return cv.timelines, cv.timelineEnds
+}
+
+// reorderTimeline moves tl after its label has been dragged by dy pixels.
+func (cv *Canvas) reorderTimeline(tl *Timeline, dy float32) {
+	list, ends := cv.reorderList(tl)
+	from := slices.Index(list, tl)
+	if from == -1 || len(ends) != len(list) {
+		return
+	}
+	to := reorderTarget(ends, from, tl.reorder.start.Y, dy)
+	if to != from {
+		cv.moveTimeline(tl, list[to], to > from)
+	}
+}
+
+// drawReorderIndicator draws a line where the timeline whose label is being dragged would be moved to. visible are the
+// timelines of list that have been laid out, and offset is the Y offset of list's first timeline.
+func (cv *Canvas) drawReorderIndicator(gtx layout.Context, list []*Timeline, ends []int, visible []*Timeline, offset int) {
+	for _, tl := range visible {
+		if tl.TimelineWidget == nil || !tl.reorder.active {
+			continue
+		}
+		from := slices.Index(list, tl)
+		to := reorderTarget(ends, from, tl.reorder.start.Y, tl.reorder.dy)
+		var y int
+		switch {
+		case to < from:
+			if to > 0 {
+				y = ends[to-1]
+			}
+		case to > from:
+			y = ends[to]
+		default:
+			return
+		}
+		y += offset
+		h := gtx.Dp(reorderIndicatorHeightDp)
+		paint.FillShape(gtx.Ops, colors[colorTimelineReorder], clip.Rect{
+			Min: image.Pt(0, y-h/2),
+			Max: image.Pt(gtx.Constraints.Max.X, y-h/2+h),
+		}.Op())
+		return
+	}
+}
+
+func (cv *Canvas) timelineContextMenu(win *theme.Window, tl *Timeline) []*theme.MenuItem {
+	items := []*theme.MenuItem{
+		{
+			Label: ToggleLabel("Unpin timeline", "Pin timeline to top", &tl.pinned),
+			Do:    func(gtx layout.Context) { cv.PinTimeline(tl, !tl.pinned) },
+		},
+		{
+			Label: PlainLabel("Hide timeline"),
+			Do:    func(gtx layout.Context) { cv.HideTimeline(tl) },
+		},
+		{
+			Label: PlainLabel("Move timeline to top"),
+			Do:    func(gtx layout.Context) { cv.MoveTimelineToTop(tl) },
+		},
+	}
+	switch tl.item.(type) {
+	case *ptrace.Processor:
+		items = append(items, &theme.MenuItem{
+			Label: ToggleLabel("Show idle processors", "Hide idle processors", &cv.arrangement.hideIdleProcessors),
+			Do:    func(gtx layout.Context) { cv.ToggleIdleProcessors() },
+		})
+	case *ptrace.Goroutine:
+		items = append(items, &theme.MenuItem{
+			Label: ToggleLabel("Show runtime goroutines", "Hide runtime goroutines", &cv.arrangement.hideRuntimeGoroutines),
+			Do:    func(gtx layout.Context) { cv.ToggleRuntimeGoroutines() },
+		})
+	}
+	return items
+}
+
+// A timelineArrangement records how the user arranged the timelines of a trace. Timelines are identified by the keys
+// returned by timelineKey.
+type timelineArrangement struct {
+	// The order of all timelines. It is only recorded if the user changed the default order.
+	Order                 []string `json:"order,omitempty"`
+	Pinned                []string `json:"pinned,omitempty"`
+	Hidden                []string `json:"hidden,omitempty"`
+	Shown                 []string `json:"shown,omitempty"`
+	HideIdleProcessors    bool     `json:"hideIdleProcessors,omitempty"`
+	HideRuntimeGoroutines bool     `json:"hideRuntimeGoroutines,omitempty"`
+	// The order that goroutines were last sorted in, if they haven't been reordered manually since. The sorted order
+	// itself is recorded in Order.
+	GoroutineSort string `json:"goroutineSort,omitempty"`
+}
+
+func (cv *Canvas) timelineArrangement() timelineArrangement {
+	a := timelineArrangement{
+		HideIdleProcessors:    cv.arrangement.hideIdleProcessors,
+		HideRuntimeGoroutines: cv.arrangement.hideRuntimeGoroutines,
+		GoroutineSort:         goroutineSortNames[cv.arrangement.goroutineSort],
+	}
+	for _, tl := range cv.allTimelines {
+		key := timelineKey(tl)
+		if cv.arrangement.reordered {
+			a.Order = append(a.Order, key)
+		}
+		if tl.pinned {
+			a.Pinned = append(a.Pinned, key)
+		}
+		if tl.hidden {
+			a.Hidden = append(a.Hidden, key)
+		}
+		if tl.shown {
+			a.Shown = append(a.Shown, key)
+		}
+	}
+	return a
+}
+
+// applyTimelineArrangement restores an arrangement. Keys of timelines that don't exist are ignored.
+func (cv *Canvas) applyTimelineArrangement(a timelineArrangement) {
+	byKey := make(map[string]*Timeline, len(cv.allTimelines))
+	for _, tl := range cv.allTimelines {
+		byKey[timelineKey(tl)] = tl
+		tl.pinned = false
+		tl.hidden = false
+		tl.shown = false
+	}
+
+	cv.applyDefaultOrder()
+	cv.arrangement.reordered = len(a.Order) != 0
+	if cv.arrangement.reordered {
+		ordered := make([]*Timeline, 0, len(cv.allTimelines))
+		seen := make(map[*Timeline]struct{}, len(cv.allTimelines))
+		for _, key := range a.Order {
+			if tl, ok := byKey[key]; ok {
+				if _, ok := seen[tl]; !ok {
+					seen[tl] = struct{}{}
+					ordered = append(ordered, tl)
+				}
+			}
+		}
+		// Timelines that the arrangement doesn't mention keep their relative default order, after all others.
+		for _, tl := range cv.allTimelines {
+			if _, ok := seen[tl]; !ok {
+				ordered = append(ordered, tl)
+			}
+		}
+		cv.allTimelines = ordered
+	}
+
+	for _, key := range a.Pinned {
+		if tl, ok := byKey[key]; ok {
+			tl.pinned = true
+		}
+	}
+	for _, key := range a.Hidden {
+		if tl, ok := byKey[key]; ok {
+			tl.hidden = true
+		}
+	}
+	for _, key := range a.Shown {
+		if tl, ok := byKey[key]; ok {
+			tl.shown = true
+		}
+	}
+	cv.arrangement.hideIdleProcessors = a.HideIdleProcessors
+	cv.arrangement.hideRuntimeGoroutines = a.HideRuntimeGoroutines
+	cv.arrangement.goroutineSort = goroutineSortNone
+	if cv.arrangement.reordered {
+		if i := slices.Index(goroutineSortNames[:], a.GoroutineSort); i != -1 {
+			cv.arrangement.goroutineSort = goroutineSortOrder(i)
+		}
+	}
+	cv.arrangeTimelines()
+}
+
+func timelineArrangementPath(hash string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gotraceui", "timelines", hash+".json")
+}
+
+// arrangementSaveDelay is how long we wait after the last change to the arrangement of the timelines before saving it.
+// Dragging a timeline changes the arrangement many times in quick succession, and after sorting goroutines, the
+// arrangement contains the keys of all timelines, which can amount to megabytes.
+const arrangementSaveDelay = time.Second
+
+// arrangementSaver writes timeline arrangements in the background, making sure that older arrangements don't overwrite
+// newer ones.
+type arrangementSaver struct {
+	// The sequence number of the last arrangement that was requested to be written. Only accessed by the UI goroutine.
+	seq uint64
+
+	mu sync.Mutex
+	// The sequence number of the last arrangement that was written. Guarded by mu.
+	written uint64
+}
+
+// saveTimelineArrangement remembers the arrangement of the timelines for the next time the trace gets opened. The
+// arrangement is captured immediately and written in the background.
+func (mwin *MainWindow) saveTimelineArrangement() {
+	mwin.arrangementSaveAt = time.Time{}
+	path := timelineArrangementPath(mwin.traceHash)
+	a := mwin.canvas.timelineArrangement()
+	saver := &mwin.arrangementSaves
+	saver.seq++
+	seq := saver.seq
+
+	go func() {
+		saver.mu.Lock()
+		defer saver.mu.Unlock()
+		if seq < saver.written {
+			return
+		}
+		saver.written = seq
+
+		err := func() error {
+			if path == "" {
+				return errors.New("couldn't determine the config directory")
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+				return err
+			}
+			b, err := json.Marshal(a)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(path, b, 0o666)
+		}()
+		if err != nil {
+			mwin.commands <- func(mwin *MainWindow, gtx layout.Context) {
+				mwin.twin.ShowNotification(gtx, fmt.Sprintf("Couldn't remember timeline arrangement: %s", err))
+			}
+		}
+	}()
+}
+
+// restoreTimelineArrangement restores the remembered arrangement of the timelines, if there is one.
+func (mwin *MainWindow) restoreTimelineArrangement(gtx layout.Context) {
+	path := timelineArrangementPath(mwin.traceHash)
+	if path == "" {
+		return
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			mwin.twin.ShowNotification(gtx, fmt.Sprintf("Couldn't restore timeline arrangement: %s", err))
+		}
+		return
+	}
+	var a timelineArrangement
+	if err := json.Unmarshal(b, &a); err != nil {
+		mwin.twin.ShowNotification(gtx, fmt.Sprintf("Couldn't restore timeline arrangement: %s", err))
+		return
+	}
+	mwin.canvas.applyTimelineArrangement(a)
+}
+
+type goroutineSortOrder uint8
+
+const (
+	// Goroutines are in their default order, or the order the user arranged them in.
+	goroutineSortNone goroutineSortOrder = iota
+	goroutineSortID
+	goroutineSortCreation
+	goroutineSortFunction
+	goroutineSortRunning
+	goroutineSortBlocked
+	goroutineSortReady
+	goroutineSortSpans
+)
+
+// Names of sort orders, as stored in timeline arrangements
+var goroutineSortNames = [...]string{
+	goroutineSortNone:     "",
+	goroutineSortID:       "id",
+	goroutineSortCreation: "creation",
+	goroutineSortFunction: "function",
+	goroutineSortRunning:  "running",
+	goroutineSortBlocked:  "blocked",
+	goroutineSortReady:    "ready",
+	goroutineSortSpans:    "spans",
+}
+
+// computeGoroutineStateTotals computes the summaries of all goroutines, indexed by their sequential IDs.
+func computeGoroutineStateTotals(tr *Trace, cancelled <-chan struct{}) []goroutineSummary {
+	out := make([]goroutineSummary, len(tr.Goroutines))
+	for i, g := range tr.Goroutines {
+		if i%1000 == 0 {
+			select {
+			case <-cancelled:
+				return nil
+			default:
+			}
+		}
+		out[g.SeqID] = summarizeGoroutine(tr, g)
+	}
+	return out
+}
+
+func goroutineCreation(g *ptrace.Goroutine) trace.Timestamp {
+	if g.Spans.Len() == 0 {
+		return 0
+	}
+	return g.Spans.AtPtr(0).Start
+}
+
+func goroutineFunction(g *ptrace.Goroutine) string {
+	if g.Function == nil {
+		return ""
+	}
+	return g.Function.Fn
+}
+
+// SortGoroutines sorts the goroutine timelines. Goroutine timelines keep the positions they occupy among the other
+// timelines. Metrics sort in descending order, so that the busiest goroutines end up at the top. Goroutines with
+// equal keys are sorted by their sequential IDs.
+func (cv *Canvas) SortGoroutines(order goroutineSortOrder, totals []goroutineSummary) {
+	if order == goroutineSortNone {
+		return
+	}
+
+	var idxs []int
+	var tls []*Timeline
+	for i, tl := range cv.allTimelines {
+		if _, ok := tl.item.(*ptrace.Goroutine); ok {
+			idxs = append(idxs, i)
+			tls = append(tls, tl)
+		}
+	}
+
+	// less reports whether a sorts before b, using the goroutines' sequential IDs to break ties.
+	var less func(a, b *ptrace.Goroutine) bool
+	byDuration := func(da, db time.Duration, a, b *ptrace.Goroutine) bool {
+		if da != db {
+			return da > db
+		}
+		return a.SeqID < b.SeqID
+	}
+	switch order {
+	case goroutineSortID:
+		less = func(a, b *ptrace.Goroutine) bool { return a.ID < b.ID }
+	case goroutineSortCreation:
+		less = func(a, b *ptrace.Goroutine) bool {
+			ta, tb := goroutineCreation(a), goroutineCreation(b)
+			if ta != tb {
+				return ta < tb
+			}
+			return a.SeqID < b.SeqID
+		}
+	case goroutineSortFunction:
+		less = func(a, b *ptrace.Goroutine) bool {
+			fa, fb := goroutineFunction(a), goroutineFunction(b)
+			if fa != fb {
+				return fa < fb
+			}
+			return a.SeqID < b.SeqID
+		}
+	case goroutineSortRunning:
+		less = func(a, b *ptrace.Goroutine) bool {
+			return byDuration(totals[a.SeqID].running, totals[b.SeqID].running, a, b)
+		}
+	case goroutineSortBlocked:
+		less = func(a, b *ptrace.Goroutine) bool {
+			return byDuration(totals[a.SeqID].blocked, totals[b.SeqID].blocked, a, b)
+		}
+	case goroutineSortReady:
+		less = func(a, b *ptrace.Goroutine) bool {
+			return byDuration(totals[a.SeqID].ready, totals[b.SeqID].ready, a, b)
+		}
+	case goroutineSortSpans:
+		less = func(a, b *ptrace.Goroutine) bool {
+			na, nb := a.Spans.Len(), b.Spans.Len()
+			if na != nb {
+				return na > nb
+			}
+			return a.SeqID < b.SeqID
+		}
+	default:
+		panic(fmt.Sprintf("unhandled sort order %d", order))
+	}
+	slices.SortFunc(tls, func(a, b *Timeline) bool {
+		return less(a.item.(*ptrace.Goroutine), b.item.(*ptrace.Goroutine))
+	})
+
+	for i, idx := range idxs {
+		cv.allTimelines[idx] = tls[i]
+	}
+	cv.arrangement.goroutineSort = order
+	cv.arrangement.reordered = true
+	cv.arrangementChanged()
+}
+
+// goroutineStateTotals returns the state totals of all goroutines, which are computed once per trace.
+func (mwin *MainWindow) goroutineStateTotals() *theme.Future[[]goroutineSummary] {
+	if mwin.cachedGoroutineStateTotals == nil {
+		tr := mwin.trace
+		mwin.cachedGoroutineStateTotals = theme.NewFuture(mwin.twin, func(cancelled <-chan struct{}) []goroutineSummary {
+			return computeGoroutineStateTotals(tr, cancelled)
+		})
+	}
+	return mwin.cachedGoroutineStateTotals
+}
+
+// applyPendingGoroutineSort sorts the goroutine timelines once the data needed for sorting is available.
+func (mwin *MainWindow) applyPendingGoroutineSort() {
+	switch mwin.pendingGoroutineSort {
+	case goroutineSortNone:
+		return
+	case goroutineSortRunning, goroutineSortBlocked, goroutineSortReady:
+	default:
+		// Other orders don't depend on the goroutines' statistics.
+		mwin.canvas.SortGoroutines(mwin.pendingGoroutineSort, nil)
+		mwin.pendingGoroutineSort = goroutineSortNone
+		return
+	}
+	if totals, ok := mwin.goroutineStateTotals().Result(); ok {
+		mwin.canvas.SortGoroutines(mwin.pendingGoroutineSort, totals)
+		mwin.pendingGoroutineSort = goroutineSortNone
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"sort"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/gesture"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"golang.org/x/exp/slices"
+)
+
+// Bookmark is a named point in time that the user wants to return to.
+type Bookmark struct {
+	Name string          `json:"name"`
+	When trace.Timestamp `json:"when"`
+}
+
+// bookmarkRemoval is the object of links that remove a bookmark.
+type bookmarkRemoval struct {
+	Bookmark *Bookmark
+}
+
+// AddBookmark adds a bookmark, keeping the bookmarks sorted by time.
+func (cv *Canvas) AddBookmark(name string, when trace.Timestamp) *Bookmark {
+	b := &Bookmark{Name: name, When: when}
+	i := sort.Search(len(cv.bookmarks), func(i int) bool {
+		return cv.bookmarks[i].When > when
+	})
+	cv.bookmarks = slices.Insert(cv.bookmarks, i, b)
+	cv.bookmarksAdded++
+	return b
+}
+
+func (cv *Canvas) RemoveBookmark(b *Bookmark) {
+	if i := slices.Index(cv.bookmarks, b); i != -1 {
+		cv.bookmarks = slices.Delete(cv.bookmarks, i, i+1)
+	}
+}
+
+// cycleBookmarks navigates to the next (dir > 0) or previous (dir < 0) bookmark, relative to the axis's origin,
+// wrapping around at either end.
+func (cv *Canvas) cycleBookmarks(gtx layout.Context, dir int) (*Bookmark, bool) {
+	if len(cv.bookmarks) == 0 {
+		return nil, false
+	}
+
+	start := cv.start
+	if cv.animateTo.animating {
+		// Allow cycling through several bookmarks in quick succession.
+		start = cv.animateTo.targetStart
+	}
+	ref := start + cv.originOffset()
+	// Bookmarks within one pixel of the origin count as the current bookmark.
+	slack := trace.Timestamp(cv.nsPerPx)
+
+	var b *Bookmark
+	if dir > 0 {
+		i := sort.Search(len(cv.bookmarks), func(i int) bool {
+			return cv.bookmarks[i].When > ref+slack
+		})
+		if i == len(cv.bookmarks) {
+			i = 0
+		}
+		b = cv.bookmarks[i]
+	} else {
+		i := sort.Search(len(cv.bookmarks), func(i int) bool {
+			return cv.bookmarks[i].When >= ref-slack
+		}) - 1
+		if i < 0 {
+			i = len(cv.bookmarks) - 1
+		}
+		b = cv.bookmarks[i]
+	}
+
+	cv.navigateToTimestamp(gtx, b.When)
+	return b, true
+}
+
+// drawBookmarks draws a vertical line for each visible bookmark.
+func (cv *Canvas) drawBookmarks(gtx layout.Context) {
+	for _, b := range cv.bookmarks {
+		if b.When < cv.start || b.When > cv.End() {
+			continue
+		}
+		x := round32(cv.tsToPx(b.When))
+		rect := clip.Rect{
+			Min: image.Pt(int(x), 0),
+			Max: image.Pt(int(x)+1, gtx.Constraints.Max.Y),
+		}
+		paint.FillShape(gtx.Ops, colors[colorBookmark], rect.Op())
+	}
+}
+
+// drawBookmarkLabels draws the names of visible bookmarks on the axis.
+func (cv *Canvas) drawBookmarkLabels(win *theme.Window, gtx layout.Context) {
+	for _, b := range cv.bookmarks {
+		if b.When < cv.start || b.When > cv.End() {
+			continue
+		}
+		x := int(round32(cv.tsToPx(b.When)))
+
+		rec := Record(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			return layout.UniformInset(1).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return widget.TextLine{Color: win.Theme.Palette.Background}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, b.Name)
+			})
+		})
+		// Place the label to the right of the line, unless that would cut it off.
+		if x+rec.Dimensions.Size.X > gtx.Constraints.Max.X {
+			x -= rec.Dimensions.Size.X
+		}
+		stack := op.Offset(image.Pt(x, 0)).Push(gtx.Ops)
+		paint.FillShape(gtx.Ops, colors[colorBookmark], clip.Rect{Max: rec.Dimensions.Size}.Op())
+		rec.Layout(win, gtx)
+		stack.Pop()
+	}
+}
+
+// newBookmarkMenuItems returns context menu items for bookmarking the start and end of spans.
+func newBookmarkMenuItems(win *theme.Window, cv *Canvas, start, end trace.Timestamp) []*theme.MenuItem {
+	return []*theme.MenuItem{
+		{
+			Label: PlainLabel("Bookmark start"),
+			Do:    func(gtx layout.Context) { displayAddBookmarkDialog(win, cv, start) },
+		},
+		{
+			Label: PlainLabel("Bookmark end"),
+			Do:    func(gtx layout.Context) { displayAddBookmarkDialog(win, cv, end) },
+		},
+	}
+}
+
+func displayAddBookmarkDialog(win *theme.Window, cv *Canvas, when trace.Timestamp) {
+	bd := &bookmarkDialog{cv: cv, when: when, focus: true}
+	bd.editor.SingleLine = true
+	bd.editor.Submit = true
+	name := local.Sprintf("Bookmark %d", cv.bookmarksAdded+1)
+	bd.editor.SetText(name)
+	// Select the default name so that typing replaces it.
+	bd.editor.SetCaret(len(name), 0)
+
+	win.SetModal(func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+		return theme.Dialog(win.Theme, "Add bookmark").Layout(win, gtx, bd.Layout)
+	})
+}
+
+type bookmarkDialog struct {
+	cv     *Canvas
+	when   trace.Timestamp
+	editor widget.Editor
+	add    widget.PrimaryClickable
+	cancel widget.PrimaryClickable
+	focus  bool
+}
+
+// Don't bubble up normal key presses while typing the name, like theme.ListWindow does.
+var bookmarkDialogKeyset = key.Set("⎋|,|.|(Shift)-[A,B,C,D,E,F,G,H,I,J,K,L,M,N,O,P,Q,R,S,T,U,V,W,X,Y,Z]")
+
+func (bd *bookmarkDialog) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.bookmarkDialog.Layout").End()
+
+	key.InputOp{Tag: bd, Keys: bookmarkDialogKeyset}.Add(gtx.Ops)
+	if bd.focus {
+		bd.editor.Focus()
+		bd.focus = false
+	}
+
+	gtx.Constraints.Min = image.Point{}
+	gtx.Constraints.Max.X = gtx.Dp(400)
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return widget.TextLine{Color: win.Theme.Palette.Foreground}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "At "+formatTimestamp(bd.when))
+		}),
+		layout.Rigid(layout.Spacer{Height: 5}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return theme.TextBox(win.Theme, &bd.editor, "Name").Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &bd.add.Clickable, "Add").Layout)),
+				layout.Rigid(layout.Spacer{Width: 5}.Layout),
+				layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &bd.cancel.Clickable, "Cancel").Layout)),
+			)
+		}),
+	)
+
+	confirmed := false
+	for _, ev := range bd.editor.Events() {
+		if _, ok := ev.(widget.SubmitEvent); ok {
+			confirmed = true
+		}
+	}
+	for bd.add.Clicked() {
+		confirmed = true
+	}
+	if confirmed {
+		name := strings.TrimSpace(bd.editor.Text())
+		if name == "" {
+			name = local.Sprintf("Bookmark %d", bd.cv.bookmarksAdded+1)
+		}
+		bd.cv.AddBookmark(name, bd.when)
+		win.CloseModal()
+	}
+
+	for bd.cancel.Clicked() {
+		win.CloseModal()
+	}
+	for _, ev := range gtx.Events(bd) {
+		if ev, ok := ev.(key.Event); ok && ev.State == key.Press && ev.Name == "⎋" {
+			win.CloseModal()
+		}
+	}
+
+	return dims
+}
+
+// BookmarksPanel lists all bookmarks.
+type BookmarksPanel struct {
+	mwin        *MainWindow
+	description Description
+	list        bookmarksList
+
+	theme.PanelButtons
+}
+
+func NewBookmarksPanel(mwin *MainWindow) *BookmarksPanel {
+	return &BookmarksPanel{
+		mwin: mwin,
+		list: bookmarksList{cv: &mwin.canvas},
+	}
+}
+
+func (bp *BookmarksPanel) Title() string {
+	return "Bookmarks"
+}
+
+func (bp *BookmarksPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.BookmarksPanel.Layout").End()
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	// The set of bookmarks can change at any time, so we build the description every frame.
+	tb := TextBuilder{Theme: win.Theme}
+	bp.description.Attributes = []DescriptionAttribute{
+		{Key: "Bookmarks", Value: theme.Immediate(*tb.Span(local.Sprintf("%d", len(bp.mwin.canvas.bookmarks))))},
+		{Key: "Shortcuts", Value: theme.Immediate(*tb.Span("B adds a bookmark at the cursor, . and , cycle through bookmarks"))},
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, bp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			return bp.description.Layout(win, gtx)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return bp.list.Layout(win, gtx)
+		}),
+	)
+
+	for _, ev := range bp.list.Clicked() {
+		if obj, ok := ev.Span.Object.(*bookmarkRemoval); ok {
+			if ev.Event.Type == gesture.TypeClick && ev.Event.Button == pointer.ButtonPrimary {
+				bp.mwin.canvas.RemoveBookmark(obj.Bookmark)
+			}
+			continue
+		}
+		handleLinkClick(win, bp.mwin, ev)
+	}
+
+	for bp.PanelButtons.Backed() {
+		bp.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+type bookmarksList struct {
+	cv   *Canvas
+	list widget.List
+
+	removals allocator[bookmarkRemoval]
+	texts    allocator[Text]
+}
+
+func (bl *bookmarksList) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.bookmarksList.Layout").End()
+
+	bl.list.Axis = layout.Vertical
+	bl.removals.Reset()
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < bl.texts.Len() {
+			txt = bl.texts.Ptr(txtCnt)
+		} else {
+			txt = bl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		b := bl.cv.bookmarks[row]
+		switch col {
+		case 0: // Name
+			txt.Span(b.Name)
+		case 1: // Time
+			txt.Alignment = text.End
+			txt.Link(formatTimestamp(b.When), b.When)
+		case 2: // Since previous
+			if row == 0 {
+				txt.Alignment = text.End
+				txt.Span("—")
+			} else {
+				layoutDuration(txt, time.Duration(b.When-bl.cv.bookmarks[row-1].When))
+			}
+		case 3: // Remove
+			txt.Link("Remove", bl.removals.Allocate(bookmarkRemoval{b}))
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       bookmarksColumns,
+		List:          &bl.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(bl.cv.bookmarks), cellFn)
+	bl.texts.Truncate(txtCnt)
+	return dims
+}
+
+// XXX the widths depend on the font and scaling
+var bookmarksColumns = []theme.TableListColumn{
+	{Name: "Name", MinWidth: 400, MaxWidth: 400},
+	{Name: "Time", MinWidth: 200, MaxWidth: 200},
+	{Name: "Since previous", MinWidth: 150, MaxWidth: 150},
+	{Name: "", MinWidth: 100, MaxWidth: 100},
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (bl *bookmarksList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < bl.texts.Len(); i++ {
+		txt := bl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
@@ -79,6 +79,7 @@ type Canvas struct {
 	debugWindow *DebugWindow
 
 	clickedGoroutineTimelines []*ptrace.Goroutine
+	clickedTaskTimelines      []*ptrace.Task
 	clickedSpans              []struct {
 		Spans     ptrace.Spans
 		AllEvents []ptrace.EventID
@@ -116,12 +117,45 @@ type Canvas struct {
 	}
 
 	locationHistory []LocationHistoryEntry
-	// All timelines. Index 0 and 1 are the GC and STW timelines, followed by processors and goroutines.
-	timelines []*Timeline
+	// Bookmarks, sorted by time
+	bookmarks []*Bookmark
+	// The number of bookmarks that have ever been added, used for naming new bookmarks
+	bookmarksAdded int
+	// All timelines, in the order the user arranged them. By default, index 0 and 1 are the GC and STW timelines,
+	// followed by machines, processors, goroutines and tasks.
+	allTimelines []*Timeline
+	gcTimeline   *Timeline
+	stwTimeline  *Timeline
+	// The timelines that are pinned to the top of the canvas and the timelines that scroll below them. Both are
+	// computed from allTimelines by Canvas.arrangeTimelines.
+	pinnedTimelines []*Timeline
+	timelines       []*Timeline
+	// The height of the area of pinned timelines, including the separator, as of the current frame
+	pinnedHeight int
+	// pinnedEnds[i] describes the Y pixel offset where pinned timeline i ends
+	pinnedEnds  []int
+	arrangement struct {
+		hideIdleProcessors    bool
+		hideRuntimeGoroutines bool
+		// Whether the user changed the default order of timelines
+		reordered bool
+		// The order goroutine timelines were last sorted in
+		goroutineSort goroutineSortOrder
+		// Set when the arrangement changes, until the main window saves it
+		changed bool
+		// Processors that are hidden by hideIdleProcessors, computed lazily
+		idleProcessors map[*ptrace.Processor]struct{}
+	}
+	// Scratch space for the list of displayed timelines
+	displayedTls []*Timeline
+
 	scrollbar widget.Scrollbar
 	axis      Axis
 
-	memoryGraph Plot
+	// Plots displayed above the timelines. The memory plot is always the first plot.
+	plots []*Plot
+	// The subset of plots that aren't hidden, as of the current frame
+	visiblePlots []*Plot
 
 	// State for dragging the canvas
 	drag struct {
@@ -141,6 +175,19 @@ type Canvas struct {
 		active  bool
 	}
 
+	// State for selecting a time range to inspect
+	rangeSelection struct {
+		ready   bool
+		clickAt f32.Point
+		active  bool
+
+		// The selected range, which remains visible until it gets cleared
+		selected   bool
+		start, end trace.Timestamp
+		// Set when the user finished selecting a range, until the main window consumes it
+		done bool
+	}
+
 	// We have multiple sources of the pointer position, which are valid during different times: Canvas.hover and
 	// Canvas.drag.drag – when we're dragging, Canvas.drag.drag grabs pointer input and the hover won't update anymore.
 	pointerAt f32.Point
@@ -152,17 +199,20 @@ type Canvas struct {
 		displayAllLabels   bool
 		compact            bool
 		displayStackTracks bool
+		displayLogMarkers  bool
 		// Should tooltips be shown?
 		showTooltips showTooltips
 		// Should GC overlays be shown?
 		showGCOverlays showGCOverlays
+		// Should flow arrows be shown for all visible goroutines, not just for hovered and selected spans?
+		displayAllFlows bool
 
 		hoveredTimeline *Timeline
 		hoveredSpans    ptrace.Spans
 		hover           gesture.Hover
 	}
 
-	resizeMemoryTimelines component.Resize
+	resizePlots component.Resize
 
 	// prevFrame records the canvas's state in the previous state. It allows reusing the computed displayed spans
 	// between frames if the canvas hasn't changed.
@@ -173,6 +223,7 @@ type Canvas struct {
 		nsPerPx            float64
 		compact            bool
 		displayStackTracks bool
+		displayLogMarkers  bool
 		displayedTls       []*Timeline
 		hoveredTimeline    *Timeline
 		hoveredSpans       ptrace.Spans
@@ -184,6 +235,19 @@ type Canvas struct {
 	// timelineEnds[i] describes the absolute Y pixel offset where timeline i ends. It is computed by
 	// Canvas.computeTimelinePositions
 	timelineEnds []int
+	// goroutineTimelines maps from goroutines' sequential IDs to the indices of their timelines, or -1. It is computed
+	// by Canvas.computeTimelinePositions
+	goroutineTimelines []int
+
+	flows struct {
+		// The span that was clicked most recently, whose flows we display until the selection gets cleared
+		selected          ptrace.Spans
+		selectedGoroutine *ptrace.Goroutine
+
+		// Reused between frames
+		displayed []flow
+		arrows    []flowArrow
+	}
 
 	timelineWidgetsCache Cache[TimelineWidget]
 	trackWidgetsCache    Cache[TrackWidget]
@@ -220,16 +284,19 @@ func (sl *textLengther) Compute(gtx layout.Context, s string, shaper *text.Shape
 func NewCanvasInto(cv *Canvas, dwin *DebugWindow, t *Trace) {
 	*cv = Canvas{}
 
-	cv.resizeMemoryTimelines.Axis = layout.Vertical
-	cv.resizeMemoryTimelines.Ratio = 0.1
+	cv.resizePlots.Axis = layout.Vertical
+	cv.resizePlots.Ratio = 0.25
 	cv.timeline.displayAllLabels = true
 	cv.axis = Axis{cv: cv, anchor: AxisAnchorCenter}
 	cv.trace = t
 	cv.debugWindow = dwin
 
-	cv.timelines = make([]*Timeline, 2, len(t.Goroutines)+len(t.Processors)+len(t.Machines)+2)
-	cv.timelines[0] = NewGCTimeline(cv, t, t.GC)
-	cv.timelines[1] = NewSTWTimeline(cv, t, t.STW)
+	cv.gcTimeline = NewGCTimeline(cv, t, t.GC)
+	cv.stwTimeline = NewSTWTimeline(cv, t, t.STW)
+	cv.allTimelines = make([]*Timeline, 2, len(t.Goroutines)+len(t.Processors)+len(t.Machines)+2)
+	cv.allTimelines[0] = cv.gcTimeline
+	cv.allTimelines[1] = cv.stwTimeline
+	cv.arrangeTimelines()
 
 	cv.timeline.hoveredSpans = NoSpan{}
 
@@ -248,10 +315,17 @@ func (cv *Canvas) computeTimelinePositions(gtx layout.Context) {
 	}
 
 	cv.timelineEnds = slices.Grow(cv.timelineEnds[:0], len(cv.timelines))[:len(cv.timelines)]
+	cv.goroutineTimelines = slices.Grow(cv.goroutineTimelines[:0], len(cv.trace.Goroutines))[:len(cv.trace.Goroutines)]
+	for i := range cv.goroutineTimelines {
+		cv.goroutineTimelines[i] = -1
+	}
 	accEnds := 0
 	for i, tl := range cv.timelines {
 		accEnds += tl.Height(gtx, cv)
 		cv.timelineEnds[i] = accEnds
+		if g, ok := tl.item.(*ptrace.Goroutine); ok {
+			cv.goroutineTimelines[g.SeqID] = i
+		}
 	}
 }
 
@@ -306,6 +380,28 @@ func (cv *Canvas) navigateToStartAndEnd(gtx layout.Context, start, end trace.Tim
 	cv.navigateTo(gtx, start, nsPerPx, y)
 }
 
+// originOffset returns the distance between the start of the canvas and the axis's origin.
+func (cv *Canvas) originOffset() trace.Timestamp {
+	d := cv.End() - cv.start
+	switch cv.axis.anchor {
+	case AxisAnchorNone:
+		return cv.pxToTs(cv.axis.position) - cv.start
+	case AxisAnchorStart:
+		return 0
+	case AxisAnchorCenter:
+		return d / 2
+	case AxisAnchorEnd:
+		return d
+	default:
+		panic(fmt.Sprintf("unhandled anchor %d", cv.axis.anchor))
+	}
+}
+
+// navigateToTimestamp pans the canvas so that ts is at the axis's origin, without changing the zoom level.
+func (cv *Canvas) navigateToTimestamp(gtx layout.Context, ts trace.Timestamp) {
+	cv.navigateTo(gtx, ts-cv.originOffset(), cv.nsPerPx, cv.y)
+}
+
 func (cv *Canvas) navigateToNoHistory(gtx layout.Context, start trace.Timestamp, nsPerPx float64, y int) {
 	if !cv.navigateToChecks(start, nsPerPx, y) {
 		return
@@ -359,6 +455,7 @@ func (cv *Canvas) unchanged() bool {
 		cv.prevFrame.y == cv.y &&
 		cv.prevFrame.compact == cv.timeline.compact &&
 		cv.prevFrame.displayStackTracks == cv.timeline.displayStackTracks &&
+		cv.prevFrame.displayLogMarkers == cv.timeline.displayLogMarkers &&
 		cv.prevFrame.filter == cv.timeline.filter &&
 		cv.prevFrame.automaticFilter == cv.timeline.automaticFilter
 }
@@ -397,6 +494,89 @@ func (cv *Canvas) endZoomSelection(win *theme.Window, gtx layout.Context, pos f3
 	cv.navigateToStartAndEnd(gtx, start, end, cv.y)
 }
 
+func (cv *Canvas) startRangeSelection(pos f32.Point) {
+	cv.rangeSelection.active = true
+	cv.rangeSelection.clickAt = pos
+}
+
+func (cv *Canvas) endRangeSelection(win *theme.Window, gtx layout.Context, pos f32.Point) {
+	cv.rangeSelection.active = false
+	one := cv.rangeSelection.clickAt.X
+	two := pos.X
+
+	startPx := min(one, two)
+	endPx := max(one, two)
+
+	if startPx < 0 {
+		startPx = 0
+	}
+	if limit := float32(cv.VisibleWidth(win, gtx)); endPx > limit {
+		endPx = limit
+	}
+
+	start := cv.pxToTs(startPx)
+	end := cv.pxToTs(endPx)
+	if start == end {
+		return
+	}
+	cv.SelectRange(start, end)
+}
+
+// SelectRange selects the time range [start, end] and displays it on the canvas.
+func (cv *Canvas) SelectRange(start, end trace.Timestamp) {
+	cv.rangeSelection.selected = true
+	cv.rangeSelection.start = start
+	cv.rangeSelection.end = end
+	cv.rangeSelection.done = true
+}
+
+func (cv *Canvas) ClearRangeSelection() {
+	cv.rangeSelection.selected = false
+	cv.rangeSelection.done = false
+}
+
+// SelectedRange returns the range the user finished selecting since the last call to SelectedRange, if any.
+func (cv *Canvas) SelectedRange() (start, end trace.Timestamp, ok bool) {
+	if !cv.rangeSelection.done {
+		return 0, 0, false
+	}
+	cv.rangeSelection.done = false
+	return cv.rangeSelection.start, cv.rangeSelection.end, true
+}
+
+// drawRange highlights the range between two pixel offsets and labels it with its duration.
+func (cv *Canvas) drawRange(win *theme.Window, gtx layout.Context, startPx, endPx float32) {
+	if startPx > endPx {
+		startPx, endPx = endPx, startPx
+	}
+	rect := clip.FRect{
+		Min: f32.Pt(startPx, 0),
+		Max: f32.Pt(endPx, float32(gtx.Constraints.Max.Y)),
+	}
+	paint.FillShape(gtx.Ops, colors[colorRangeSelection], rect.Op(gtx.Ops))
+	for _, x := range [2]float32{startPx, endPx} {
+		line := clip.FRect{
+			Min: f32.Pt(x, 0),
+			Max: f32.Pt(x+1, float32(gtx.Constraints.Max.Y)),
+		}
+		paint.FillShape(gtx.Ops, colors[colorRangeSelectionBorder], line.Op(gtx.Ops))
+	}
+
+	d := time.Duration(cv.pxToTs(endPx) - cv.pxToTs(startPx))
+	rec := Record(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+		gtx.Constraints.Min = image.Point{}
+		return layout.UniformInset(2).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return widget.TextLine{Color: win.Theme.Palette.Background}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, roundDuration(d).String())
+		})
+	})
+	// Center the label in the range, below the axis.
+	x := int(round32((startPx+endPx)/2)) - rec.Dimensions.Size.X/2
+	y := gtx.Dp(tickHeightDp) * 3
+	defer op.Offset(image.Pt(x, y)).Push(gtx.Ops).Pop()
+	paint.FillShape(gtx.Ops, colors[colorRangeSelectionBorder], clip.Rect{Max: rec.Dimensions.Size}.Op())
+	rec.Layout(win, gtx)
+}
+
 func (cv *Canvas) startDrag(pos f32.Point) {
 	cv.cancelNavigation()
 	cv.rememberLocation()
@@ -514,7 +694,20 @@ func (cv *Canvas) ZoomToFitCurrentView(gtx layout.Context) {
 	cv.navigateToStartAndEnd(gtx, first, last, cv.y)
 }
 
+// timelineY returns the Y offset at which the timeline of act is at the top of the scrolled area. Pinned timelines are
+// always visible, so for them it returns the current offset. Hidden timelines get shown again, as the user is trying
+// to navigate to them.
 func (cv *Canvas) timelineY(gtx layout.Context, act any) int {
+	if tl := cv.timelineOf(act); tl != nil {
+		if tl.pinned {
+			return cv.y
+		}
+		if cv.timelineHidden(tl) {
+			cv.ShowTimeline(tl)
+			cv.computeTimelinePositions(gtx)
+		}
+	}
+
 	// OPT(dh): don't be O(n)
 	off := 0
 	for _, tl := range cv.timelines {
@@ -527,6 +720,17 @@ func (cv *Canvas) timelineY(gtx layout.Context, act any) int {
 	panic("unreachable")
 }
 
+// timelineOf returns the timeline that displays item, or nil if there is no such timeline.
+func (cv *Canvas) timelineOf(item any) *Timeline {
+	// OPT(dh): don't be O(n)
+	for _, tl := range cv.allTimelines {
+		if item == tl.item {
+			return tl
+		}
+	}
+	return nil
+}
+
 func (cv *Canvas) scrollToTimeline(gtx layout.Context, act any) {
 	off := cv.timelineY(gtx, act)
 	cv.navigateTo(gtx, cv.start, cv.nsPerPx, off)
@@ -562,10 +766,86 @@ func (cv *Canvas) height(gtx layout.Context) int {
 	return total
 }
 
+// updateVisiblePlots updates the list of plots that aren't hidden. Whenever that list changes, the available space is
+// distributed evenly among the visible plots.
+func (cv *Canvas) updateVisiblePlots() {
+	i := 0
+	for _, pl := range cv.plots {
+		if pl.hidden {
+			continue
+		}
+		if i >= len(cv.visiblePlots) || cv.visiblePlots[i] != pl {
+			i = -1
+			break
+		}
+		i++
+	}
+	if i == len(cv.visiblePlots) {
+		return
+	}
+
+	cv.visiblePlots = cv.visiblePlots[:0]
+	for _, pl := range cv.plots {
+		if !pl.hidden {
+			cv.visiblePlots = append(cv.visiblePlots, pl)
+		}
+	}
+	for i, pl := range cv.visiblePlots {
+		pl.resize.Axis = layout.Vertical
+		pl.resize.Ratio = 1 / float32(len(cv.visiblePlots)-i)
+	}
+}
+
+// layoutPlots lays out plots from top to bottom. Each plot can be resized individually, taking space from the plots
+// below it.
+func (cv *Canvas) layoutPlots(win *theme.Window, gtx layout.Context, plots []*Plot) layout.Dimensions {
+	if len(plots) == 1 {
+		return plots[0].Layout(win, gtx, cv)
+	}
+
+	return theme.Resize(win.Theme, &plots[0].resize).Layout(win, gtx,
+		func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+			return plots[0].Layout(win, gtx, cv)
+		},
+		func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+			return cv.layoutPlots(win, gtx, plots[1:])
+		},
+	)
+}
+
+// ShowAllPlots unhides all hidden plots.
+func (cv *Canvas) ShowAllPlots() {
+	for _, pl := range cv.plots {
+		pl.hidden = false
+	}
+}
+
+// HasPlot reports whether the plot is currently being displayed.
+func (cv *Canvas) HasPlot(pl *Plot) bool {
+	return slices.Contains(cv.plots, pl)
+}
+
+// AddPlot displays an additional plot below the existing ones.
+func (cv *Canvas) AddPlot(pl *Plot) {
+	if !cv.HasPlot(pl) {
+		cv.plots = append(cv.plots, pl)
+	}
+}
+
+func (cv *Canvas) RemovePlot(pl *Plot) {
+	if i := slices.Index(cv.plots, pl); i != -1 {
+		cv.plots = slices.Delete(cv.plots, i, i+1)
+	}
+}
+
 func (cv *Canvas) ToggleStackTracks() {
 	cv.timeline.displayStackTracks = !cv.timeline.displayStackTracks
 }
 
+func (cv *Canvas) ToggleLogMarkers() {
+	cv.timeline.displayLogMarkers = !cv.timeline.displayLogMarkers
+}
+
 func (cv *Canvas) UndoNavigation(gtx layout.Context) {
 	if e, ok := cv.popLocationHistory(); ok {
 		cv.navigateToNoHistory(gtx, e.start, e.nsPerPx, e.y)
@@ -716,6 +996,27 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 						cv.y = y - (int(cv.timeline.hover.Pointer().Y) - int(offset))
 					}
 
+				case "⎋":
+					cv.ClearRangeSelection()
+					cv.flows.selected = nil
+
+				case "A":
+					cv.ToggleFlowArrows()
+
+				case "B":
+					displayAddBookmarkDialog(win, cv, cv.pxToTs(cv.pointerAt.X))
+
+				case ".", ",":
+					dir := 1
+					if ev.Name == "," {
+						dir = -1
+					}
+					if b, ok := cv.cycleBookmarks(gtx, dir); ok {
+						win.ShowNotification(gtx, b.Name)
+					} else {
+						win.ShowNotification(gtx, "No bookmarks")
+					}
+
 				case "Z":
 					if ev.Modifiers.Contain(key.ModShortcut) {
 						cv.UndoNavigation(gtx)
@@ -724,6 +1025,9 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 				case "X":
 					cv.ToggleTimelineLabels()
 
+				case "L":
+					cv.ToggleLogMarkers()
+
 				case "C":
 					cv.ToggleCompactDisplay()
 					if h := cv.timeline.hoveredTimeline; h != nil {
@@ -789,10 +1093,16 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 	for _, ev := range cv.drag.drag.Events(gtx.Metric, gtx, gesture.Both) {
 		switch ev.Type {
 		case pointer.Press:
+			if h := cv.timeline.hoveredTimeline; h != nil && h.TimelineWidget != nil && h.labelClick.Hovered() {
+				// Dragging a timeline's label reorders the timeline.
+				break
+			}
 			if ev.Modifiers == 0 {
 				cv.drag.ready = true
 			} else if ev.Modifiers == key.ModShortcut {
 				cv.zoomSelection.ready = true
+			} else if ev.Modifiers == key.ModShift {
+				cv.rangeSelection.ready = true
 			}
 		case pointer.Drag:
 			cv.pointerAt = ev.Position
@@ -800,6 +1110,8 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 				cv.startDrag(ev.Position)
 			} else if cv.zoomSelection.ready && !cv.zoomSelection.active {
 				cv.startZoomSelection(ev.Position)
+			} else if cv.rangeSelection.ready && !cv.rangeSelection.active {
+				cv.startRangeSelection(ev.Position)
 			}
 			if cv.drag.active {
 				cv.dragTo(gtx, ev.Position)
@@ -807,12 +1119,16 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 		case pointer.Release, pointer.Cancel:
 			cv.drag.ready = false
 			cv.zoomSelection.ready = false
+			cv.rangeSelection.ready = false
 			if cv.drag.active {
 				cv.endDrag()
 			}
 			if cv.zoomSelection.active {
 				cv.endZoomSelection(win, gtx, ev.Position)
 			}
+			if cv.rangeSelection.active {
+				cv.endRangeSelection(win, gtx, ev.Position)
+			}
 		}
 	}
 
@@ -832,6 +1148,7 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 		cv.hover.Add(gtx.Ops)
 
 		cv.clickedGoroutineTimelines = cv.clickedGoroutineTimelines[:0]
+		cv.clickedTaskTimelines = cv.clickedTaskTimelines[:0]
 
 		if d := cv.scrollbar.ScrollDistance(); d != 0 {
 			// TODO(dh): because scroll amounts are relative even when the user clicks on a specific spot on the
@@ -858,7 +1175,7 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 		if cv.drag.active {
 			pointer.CursorAllScroll.Add(gtx.Ops)
 		}
-		key.InputOp{Tag: cv, Keys: "Short-Z|C|S|O|T|X|(Shift)-(Short)-" + key.NameHome}.Add(gtx.Ops)
+		key.InputOp{Tag: cv, Keys: "Short-Z|A|B|C|L|S|O|T|X|.|,|⎋|(Shift)-(Short)-" + key.NameHome}.Add(gtx.Ops)
 
 		drawRegionOverlays := func(spans ptrace.Spans, c color.NRGBA, height int) {
 			var p clip.Path
@@ -890,7 +1207,7 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 			paint.FillShape(gtx.Ops, c, clip.Outline{Path: p.End()}.Op())
 		}
 
-		// Draw axis, memory graph, timelines, and scrollbar
+		// Draw axis, plots, timelines, and scrollbar
 		layout.Flex{Axis: layout.Vertical, WeightSum: 1}.Layout(gtx,
 			// Axis
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
@@ -905,55 +1222,63 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 				drawRegionOverlays((cv.trace.STW), colors[colorStateBlocked], tickHeight)
 
 				dims := cv.axis.Layout(win, gtx)
+				cv.drawBookmarkLabels(win, gtx)
 
 				return dims
 			}),
 
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				return theme.Resize(win.Theme, &cv.resizeMemoryTimelines).Layout(win, gtx,
-					// Memory graph
+				// Timelines and scrollbar
+				timelines := func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+					return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+						// Timelines
+						layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+							defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+							if width := gtx.Constraints.Max.X; width != cv.width {
+								panic(fmt.Sprintf("computed timelines width differs from actual width: %d != %d", cv.width, width))
+							}
+
+							cv.drag.drag.Add(gtx.Ops)
+
+							cv.timeline.hover.Add(gtx.Ops)
+							dims, tws := cv.layoutTimelines(win, gtx)
+							cv.prevFrame.displayedTls = tws
+							cv.drawFlows(gtx)
+							return dims
+						}),
+
+						// Scrollbar
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							totalHeight := cv.height(gtx)
+							if len(cv.timelines) > 0 {
+								// Allow scrolling past the last goroutine
+								totalHeight += cv.timelines[len(cv.timelines)-1].Height(gtx, cv)
+							}
+
+							fraction := float32(gtx.Constraints.Max.Y-cv.pinnedHeight) / float32(totalHeight)
+							offset := float32(cv.y) / float32(totalHeight)
+							sb := theme.Scrollbar(win.Theme, &cv.scrollbar)
+							return sb.Layout(gtx, layout.Vertical, offset, offset+fraction)
+						}),
+					)
+				}
+
+				cv.updateVisiblePlots()
+				if len(cv.visiblePlots) == 0 {
+					return timelines(win, gtx)
+				}
+
+				return theme.Resize(win.Theme, &cv.resizePlots).Layout(win, gtx,
+					// Plots
 					func(win *theme.Window, gtx layout.Context) layout.Dimensions {
 						defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
 						cv.drag.drag.Add(gtx.Ops)
 
-						dims := cv.memoryGraph.Layout(win, gtx, cv)
-						return dims
+						return cv.layoutPlots(win, gtx, cv.visiblePlots)
 					},
 
-					// Timelines and scrollbar
-					func(win *theme.Window, gtx layout.Context) layout.Dimensions {
-						return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
-							// Timelines
-							layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
-								defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
-
-								if width := gtx.Constraints.Max.X; width != cv.width {
-									panic(fmt.Sprintf("computed timelines width differs from actual width: %d != %d", cv.width, width))
-								}
-
-								cv.drag.drag.Add(gtx.Ops)
-
-								cv.timeline.hover.Add(gtx.Ops)
-								dims, tws := cv.layoutTimelines(win, gtx)
-								cv.prevFrame.displayedTls = tws
-								return dims
-							}),
-
-							// Scrollbar
-							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-								totalHeight := cv.height(gtx)
-								if len(cv.timelines) > 0 {
-									// Allow scrolling past the last goroutine
-									totalHeight += cv.timelines[len(cv.timelines)-1].Height(gtx, cv)
-								}
-
-								fraction := float32(gtx.Constraints.Max.Y) / float32(totalHeight)
-								offset := float32(cv.y) / float32(totalHeight)
-								sb := theme.Scrollbar(win.Theme, &cv.scrollbar)
-								return sb.Layout(gtx, layout.Vertical, offset, offset+fraction)
-							}),
-						)
-					},
+					timelines,
 				)
 			}),
 		)
@@ -969,6 +1294,13 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 			paint.FillShape(gtx.Ops, win.Theme.Palette.PrimarySelection, rect.Op(gtx.Ops))
 		}
 
+		// Draw range selection
+		if cv.rangeSelection.active {
+			cv.drawRange(win, gtx, cv.rangeSelection.clickAt.X, cv.pointerAt.X)
+		} else if cv.rangeSelection.selected {
+			cv.drawRange(win, gtx, cv.tsToPx(cv.rangeSelection.start), cv.tsToPx(cv.rangeSelection.end))
+		}
+
 		// Draw STW and GC overlays
 		if cv.timeline.showGCOverlays >= showGCOverlaysBoth {
 			c := colors[colorStateGC]
@@ -981,6 +1313,8 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 			drawRegionOverlays((cv.trace.STW), c, gtx.Constraints.Max.Y)
 		}
 
+		cv.drawBookmarks(gtx)
+
 		// Draw cursor
 		rect := clip.Rect{
 			Min: image.Pt(int(round32(cv.pointerAt.X)), 0),
@@ -996,6 +1330,7 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 	cv.prevFrame.y = cv.y
 	cv.prevFrame.compact = cv.timeline.compact
 	cv.prevFrame.displayStackTracks = cv.timeline.displayStackTracks
+	cv.prevFrame.displayLogMarkers = cv.timeline.displayLogMarkers
 	cv.prevFrame.hoveredSpans = cv.timeline.hoveredSpans
 	cv.prevFrame.hoveredTimeline = cv.timeline.hoveredTimeline
 	cv.prevFrame.filter = cv.timeline.filter
@@ -1015,6 +1350,10 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 				// TODO(dh): give all relevant types a method that we can check for, instead of having to hard-code
 				// a list of types here.
 			}
+			if g, ok := tl.item.(*ptrace.Goroutine); ok {
+				cv.flows.selected = clicked.Spans
+				cv.flows.selectedGoroutine = g
+			}
 			cv.clickedSpans = append(cv.clickedSpans, struct {
 				Spans     ptrace.Spans
 				AllEvents []ptrace.EventID
@@ -1092,6 +1431,22 @@ func (cv *Canvas) layoutTimelines(win *theme.Window, gtx layout.Context) (layout
 		tl.displayed = false
 	}
 
+	displayed := cv.displayedTls[:0]
+	cv.pinnedHeight = 0
+	if len(cv.pinnedTimelines) > 0 {
+		var n int
+		cv.pinnedHeight, n = cv.layoutPinnedTimelines(win, gtx)
+		displayed = append(displayed, cv.pinnedTimelines[:n]...)
+	}
+
+	stack := op.Offset(image.Pt(0, cv.pinnedHeight)).Push(gtx.Ops)
+	gtx.Constraints.Max.Y -= cv.pinnedHeight
+	if gtx.Constraints.Max.Y < 0 {
+		gtx.Constraints.Max.Y = 0
+	}
+	gtx.Constraints.Min.Y = 0
+	clipStack := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+
 	start, end := cv.visibleTimelines(gtx)
 
 	y := -cv.y
@@ -1103,21 +1458,16 @@ func (cv *Canvas) layoutTimelines(win *theme.Window, gtx layout.Context) (layout
 		tl := cv.timelines[i]
 		stack := op.Offset(image.Pt(0, y)).Push(gtx.Ops)
 		topBorder := i > 0 && cv.timelines[i-1].Hovered()
-		if tl.TimelineWidget == nil {
-			tl.TimelineWidget = cv.timelineWidgetsCache.Get()
-			*tl.TimelineWidget = TimelineWidget{cv: cv}
-		}
-		tl.Layout(win, gtx, cv, cv.timeline.displayAllLabels, cv.timeline.compact, topBorder, &cv.trackSpanLabels)
+		cv.layoutTimeline(win, gtx, tl, topBorder)
 		stack.Pop()
 
 		y += tl.Height(gtx, cv)
-
-		if tl.LabelClicked() {
-			if g, ok := tl.item.(*ptrace.Goroutine); ok {
-				cv.clickedGoroutineTimelines = append(cv.clickedGoroutineTimelines, g)
-			}
-		}
 	}
+	displayed = append(displayed, cv.timelines[start:end]...)
+	cv.drawReorderIndicator(gtx, cv.timelines, cv.timelineEnds, cv.timelines[start:end], -cv.y)
+
+	clipStack.Pop()
+	stack.Pop()
 
 	for _, tl := range cv.prevFrame.displayedTls {
 		if !tl.displayed {
@@ -1127,7 +1477,71 @@ func (cv *Canvas) layoutTimelines(win *theme.Window, gtx layout.Context) (layout
 		}
 	}
 
-	return layout.Dimensions{Size: gtx.Constraints.Max}, cv.timelines[start:end]
+	// Apply reorderings after all timelines have been laid out, as they change the lists of timelines.
+	for _, tl := range displayed {
+		if dy, ok := tl.Reordered(); ok {
+			cv.reorderTimeline(tl, dy)
+			cv.computeTimelinePositions(gtx)
+		}
+	}
+
+	cv.displayedTls = displayed
+	return layout.Dimensions{Size: gtx.Constraints.Max}, displayed
+}
+
+// layoutPinnedTimelines lays out the pinned timelines at the top of the canvas, using at most half of the available
+// height. It returns the height of the pinned area, including the separator, and the number of timelines that fit.
+func (cv *Canvas) layoutPinnedTimelines(win *theme.Window, gtx layout.Context) (height int, n int) {
+	cv.pinnedEnds = cv.pinnedEnds[:0]
+	for _, tl := range cv.pinnedTimelines {
+		height += tl.Height(gtx, cv)
+		cv.pinnedEnds = append(cv.pinnedEnds, height)
+	}
+	if maxHeight := gtx.Constraints.Max.Y / 2; height > maxHeight {
+		height = maxHeight
+	}
+
+	stack := clip.Rect{Max: image.Pt(gtx.Constraints.Max.X, height)}.Push(gtx.Ops)
+	y := 0
+	for i, tl := range cv.pinnedTimelines {
+		if y >= height {
+			break
+		}
+		stack := op.Offset(image.Pt(0, y)).Push(gtx.Ops)
+		topBorder := i > 0 && cv.pinnedTimelines[i-1].Hovered()
+		cv.layoutTimeline(win, gtx, tl, topBorder)
+		stack.Pop()
+
+		y += tl.Height(gtx, cv)
+		n++
+	}
+	cv.drawReorderIndicator(gtx, cv.pinnedTimelines, cv.pinnedEnds, cv.pinnedTimelines[:n], 0)
+	stack.Pop()
+
+	sep := gtx.Dp(pinnedSeparatorHeightDp)
+	paint.FillShape(gtx.Ops, colors[colorPinnedSeparator], clip.Rect{
+		Min: image.Pt(0, height),
+		Max: image.Pt(gtx.Constraints.Max.X, height+sep),
+	}.Op())
+
+	return height + sep, n
+}
+
+func (cv *Canvas) layoutTimeline(win *theme.Window, gtx layout.Context, tl *Timeline, topBorder bool) {
+	if tl.TimelineWidget == nil {
+		tl.TimelineWidget = cv.timelineWidgetsCache.Get()
+		*tl.TimelineWidget = TimelineWidget{cv: cv}
+	}
+	tl.Layout(win, gtx, cv, cv.timeline.displayAllLabels, cv.timeline.compact, topBorder, &cv.trackSpanLabels)
+
+	if tl.LabelClicked() {
+		switch item := tl.item.(type) {
+		case *ptrace.Goroutine:
+			cv.clickedGoroutineTimelines = append(cv.clickedGoroutineTimelines, item)
+		case *ptrace.Task:
+			cv.clickedTaskTimelines = append(cv.clickedTaskTimelines, item)
+		}
+	}
 }
 
 // setPointerPosition updates the canvas's pointer position. This is used by Axis to keep the canvas updated while the
@@ -1187,8 +1601,15 @@ func (axis *Axis) Layout(win *theme.Window, gtx layout.Context) (dims layout.Dim
 
 	for _, ev := range axis.click.Events(gtx.Queue) {
 		if ev.Type == gesture.TypePress && ev.Button == pointer.ButtonSecondary {
+			at := axis.cv.pxToTs(float32(ev.Position.X))
 			win.SetContextMenu(
 				[]*theme.MenuItem{
+					{
+						Label: PlainLabel("Add bookmark here"),
+						Do: func(gtx layout.Context) {
+							displayAddBookmarkDialog(win, axis.cv, at)
+						},
+					},
 					{
 						Label:    PlainLabel("Move origin to the left"),
 						Disabled: func() bool { return axis.anchor == AxisAnchorStart },
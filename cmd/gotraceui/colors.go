@@ -25,11 +25,39 @@ var colors = [...]color.NRGBA{
 	colorStateUserRegion: rgba(0xF2A2E8FF),
 	colorStateCPUSample:  rgba(0x98D597FF),
 	colorStateStack:      rgba(0x79B579FF),
+	colorStateTask:       rgba(0xD3A2F2FF),
 
 	colorStateDone: rgba(0x000000FF),
 
+	colorStateUser0: rgba(0xD9A441FF),
+	colorStateUser1: rgba(0x4178BAFF),
+	colorStateUser2: rgba(0xC9699AFF),
+	colorStateUser3: rgba(0x6B8E23FF),
+	colorStateUser4: rgba(0xE07B39FF),
+	colorStateUser5: rgba(0x3FA7A0FF),
+	colorStateUser6: rgba(0x8C6D46FF),
+	colorStateUser7: rgba(0x7A7ADBFF),
+
 	colorTimelineLabel:  rgba(0x888888FF),
 	colorTimelineBorder: rgba(0xDDDDDDFF),
+	// Background of parts of timelines that couldn't be active
+	colorTimelineInactive: rgba(0xEEEEEEFF),
+	// Background of parts of processor timelines that were idle while goroutines were runnable
+	colorTimelineIdle: rgba(0xF8D7C4FF),
+
+	colorUserLogMarker: rgba(0x1F5FCFFF),
+	colorBookmark:      rgba(0xE07B00FF),
+
+	colorRangeSelection:       rgba(0x4178BA33),
+	colorRangeSelectionBorder: rgba(0x4178BAFF),
+
+	colorFlowUnblock: rgba(0xC2185BFF),
+	colorFlowCreate:  rgba(0x2E7D32FF),
+
+	// Line between pinned and scrolled timelines
+	colorPinnedSeparator: rgba(0x888888FF),
+	// Where a timeline will be moved to when dragging its label
+	colorTimelineReorder: rgba(0x4178BAFF),
 
 	// TODO(dh): find a nice color for this
 	colorSpanHighlightedPrimaryOutline:   rgba(0xFF00FFFF),
@@ -38,6 +66,10 @@ var colors = [...]color.NRGBA{
 
 type colorIndex uint8
 
+// numUserStateColors is the number of colors used for user-defined states. There are fewer colors than possible states,
+// and colors get reused.
+const numUserStateColors = 8
+
 const (
 	colorStateUnknown colorIndex = iota
 
@@ -58,12 +90,33 @@ const (
 	colorStateUserRegion
 	colorStateStack
 	colorStateCPUSample
+	colorStateTask
 	colorStateDone
+	// Colors of user-defined states, see numUserStateColors
+	colorStateUser0
+	colorStateUser1
+	colorStateUser2
+	colorStateUser3
+	colorStateUser4
+	colorStateUser5
+	colorStateUser6
+	colorStateUser7
 
 	colorStateLast
 
 	colorTimelineLabel
 	colorTimelineBorder
+	colorTimelineInactive
+	colorTimelineIdle
+
+	colorUserLogMarker
+	colorBookmark
+	colorRangeSelection
+	colorRangeSelectionBorder
+	colorFlowUnblock
+	colorFlowCreate
+	colorPinnedSeparator
+	colorTimelineReorder
 
 	colorSpanHighlightedPrimaryOutline
 	colorSpanHighlightedSecondaryOutline
@@ -95,6 +148,7 @@ var stateColors = [256]colorIndex{
 	ptrace.StateUserRegion:              colorStateUserRegion,
 	ptrace.StateStack:                   colorStateStack,
 	ptrace.StateCPUSample:               colorStateCPUSample,
+	ptrace.StateTask:                    colorStateTask,
 	ptrace.StateDone:                    colorStateDone,
 
 	// per-P states
@@ -104,6 +158,16 @@ var stateColors = [256]colorIndex{
 	ptrace.StateRunningP: colorStateActive,
 }
 
+// stateColor returns the color of spans in a scheduling state. User-defined states cycle through a fixed palette. The
+// base state is already evident from the tooltip and statistics, and distinguishing user states from each other is
+// more useful than making them look like their base states.
+func stateColor(state ptrace.SchedulingState) colorIndex {
+	if ptrace.IsUserState(state) {
+		return colorStateUser0 + colorIndex(int(state-ptrace.StateUser0)%numUserStateColors)
+	}
+	return stateColors[state]
+}
+
 func rgba(c uint32) color.NRGBA {
 	// XXX does endianness matter?
 	return color.NRGBA{
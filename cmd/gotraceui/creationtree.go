@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/gesture"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+	"gioui.org/text"
+)
+
+// goroutineChildren is the object of links that open the creation tree at a goroutine's children.
+type goroutineChildren struct {
+	Goroutine *ptrace.Goroutine
+}
+
+// cpuTime returns the time g spent on a CPU, running its own code or doing work for the GC.
+func cpuTime(tr *Trace, g *ptrace.Goroutine) time.Duration {
+	var d time.Duration
+	for i := 0; i < g.Spans.Len(); i++ {
+		s := g.Spans.AtPtr(i)
+		switch tr.BaseState(s.State) {
+		case ptrace.StateActive, ptrace.StateGCIdle, ptrace.StateGCDedicated, ptrace.StateGCFractional,
+			ptrace.StateGCMarkAssist, ptrace.StateGCSweep:
+			d += s.Duration()
+		}
+	}
+	return d
+}
+
+// creationTreeAggregates are the per goroutine aggregates of the creation tree, indexed by the goroutines' sequence IDs.
+type creationTreeAggregates struct {
+	// The number of direct and indirect children
+	Descendants []int
+	// The CPU time of goroutines, not including their descendants
+	CPUTime []time.Duration
+	// The CPU time of goroutines and all their descendants
+	SubtreeCPUTime []time.Duration
+	// Goroutines that weren't created by other goroutines in the trace
+	Roots []*ptrace.Goroutine
+}
+
+func computeCreationTreeAggregates(tr *Trace, cancelled <-chan struct{}) *creationTreeAggregates {
+	out := &creationTreeAggregates{
+		Descendants:    make([]int, len(tr.Goroutines)),
+		CPUTime:        make([]time.Duration, len(tr.Goroutines)),
+		SubtreeCPUTime: make([]time.Duration, len(tr.Goroutines)),
+	}
+	for i, g := range tr.Goroutines {
+		if i%1000 == 0 {
+			select {
+			case <-cancelled:
+				return nil
+			default:
+			}
+		}
+		out.CPUTime[g.SeqID] = cpuTime(tr, g)
+		if g.Parent == nil {
+			out.Roots = append(out.Roots, g)
+		}
+	}
+
+	// Aggregate in post-order. We don't rely on goroutine IDs to order parents before their children, as IDs get
+	// allocated in per-P batches.
+	var visit func(g *ptrace.Goroutine)
+	visit = func(g *ptrace.Goroutine) {
+		n := 0
+		d := out.CPUTime[g.SeqID]
+		for _, c := range g.Children {
+			visit(c)
+			n += 1 + out.Descendants[c.SeqID]
+			d += out.SubtreeCPUTime[c.SeqID]
+		}
+		out.Descendants[g.SeqID] = n
+		out.SubtreeCPUTime[g.SeqID] = d
+	}
+	for _, g := range out.Roots {
+		visit(g)
+	}
+
+	select {
+	case <-cancelled:
+		return nil
+	default:
+	}
+	return out
+}
+
+// CreationTreePanel displays which goroutines created which other goroutines, as an expandable tree.
+type CreationTreePanel struct {
+	mwin       *MainWindow
+	aggregates *theme.Future[*creationTreeAggregates]
+	// The goroutine to reveal once the aggregates have been computed
+	focus *ptrace.Goroutine
+
+	initialized bool
+	description Description
+	tree        creationTreeList
+
+	theme.PanelButtons
+}
+
+func NewCreationTreePanel(mwin *MainWindow, focus *ptrace.Goroutine) *CreationTreePanel {
+	tr := mwin.trace
+	return &CreationTreePanel{
+		mwin:  mwin,
+		focus: focus,
+		aggregates: theme.NewFuture(mwin.twin, func(cancelled <-chan struct{}) *creationTreeAggregates {
+			return computeCreationTreeAggregates(tr, cancelled)
+		}),
+		tree: creationTreeList{
+			expanded: map[*ptrace.Goroutine]bool{},
+		},
+	}
+}
+
+func (ct *CreationTreePanel) Title() string {
+	if ct.focus != nil {
+		return local.Sprintf("Goroutines created by goroutine %d", ct.focus.ID)
+	}
+	return "Goroutine creation tree"
+}
+
+func (ct *CreationTreePanel) init(win *theme.Window, agg *creationTreeAggregates) {
+	ct.tree.agg = agg
+	if ct.focus != nil {
+		ct.tree.reveal(ct.focus)
+	} else {
+		ct.tree.rebuild()
+	}
+
+	var maxDepth int
+	for _, g := range ct.mwin.trace.Goroutines {
+		depth := 0
+		for p := g.Parent; p != nil; p = p.Parent {
+			depth++
+		}
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+
+	value := func(s *TextSpan) *theme.Future[TextSpan] {
+		return theme.Immediate(*s)
+	}
+	tb := TextBuilder{Theme: win.Theme}
+	ct.description.Attributes = []DescriptionAttribute{
+		{Key: "Goroutines", Value: value(tb.Span(local.Sprintf("%d", len(ct.mwin.trace.Goroutines))))},
+		{Key: "Roots", Value: value(tb.Span(local.Sprintf("%d", len(agg.Roots))))},
+		{Key: "Maximum depth", Value: value(tb.Span(local.Sprintf("%d", maxDepth)))},
+	}
+}
+
+func (ct *CreationTreePanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.CreationTreePanel.Layout").End()
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	agg, ok := ct.aggregates.Result()
+	if ok && !ct.initialized {
+		ct.init(win, agg)
+		ct.initialized = true
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, ct.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !ok {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing creation tree…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min = image.Point{}
+					return ct.description.Layout(win, gtx)
+				}),
+
+				layout.Rigid(layout.Spacer{Height: 10}.Layout),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return ct.tree.Layout(win, gtx)
+				}),
+			)
+		}),
+	)
+
+	for _, ev := range ct.tree.Clicked() {
+		if node, ok := ev.Span.Object.(*creationTreeToggle); ok {
+			if ev.Event.Type == gesture.TypeClick && ev.Event.Button == pointer.ButtonPrimary {
+				ct.tree.toggle(node.Goroutine)
+			}
+			continue
+		}
+		handleLinkClick(win, ct.mwin, ev)
+	}
+
+	for ct.PanelButtons.Backed() {
+		ct.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+// creationTreeToggle is the object of links that expand or collapse a goroutine's children.
+type creationTreeToggle struct {
+	Goroutine *ptrace.Goroutine
+}
+
+type creationTreeRow struct {
+	g     *ptrace.Goroutine
+	depth int
+}
+
+type creationTreeList struct {
+	agg      *creationTreeAggregates
+	expanded map[*ptrace.Goroutine]bool
+	// The currently visible rows, in depth-first order
+	rows []creationTreeRow
+	list widget.List
+
+	toggles allocator[creationTreeToggle]
+	texts   allocator[Text]
+}
+
+func (tl *creationTreeList) rebuild() {
+	tl.rows = tl.rows[:0]
+	var add func(g *ptrace.Goroutine, depth int)
+	add = func(g *ptrace.Goroutine, depth int) {
+		tl.rows = append(tl.rows, creationTreeRow{g, depth})
+		if tl.expanded[g] {
+			for _, c := range g.Children {
+				add(c, depth+1)
+			}
+		}
+	}
+	for _, g := range tl.agg.Roots {
+		add(g, 0)
+	}
+}
+
+func (tl *creationTreeList) toggle(g *ptrace.Goroutine) {
+	tl.expanded[g] = !tl.expanded[g]
+	tl.rebuild()
+}
+
+// reveal expands g and all of its ancestors and scrolls to g.
+func (tl *creationTreeList) reveal(g *ptrace.Goroutine) {
+	for p := g; p != nil; p = p.Parent {
+		tl.expanded[p] = true
+	}
+	tl.rebuild()
+	for i, row := range tl.rows {
+		if row.g == g {
+			tl.list.Position.First = i
+			tl.list.Position.Offset = 0
+			break
+		}
+	}
+}
+
+func (tl *creationTreeList) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.creationTreeList.Layout").End()
+
+	tl.list.Axis = layout.Vertical
+	tl.toggles.Reset()
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < tl.texts.Len() {
+			txt = tl.texts.Ptr(txtCnt)
+		} else {
+			txt = tl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		r := tl.rows[row]
+		g := r.g
+		switch col {
+		case 0: // Goroutine
+			s := txt.Span(strings.Repeat("  ", r.depth))
+			s.Font.Variant = "Mono"
+			// Like theme.Foldable, we use [O] and [C] to show the state of the node, because the Go font has no
+			// suitable triangles.
+			if len(g.Children) == 0 {
+				s := txt.Span("    ")
+				s.Font.Variant = "Mono"
+			} else if tl.expanded[g] {
+				s := txt.Link("[O]", tl.toggles.Allocate(creationTreeToggle{g}))
+				s.Font.Variant = "Mono"
+				txt.Span(" ")
+			} else {
+				s := txt.Link("[C]", tl.toggles.Allocate(creationTreeToggle{g}))
+				s.Font.Variant = "Mono"
+				txt.Span(" ")
+			}
+			txt.Link(local.Sprintf("%d", g.ID), g)
+		case 1: // Function
+			txt.Link(g.Function.Fn, g.Function)
+		case 2: // Children
+			txt.Alignment = text.End
+			txt.Span(local.Sprintf("%d", len(g.Children)))
+		case 3: // Descendants
+			txt.Alignment = text.End
+			txt.Span(local.Sprintf("%d", tl.agg.Descendants[g.SeqID]))
+		case 4: // CPU time
+			layoutDuration(txt, tl.agg.CPUTime[g.SeqID])
+		case 5: // Subtree CPU time
+			layoutDuration(txt, tl.agg.SubtreeCPUTime[g.SeqID])
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       creationTreeColumns,
+		List:          &tl.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(tl.rows), cellFn)
+	tl.texts.Truncate(txtCnt)
+	return dims
+}
+
+// XXX the widths depend on the font and scaling
+var creationTreeColumns = []theme.TableListColumn{
+	{Name: "Goroutine", MinWidth: 300, MaxWidth: 300},
+	{Name: "Function", MinWidth: 500, MaxWidth: 500},
+	{Name: "Children", MinWidth: 100, MaxWidth: 100},
+	{Name: "Descendants", MinWidth: 120, MaxWidth: 120},
+	{Name: "CPU time", MinWidth: 150, MaxWidth: 150},
+	{Name: "Subtree CPU time", MinWidth: 160, MaxWidth: 160},
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (tl *creationTreeList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < tl.texts.Len(); i++ {
+		txt := tl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
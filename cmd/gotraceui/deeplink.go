@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+// A deepLink describes a location in a trace, as specified by command-line flags. It allows pasting locations into
+// tickets and chat messages.
+type deepLink struct {
+	// The time at the center of the view, relative to the start of the trace
+	at    time.Duration
+	hasAt bool
+	// The duration of the view. Zero keeps the current zoom level.
+	span time.Duration
+	// The goroutine whose timeline to scroll to
+	goroutine    uint64
+	hasGoroutine bool
+	// The kind of panel to open, using the same names as session files
+	panel string
+}
+
+// The link specified on the command line
+var cmdlineLink deepLink
+
+func registerDeepLinkFlags(fs *flag.FlagSet, dl *deepLink) {
+	fs.Func("at", "Center the view on this time since the start of the trace, e.g. 1.234s", func(s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		dl.at = d
+		dl.hasAt = true
+		return nil
+	})
+	fs.DurationVar(&dl.span, "span", 0, "Zoom so that the view covers this duration, e.g. 2ms")
+	fs.Func("goroutine", "Scroll to the timeline of the goroutine with this ID", func(s string) error {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		dl.goroutine = id
+		dl.hasGoroutine = true
+		return nil
+	})
+	fs.StringVar(&dl.panel, "panel", "", "Open a panel, e.g. goroutine, function, tasks or gc")
+}
+
+func (dl deepLink) isZero() bool {
+	return !dl.hasAt && dl.span == 0 && !dl.hasGoroutine && dl.panel == ""
+}
+
+func (dl deepLink) validate() error {
+	if dl.span < 0 {
+		return errors.New("-span must not be negative")
+	}
+	if (dl.panel == "goroutine" || dl.panel == "function") && !dl.hasGoroutine {
+		return fmt.Errorf("-panel %s requires -goroutine", dl.panel)
+	}
+	return nil
+}
+
+// commandLine returns the command line that opens tracePath at the location described by the link.
+func (dl deepLink) commandLine(tracePath string) string {
+	args := []string{"gotraceui"}
+	if dl.hasAt {
+		args = append(args, "-at", dl.at.String())
+	}
+	if dl.span != 0 {
+		args = append(args, "-span", dl.span.String())
+	}
+	if dl.hasGoroutine {
+		args = append(args, "-goroutine", strconv.FormatUint(dl.goroutine, 10))
+	}
+	if dl.panel != "" {
+		args = append(args, "-panel", dl.panel)
+	}
+	args = append(args, shellQuote(tracePath))
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes s for POSIX shells, if necessary.
+func shellQuote(s string) string {
+	safe := s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=+,@", r))
+	}) == -1
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// openDeepLinkPanel opens the panel requested by the link. It gets called once the trace has been loaded. Navigation
+// has to wait until the canvas has been laid out, because it depends on the canvas's width.
+func (mwin *MainWindow) openDeepLinkPanel(gtx layout.Context, dl deepLink) {
+	if mwin.state != "main" {
+		// Loading the trace failed.
+		return
+	}
+
+	mwin.pendingLink = &dl
+	if dl.panel == "" {
+		return
+	}
+
+	var g *ptrace.Goroutine
+	if dl.hasGoroutine {
+		var ok bool
+		g, ok = lookupGoroutine(mwin.trace, dl.goroutine)
+		if !ok {
+			// navigateToDeepLink reports the unknown goroutine.
+			return
+		}
+	}
+
+	switch dl.panel {
+	case "goroutine":
+		mwin.openGoroutine(g)
+	case "function":
+		mwin.openFunction(g.Function)
+	default:
+		// Other panels are opened for the whole trace.
+		if p, ok := (sessionPanel{Kind: dl.panel}).panel(mwin); ok {
+			mwin.openPanel(p)
+		} else {
+			mwin.twin.ShowNotification(gtx, fmt.Sprintf("Unknown panel %q", dl.panel))
+		}
+	}
+}
+
+// navigateToDeepLink scrolls and zooms to the location described by the link.
+func (mwin *MainWindow) navigateToDeepLink(gtx layout.Context, dl deepLink) {
+	cv := &mwin.canvas
+
+	nsPerPx := cv.nsPerPx
+	y := cv.y
+	if dl.span > 0 {
+		nsPerPx = float64(dl.span) / float64(cv.width)
+	}
+	center := cv.start + (cv.End()-cv.start)/2
+	if dl.hasAt {
+		center = trace.Timestamp(dl.at)
+	}
+	start := center - trace.Timestamp(nsPerPx*float64(cv.width)/2)
+
+	if dl.hasGoroutine {
+		g, ok := lookupGoroutine(mwin.trace, dl.goroutine)
+		if !ok {
+			mwin.twin.ShowNotification(gtx, local.Sprintf("Couldn't find goroutine %d", dl.goroutine))
+		} else {
+			y = cv.timelineY(gtx, g)
+		}
+	}
+
+	cv.navigateTo(gtx, start, nsPerPx, y)
+}
+
+// currentDeepLink returns a link describing the current view.
+func (mwin *MainWindow) currentDeepLink() deepLink {
+	cv := &mwin.canvas
+	start, end := cv.start, cv.End()
+	dl := deepLink{
+		at:    time.Duration(start + (end-start)/2),
+		hasAt: true,
+		span:  time.Duration(end - start),
+	}
+
+	// Use the goroutine displayed in the panel, or else the goroutine whose timeline is at the top of the canvas.
+	if sp, ok := newSessionPanel(mwin.panel); ok {
+		switch {
+		case sp.Kind == "goroutine":
+			dl.panel = sp.Kind
+			dl.goroutine = sp.ID
+			dl.hasGoroutine = true
+		case sp.ID == 0 && sp.Name == "":
+			// Panels about specific functions, tasks or GC cycles can't be expressed by flags.
+			dl.panel = sp.Kind
+		}
+	}
+	if !dl.hasGoroutine {
+		i := sort.Search(len(cv.timelineEnds), func(i int) bool {
+			return cv.timelineEnds[i] > cv.y
+		})
+		if i < len(cv.timelines) {
+			if g, ok := cv.timelines[i].item.(*ptrace.Goroutine); ok {
+				dl.goroutine = g.ID
+				dl.hasGoroutine = true
+			}
+		}
+	}
+	return dl
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellQuote(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"trace.out", "trace.out"},
+		{"/tmp/traces/a-b_c,d@e+f=g:h", "/tmp/traces/a-b_c,d@e+f=g:h"},
+		{"", "''"},
+		{"my trace", "'my trace'"},
+		{"$HOME/trace", "'$HOME/trace'"},
+		{"*.out", "'*.out'"},
+		{"it's", `'it'\''s'`},
+		{"träce", "'träce'"},
+	} {
+		if got := shellQuote(test.in); got != test.want {
+			t.Errorf("shellQuote(%q): got %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestDeepLinkCommandLine(t *testing.T) {
+	for _, test := range []struct {
+		link deepLink
+		path string
+		want string
+	}{
+		{deepLink{}, "trace.out", "gotraceui trace.out"},
+		{deepLink{}, "my trace.out", "gotraceui 'my trace.out'"},
+		{deepLink{at: 0, hasAt: true}, "trace.out", "gotraceui -at 0s trace.out"},
+		{
+			deepLink{at: 1234 * time.Millisecond, hasAt: true, span: 2 * time.Millisecond, goroutine: 0, hasGoroutine: true, panel: "goroutine"},
+			"/tmp/trace.out",
+			"gotraceui -at 1.234s -span 2ms -goroutine 0 -panel goroutine /tmp/trace.out",
+		},
+		{deepLink{panel: "gc"}, "trace.out", "gotraceui -panel gc trace.out"},
+	} {
+		got := test.link.commandLine(test.path)
+		if got != test.want {
+			t.Errorf("%+v.commandLine(%q): got %s, want %s", test.link, test.path, got, test.want)
+			continue
+		}
+
+		// The command line has to round-trip through the flags that it was built for.
+		var dl deepLink
+		fs := flag.NewFlagSet("gotraceui", flag.ContinueOnError)
+		registerDeepLinkFlags(fs, &dl)
+		args := strings.Fields(got)[1:]
+		if err := fs.Parse(args); err != nil {
+			t.Errorf("%s: couldn't parse flags: %s", got, err)
+			continue
+		}
+		if dl != test.link {
+			t.Errorf("%s: got %+v, want %+v", got, dl, test.link)
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"regexp"
 	"sort"
+	"strings"
+	"time"
 
 	"honnef.co/go/gotraceui/layout"
 	"honnef.co/go/gotraceui/theme"
 	"honnef.co/go/gotraceui/trace"
 	"honnef.co/go/gotraceui/trace/ptrace"
 	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
 )
 
 type FilterMode uint8
@@ -23,6 +28,35 @@ type Filter struct {
 	// Bitmap of ptrace.SchedulingState
 	States uint64
 
+	// Highlight goroutine spans that have all of these tags
+	Tags ptrace.SpanTags
+
+	// Highlight spans whose durations are within these bounds. A zero bound is unbounded.
+	Duration struct {
+		Min time.Duration
+		Max time.Duration
+	}
+
+	// Filters on the stacks of goroutine spans. Spans match if any frame, at any depth, matches all non-nil patterns.
+	Stack struct {
+		Function *regexp.Regexp
+		File     *regexp.Regexp
+	}
+
+	// Filters specific to user region tracks
+	Region struct {
+		// Highlight user regions whose names match this pattern
+		Name *regexp.Regexp
+	}
+
+	// Filters specific to goroutine timelines
+	Goroutine struct {
+		// Highlight spans of the goroutine with this ID
+		ID uint64
+		// Highlight spans of goroutines whose function names contain this string
+		Function string
+	}
+
 	// Filters specific to processor timelines
 	Processor struct {
 		// Highlight processor spans for this goroutine
@@ -38,11 +72,14 @@ type Filter struct {
 	}
 }
 
+// HasState reports whether the filter matches the state.
 func (f Filter) HasState(state ptrace.SchedulingState) bool {
 	return f.States&(1<<state) != 0
 }
 
-func (f Filter) Match(spans ptrace.Spans, container SpanContainer) (out bool) {
+// Match reports whether the filter matches the spans. User-defined states also match if the filter matches the states
+// they refine in tr.
+func (f Filter) Match(tr *ptrace.Trace, spans ptrace.Spans, container SpanContainer) (out bool) {
 	if !f.couldMatch(spans, container) {
 		return false
 	}
@@ -55,13 +92,102 @@ func (f Filter) Match(spans ptrace.Spans, container SpanContainer) (out bool) {
 
 			for i := 0; i < spans.Len(); i++ {
 				s := spans.At(i)
-				if f.HasState(s.State) {
+				if f.HasState(s.State) || f.HasState(tr.BaseState(s.State)) {
+					return true, false
+				}
+			}
+			return false, false
+		},
+
+		func() (bool, bool) {
+			if f.Tags == 0 {
+				return false, true
+			}
+
+			if _, ok := container.Timeline.item.(*ptrace.Goroutine); !ok || container.Track.kind != TrackKindUnspecified {
+				return false, false
+			}
+
+			for i := 0; i < spans.Len(); i++ {
+				if spans.At(i).Tags&f.Tags == f.Tags {
+					return true, false
+				}
+			}
+			return false, false
+		},
+
+		func() (bool, bool) {
+			if f.Duration.Min == 0 && f.Duration.Max == 0 {
+				return false, true
+			}
+
+			for i := 0; i < spans.Len(); i++ {
+				d := spans.AtPtr(i).Duration()
+				if d >= f.Duration.Min && (f.Duration.Max == 0 || d <= f.Duration.Max) {
 					return true, false
 				}
 			}
 			return false, false
 		},
 
+		func() (bool, bool) {
+			if f.Stack.Function == nil && f.Stack.File == nil {
+				return false, true
+			}
+
+			if _, ok := container.Timeline.item.(*ptrace.Goroutine); !ok || container.Track.kind != TrackKindUnspecified {
+				return false, false
+			}
+
+			for i := 0; i < spans.Len(); i++ {
+				ev := tr.Event(spans.AtPtr(i).Event)
+				for _, pc := range tr.Stacks[ev.StkID] {
+					frame := tr.PCs[pc]
+					if (f.Stack.Function == nil || f.Stack.Function.MatchString(frame.Fn)) &&
+						(f.Stack.File == nil || f.Stack.File.MatchString(frame.File)) {
+						return true, false
+					}
+				}
+			}
+			return false, false
+		},
+
+		func() (bool, bool) {
+			if f.Region.Name == nil {
+				return false, true
+			}
+
+			if container.Track.kind != TrackKindUserRegions {
+				return false, false
+			}
+
+			for i := 0; i < spans.Len(); i++ {
+				ev := tr.Event(spans.AtPtr(i).Event)
+				if f.Region.Name.MatchString(tr.Strings[ev.Args[trace.ArgUserRegionTypeID]]) {
+					return true, false
+				}
+			}
+			return false, false
+		},
+
+		func() (bool, bool) {
+			if f.Goroutine.ID == 0 && f.Goroutine.Function == "" {
+				return false, true
+			}
+
+			g, ok := container.Timeline.item.(*ptrace.Goroutine)
+			if !ok {
+				return false, false
+			}
+			if f.Goroutine.ID != 0 && g.ID != f.Goroutine.ID {
+				return false, false
+			}
+			if f.Goroutine.Function != "" && !strings.Contains(g.Function.Fn, f.Goroutine.Function) {
+				return false, false
+			}
+			return true, false
+		},
+
 		func() (bool, bool) {
 			if f.Processor.StartAfter == 0 && f.Processor.EndBefore == 0 {
 				return false, true
@@ -97,7 +223,7 @@ func (f Filter) Match(spans ptrace.Spans, container SpanContainer) (out bool) {
 					// merged span as a whole, which means that finding some spans with the right goroutine and some
 					// spans with the time range would allow the merged span to match, even if the two sets of spans
 					// didn't intersect.
-					if container.Timeline.cv.trace.Event(span.Event).G != f.Processor.Goroutine {
+					if tr.Event(span.Event).G != f.Processor.Goroutine {
 						continue
 					}
 				}
@@ -113,7 +239,6 @@ func (f Filter) Match(spans ptrace.Spans, container SpanContainer) (out bool) {
 		func() (bool, bool) {
 			if f.Processor.Goroutine != 0 {
 				if _, ok := container.Timeline.item.(*ptrace.Processor); ok {
-					tr := container.Timeline.cv.trace
 					for i := 0; i < spans.Len(); i++ {
 						s := spans.At(i)
 						g := tr.G(tr.Event(s.Event).G)
@@ -132,7 +257,6 @@ func (f Filter) Match(spans ptrace.Spans, container SpanContainer) (out bool) {
 		func() (bool, bool) {
 			if f.Machine.Processor != 0 {
 				if _, ok := container.Timeline.item.(*ptrace.Machine); ok {
-					tr := container.Timeline.cv.trace
 					for i := 0; i < spans.Len(); i++ {
 						s := spans.At(i)
 						p := tr.P(tr.Event(s.Event).P)
@@ -192,9 +316,22 @@ func (f Filter) couldMatch(spans ptrace.Spans, container SpanContainer) bool {
 
 	b := f.couldMatchState(spans, container)
 	b = b || f.couldMatchProcessor(spans, container)
+	b = b || f.couldMatchTags(spans, container)
+	b = b || f.couldMatchSpans(spans, container)
 	return b
 }
 
+// couldMatchSpans checks the filters that apply to spans of all kinds of timelines and tracks.
+func (f Filter) couldMatchSpans(spans ptrace.Spans, container SpanContainer) bool {
+	if f.Duration.Min != 0 || f.Duration.Max != 0 {
+		return true
+	}
+	if f.Region.Name != nil && container.Track.kind == TrackKindUserRegions {
+		return true
+	}
+	return false
+}
+
 func (f Filter) couldMatchProcessor(spans ptrace.Spans, container SpanContainer) bool {
 	switch container.Timeline.item.(type) {
 	case *ptrace.Processor:
@@ -204,7 +341,20 @@ func (f Filter) couldMatchProcessor(spans ptrace.Spans, container SpanContainer)
 	}
 }
 
+func (f Filter) couldMatchTags(spans ptrace.Spans, container SpanContainer) bool {
+	if f.Tags == 0 {
+		return false
+	}
+	_, ok := container.Timeline.item.(*ptrace.Goroutine)
+	return ok && container.Track.kind == TrackKindUnspecified
+}
+
 func (f Filter) couldMatchState(spans ptrace.Spans, container SpanContainer) bool {
+	if f.States == 0 {
+		// The filter doesn't constrain states, so only the other filters can rule out the spans.
+		return true
+	}
+
 	switch item := container.Timeline.item.(type) {
 	case *ptrace.Processor:
 		return f.HasState(ptrace.StateRunningG)
@@ -232,19 +382,36 @@ func (f Filter) couldMatchState(spans ptrace.Spans, container SpanContainer) boo
 
 type HighlightDialogStyle struct {
 	Filter *Filter
-
-	bits [ptrace.StateLast]widget.BackedBit[uint64]
+	Trace  *Trace
+
+	bits     [ptrace.StateLast]widget.BackedBit[uint64]
+	tagBits  [16]widget.BackedBit[ptrace.SpanTags]
+	matchAll widget.Bool
+
+	editors struct {
+		minDuration   widget.Editor
+		maxDuration   widget.Editor
+		stackFunction widget.Editor
+		stackFile     widget.Editor
+		regionName    widget.Editor
+	}
 
 	list      widget.List
 	foldables struct {
-		states widget.Bool
+		states   widget.Bool
+		tags     widget.Bool
+		duration widget.Bool
+		stack    widget.Bool
+		regions  widget.Bool
 	}
 	stateClickables []widget.Clickable
+	tagsClickable   widget.Clickable
 }
 
-func HighlightDialog(win *theme.Window, f *Filter) HighlightDialogStyle {
+func HighlightDialog(win *theme.Window, tr *Trace, f *Filter) HighlightDialogStyle {
 	hd := HighlightDialogStyle{
 		Filter: f,
+		Trace:  tr,
 	}
 	hd.list.Axis = layout.Vertical
 
@@ -252,14 +419,168 @@ func HighlightDialog(win *theme.Window, f *Filter) HighlightDialogStyle {
 		hd.bits[i].Bits = &f.States
 		hd.bits[i].Bit = i
 	}
+	for i := range hd.tagBits {
+		hd.tagBits[i].Bits = &f.Tags
+		hd.tagBits[i].Bit = i
+	}
+	hd.matchAll.Value = f.Mode == FilterModeAnd
+
+	for _, ed := range []*widget.Editor{
+		&hd.editors.minDuration,
+		&hd.editors.maxDuration,
+		&hd.editors.stackFunction,
+		&hd.editors.stackFile,
+		&hd.editors.regionName,
+	} {
+		ed.SingleLine = true
+	}
+	if f.Duration.Min != 0 {
+		hd.editors.minDuration.SetText(f.Duration.Min.String())
+	}
+	if f.Duration.Max != 0 {
+		hd.editors.maxDuration.SetText(f.Duration.Max.String())
+	}
+	if f.Stack.Function != nil {
+		hd.editors.stackFunction.SetText(f.Stack.Function.String())
+	}
+	if f.Stack.File != nil {
+		hd.editors.stackFile.SetText(f.Stack.File.String())
+	}
+	if f.Region.Name != nil {
+		hd.editors.regionName.SetText(f.Region.Name.String())
+	}
 
-	hd.stateClickables = make([]widget.Clickable, 3)
+	hd.stateClickables = make([]widget.Clickable, 4)
 
 	return hd
 }
 
+func validateDuration(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, err := time.ParseDuration(s)
+	return err == nil
+}
+
+func validateRegexp(s string) bool {
+	_, err := regexp.Compile(s)
+	return err == nil
+}
+
+// update applies the contents of the text fields to the filter. Fields that don't contain valid values keep their
+// previous values.
+func (hd *HighlightDialogStyle) update() {
+	f := hd.Filter
+	parseDuration := func(ed *widget.Editor, dst *time.Duration) {
+		if s := ed.Text(); s == "" {
+			*dst = 0
+		} else if d, err := time.ParseDuration(s); err == nil {
+			*dst = d
+		}
+	}
+	parseRegexp := func(ed *widget.Editor, dst **regexp.Regexp) {
+		if s := ed.Text(); s == "" {
+			*dst = nil
+		} else if re, err := regexp.Compile(s); err == nil {
+			*dst = re
+		}
+	}
+	parseDuration(&hd.editors.minDuration, &f.Duration.Min)
+	parseDuration(&hd.editors.maxDuration, &f.Duration.Max)
+	parseRegexp(&hd.editors.stackFunction, &f.Stack.Function)
+	parseRegexp(&hd.editors.stackFile, &f.Stack.File)
+	parseRegexp(&hd.editors.regionName, &f.Region.Name)
+}
+
 func (hd *HighlightDialogStyle) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
-	return theme.List(win.Theme, &hd.list).Layout(gtx, 1, func(gtx layout.Context, index int) layout.Dimensions {
+	changed := false
+	for _, ed := range []*widget.Editor{
+		&hd.editors.minDuration,
+		&hd.editors.maxDuration,
+		&hd.editors.stackFunction,
+		&hd.editors.stackFile,
+		&hd.editors.regionName,
+	} {
+		for _, ev := range ed.Events() {
+			if _, ok := ev.(widget.ChangeEvent); ok {
+				changed = true
+			}
+		}
+	}
+	if changed {
+		hd.update()
+	}
+	if hd.matchAll.Changed() {
+		if hd.matchAll.Value {
+			hd.Filter.Mode = FilterModeAnd
+		} else {
+			hd.Filter.Mode = FilterModeOr
+		}
+	}
+
+	textField := func(ed *widget.Editor, label, hint string, validate func(string) bool) layout.Widget {
+		return func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.X = gtx.Dp(150)
+					return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, label, widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+				}),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					tb := theme.TextBox(win.Theme, ed, hint)
+					tb.Validate = validate
+					return tb.Layout(gtx)
+				}),
+			)
+		}
+	}
+
+	sections := []layout.Widget{
+		theme.Dumb(win, theme.CheckBox(win.Theme, &hd.matchAll, "Spans have to match all criteria").Layout),
+		hd.layoutStates(win),
+		func(gtx layout.Context) layout.Dimensions {
+			return theme.Foldable(win.Theme, &hd.foldables.tags, "Tags").Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				var boxes []theme.CheckBoxStyle
+				for i := range hd.tagBits {
+					if names := spanTagStrings(hd.Trace, 1<<i); len(names) == 1 {
+						boxes = append(boxes, theme.CheckBox(win.Theme, &hd.tagBits[i], names[0]))
+					}
+				}
+				return theme.CheckBoxGroup(win.Theme, &hd.tagsClickable, "Spans have all of").Layout(win, gtx, boxes...)
+			})
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return theme.Foldable(win.Theme, &hd.foldables.duration, "Duration").Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(textField(&hd.editors.minDuration, "At least", "e.g. 1ms", validateDuration)),
+					layout.Rigid(layout.Spacer{Height: 5}.Layout),
+					layout.Rigid(textField(&hd.editors.maxDuration, "At most", "e.g. 1s", validateDuration)),
+				)
+			})
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return theme.Foldable(win.Theme, &hd.foldables.stack, "Stack").Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(textField(&hd.editors.stackFunction, "Function", `Regular expression, e.g. ^sync\.\(\*Mutex\)\.Lock$`, validateRegexp)),
+					layout.Rigid(layout.Spacer{Height: 5}.Layout),
+					layout.Rigid(textField(&hd.editors.stackFile, "File", "Regular expression, e.g. /internal/db/", validateRegexp)),
+				)
+			})
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return theme.Foldable(win.Theme, &hd.foldables.regions, "User regions").Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				return textField(&hd.editors.regionName, "Name", "Regular expression", validateRegexp)(gtx)
+			})
+		},
+	}
+
+	return theme.List(win.Theme, &hd.list).Layout(gtx, len(sections), func(gtx layout.Context, index int) layout.Dimensions {
+		return layout.Inset{Bottom: 5}.Layout(gtx, sections[index])
+	})
+}
+
+func (hd *HighlightDialogStyle) layoutStates(win *theme.Window) layout.Widget {
+	return func(gtx layout.Context) layout.Dimensions {
 		return theme.Foldable(win.Theme, &hd.foldables.states, "States").Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
 			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
@@ -296,7 +617,19 @@ func (hd *HighlightDialogStyle) Layout(win *theme.Window, gtx layout.Context) la
 						theme.CheckBox(win.Theme, &hd.bits[ptrace.StateBlockedSyscall], stateNamesCapitalized[ptrace.StateBlockedSyscall]),
 					)
 				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					var boxes []theme.CheckBoxStyle
+					for state := ptrace.StateUser0; state < ptrace.StateLast; state++ {
+						if name, ok := hd.Trace.userStateName(state); ok {
+							boxes = append(boxes, theme.CheckBox(win.Theme, &hd.bits[state], name))
+						}
+					}
+					if len(boxes) == 0 {
+						return layout.Dimensions{}
+					}
+					return theme.CheckBoxGroup(win.Theme, &hd.stateClickables[3], "User-defined").Layout(win, gtx, boxes...)
+				}),
 			)
 		})
-	})
+	}
 }
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"math"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+
+	"gioui.org/f32"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+)
+
+const (
+	flowWidthDp     unit.Dp = 1.5
+	flowHeadSizeDp  unit.Dp = 6
+	maxDisplayFlows         = 1000
+)
+
+type flowKind uint8
+
+const (
+	flowKindUnblock flowKind = iota
+	flowKindCreate
+)
+
+// A flow connects an event on one goroutine with the goroutine that it made runnable, either by unblocking or by
+// creating it.
+type flow struct {
+	kind     flowKind
+	from, to *ptrace.Goroutine
+	// When the event happened on from and when the resulting span started on to
+	fromTs, toTs trace.Timestamp
+}
+
+// incomingFlow returns the flow that ended span s of goroutine g, if any. That is either the unblocking of a blocked
+// span, or the creation of the goroutine.
+func incomingFlow(tr *Trace, g *ptrace.Goroutine, s ptrace.Span) (flow, bool) {
+	ev := tr.Event(s.Event)
+	if tr.BaseState(s.State) == ptrace.StateCreated {
+		if ev.Type != trace.EvGoCreate || ev.G == 0 {
+			// Goroutines that existed before the trace started have no creator.
+			return flow{}, false
+		}
+		return flow{kind: flowKindCreate, from: tr.G(ev.G), to: g, fromTs: ev.Ts, toTs: s.Start}, true
+	}
+
+	gid, ok := unblockedByGoroutine(tr, s)
+	if !ok {
+		return flow{}, false
+	}
+	return flow{kind: flowKindUnblock, from: tr.G(gid), to: g, fromTs: tr.Event(ptrace.EventID(ev.Link)).Ts, toTs: s.End}, true
+}
+
+// outgoingFlows appends to out the flows caused by goroutine g during spans.
+func outgoingFlows(tr *Trace, g *ptrace.Goroutine, spans ptrace.Spans, out []flow) []flow {
+	for _, evID := range ptrace.Events(spans, g.Events, tr.Trace) {
+		ev := tr.Event(evID)
+		var f flow
+		switch ev.Type {
+		case trace.EvGoUnblock:
+			f.kind = flowKindUnblock
+			f.to = tr.G(ev.Args[trace.ArgGoUnblockG])
+		case trace.EvGoCreate:
+			f.kind = flowKindCreate
+			f.to = tr.G(ev.Args[trace.ArgGoCreateG])
+		default:
+			continue
+		}
+		f.from = g
+		f.fromTs = ev.Ts
+		f.toTs = ev.Ts
+		out = append(out, f)
+	}
+	return out
+}
+
+// spanFlows appends to out all flows that start or end in spans of goroutine g.
+func spanFlows(tr *Trace, g *ptrace.Goroutine, spans ptrace.Spans, out []flow) []flow {
+	for i := 0; i < spans.Len(); i++ {
+		if f, ok := incomingFlow(tr, g, spans.At(i)); ok {
+			out = append(out, f)
+		}
+	}
+	return outgoingFlows(tr, g, spans, out)
+}
+
+func (cv *Canvas) ToggleFlowArrows() {
+	cv.timeline.displayAllFlows = !cv.timeline.displayAllFlows
+}
+
+// collectFlows computes the flows to display. These are the flows of the hovered or selected spans, and if enabled, the
+// flows that end in any of the visible goroutine timelines.
+func (cv *Canvas) collectFlows() []flow {
+	out := cv.flows.displayed[:0]
+
+	if h := cv.timeline.hoveredTimeline; h != nil && cv.timeline.hoveredSpans.Len() != 0 {
+		if g, ok := h.item.(*ptrace.Goroutine); ok {
+			out = spanFlows(cv.trace, g, cv.timeline.hoveredSpans, out)
+		}
+	}
+	if cv.flows.selected != nil {
+		out = spanFlows(cv.trace, cv.flows.selectedGoroutine, cv.flows.selected, out)
+	}
+
+	if cv.timeline.displayAllFlows {
+	tlLoop:
+		for _, tl := range cv.prevFrame.displayedTls {
+			g, ok := tl.item.(*ptrace.Goroutine)
+			if !ok {
+				continue
+			}
+			spans := cv.visibleSpans(g.Spans)
+			for i := 0; i < spans.Len(); i++ {
+				if len(out) >= maxDisplayFlows {
+					break tlLoop
+				}
+				if f, ok := incomingFlow(cv.trace, g, spans.At(i)); ok {
+					out = append(out, f)
+				}
+			}
+		}
+	}
+
+	cv.flows.displayed = out
+	return out
+}
+
+// goroutineTrackY returns the Y offset, relative to the visible portion of the canvas, of the middle of the first
+// track of g's timeline.
+func (cv *Canvas) goroutineTrackY(gtx layout.Context, g *ptrace.Goroutine) (float32, bool) {
+	var y int
+	if i := cv.pinnedIndex(g); i != -1 {
+		if i > 0 {
+			y = cv.pinnedEnds[i-1]
+		}
+		if y >= cv.pinnedHeight {
+			// The timeline didn't fit in the pinned area.
+			return 0, false
+		}
+	} else {
+		if g.SeqID >= len(cv.goroutineTimelines) {
+			return 0, false
+		}
+		i := cv.goroutineTimelines[g.SeqID]
+		if i == -1 || i > len(cv.timelineEnds) {
+			return 0, false
+		}
+		y = cv.pinnedHeight - cv.y
+		if i > 0 {
+			y += cv.timelineEnds[i-1]
+		}
+	}
+	if !cv.timeline.compact {
+		y += gtx.Dp(timelineLabelHeightDp)
+	}
+	return float32(y) + float32(gtx.Dp(timelineTrackHeightDp))/2, true
+}
+
+// pinnedIndex returns the index of g's timeline among the pinned timelines, or -1 if it isn't pinned.
+func (cv *Canvas) pinnedIndex(g *ptrace.Goroutine) int {
+	if len(cv.pinnedEnds) != len(cv.pinnedTimelines) {
+		return -1
+	}
+	for i, tl := range cv.pinnedTimelines {
+		if tl.item == g {
+			return i
+		}
+	}
+	return -1
+}
+
+// A flowArrow is the geometry of a flow's arrow in pixels.
+type flowArrow struct {
+	kind flowKind
+	// The start of the line, the base of the arrow head, and the arrow head's three corners
+	from, base, tip, left, right f32.Point
+}
+
+// drawFlows draws arrows for the flows from their causing events to the spans they made runnable.
+func (cv *Canvas) drawFlows(gtx layout.Context) {
+	flows := cv.collectFlows()
+	if len(flows) == 0 {
+		return
+	}
+
+	headSize := float32(gtx.Dp(flowHeadSizeDp))
+	arrows := cv.flows.arrows[:0]
+	for _, f := range flows {
+		y1, ok1 := cv.goroutineTrackY(gtx, f.from)
+		y2, ok2 := cv.goroutineTrackY(gtx, f.to)
+		if !ok1 || !ok2 || y1 == y2 {
+			continue
+		}
+		from := f32.Pt(cv.tsToPx(f.fromTs), y1)
+		tip := f32.Pt(cv.tsToPx(f.toTs), y2)
+
+		// Stop the line at the base of the arrow head.
+		dx, dy := tip.X-from.X, tip.Y-from.Y
+		length := float32(math.Hypot(float64(dx), float64(dy)))
+		ux, uy := dx/length, dy/length
+		base := f32.Pt(tip.X-ux*headSize, tip.Y-uy*headSize)
+
+		arrows = append(arrows, flowArrow{
+			kind:  f.kind,
+			from:  from,
+			base:  base,
+			tip:   tip,
+			left:  f32.Pt(base.X-uy*headSize/2, base.Y+ux*headSize/2),
+			right: f32.Pt(base.X+uy*headSize/2, base.Y-ux*headSize/2),
+		})
+	}
+	cv.flows.arrows = arrows
+
+	width := float32(gtx.Dp(flowWidthDp))
+	for kind, c := range [...]colorIndex{flowKindUnblock: colorFlowUnblock, flowKindCreate: colorFlowCreate} {
+		var n int
+		var lines clip.Path
+		lines.Begin(gtx.Ops)
+		for _, a := range arrows {
+			if a.kind != flowKind(kind) {
+				continue
+			}
+			lines.MoveTo(a.from)
+			lines.LineTo(a.base)
+			n++
+		}
+		if n == 0 {
+			lines.End()
+			continue
+		}
+		paint.FillShape(gtx.Ops, colors[c], clip.Stroke{Path: lines.End(), Width: width}.Op())
+
+		var heads clip.Path
+		heads.Begin(gtx.Ops)
+		for _, a := range arrows {
+			if a.kind != flowKind(kind) {
+				continue
+			}
+			heads.MoveTo(a.tip)
+			heads.LineTo(a.left)
+			heads.LineTo(a.right)
+			heads.Close()
+		}
+		paint.FillShape(gtx.Ops, colors[c], clip.Outline{Path: heads.End()}.Op())
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"gioui.org/font"
 	"gioui.org/op"
 	"gioui.org/text"
+	"golang.org/x/exp/slices"
 )
 
 type FunctionLink struct {
@@ -39,6 +40,11 @@ type FunctionInfo struct {
 	filterGoroutines widget.Bool
 	histGoroutines   []*ptrace.Goroutine
 	hist             InteractiveHistogram
+	latencyHist      InteractiveHistogram
+
+	// livePlot plots the number of goroutines of this function that are alive over time.
+	livePlot   *Plot
+	togglePlot widget.PrimaryClickable
 
 	theme.PanelButtons
 }
@@ -109,12 +115,34 @@ func NewFunctionInfo(mwin *MainWindow, fn *ptrace.Function) *FunctionInfo {
 			Value: value(tb.Span(total.String())),
 		})
 
+		var latencies []time.Duration
+		for _, g := range fn.Goroutines {
+			if d, ok := goroutineStartLatency(fi.mwin.trace, g); ok {
+				latencies = append(latencies, d)
+			}
+		}
+		if len(latencies) != 0 {
+			slices.Sort(latencies)
+			attrs = append(attrs, DescriptionAttribute{
+				Key:   "Start latency (p50 / p99)",
+				Value: value(tb.Span(local.Sprintf("%s / %s", roundDuration(percentile(latencies, 0.5)), roundDuration(percentile(latencies, 0.99))))),
+			})
+		}
+
+		fi.livePlot = computeLiveGoroutinesPlot(fn)
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Max. live goroutines",
+			Value: value(tb.Span(local.Sprintf("%d", fi.livePlot.max))),
+		})
+
 		fi.description.Attributes = attrs
 	}
 
-	// Build histogram
+	// Build histograms
 	cfg := &widget.HistogramConfig{RejectOutliers: true, Bins: widget.DefaultHistogramBins}
 	fi.computeHistogram(mwin.twin, cfg)
+	fi.latencyHist.Config = widget.HistogramConfig{RejectOutliers: true, Bins: widget.DefaultHistogramBins}
+	fi.computeLatencyHistogram(mwin.twin)
 
 	return fi
 }
@@ -135,11 +163,18 @@ func (fi *FunctionInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dim
 		return layout.Dimensions{Size: gtx.Constraints.Min}
 	}
 
-	tabs := []string{"Goroutines", "Histogram"}
+	tabs := []string{"Goroutines", "Lifetime histogram", "Start latency histogram"}
 
 	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					label := "Show live goroutines on canvas"
+					if fi.mwin.canvas.HasPlot(fi.livePlot) {
+						label = "Hide live goroutines from canvas"
+					}
+					return theme.Button(win.Theme, &fi.togglePlot.Clickable, label).Layout(win, gtx)
+				}),
 				layout.Flexed(1, nothing),
 				layout.Rigid(theme.Dumb(win, fi.PanelButtons.Layout)),
 			)
@@ -171,8 +206,10 @@ func (fi *FunctionInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dim
 							return fi.goroutineList.Layout(win, gtx, gs)
 						}),
 					)
-				case "Histogram":
+				case "Lifetime histogram":
 					return fi.hist.Layout(win, gtx)
+				case "Start latency histogram":
+					return fi.latencyHist.Layout(win, gtx)
 				default:
 					panic("unreachable")
 				}
@@ -192,13 +229,99 @@ func (fi *FunctionInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dim
 		fi.mwin.prevPanel()
 	}
 
+	for fi.togglePlot.Clicked() {
+		if fi.mwin.canvas.HasPlot(fi.livePlot) {
+			fi.mwin.canvas.RemovePlot(fi.livePlot)
+		} else {
+			fi.mwin.canvas.AddPlot(fi.livePlot)
+		}
+	}
+
 	if fi.hist.Changed() {
 		fi.histGoroutines = fi.computeHistogram(win, &fi.hist.Config)
 	}
+	if fi.latencyHist.Changed() {
+		fi.computeLatencyHistogram(win)
+	}
 
 	return dims
 }
 
+// goroutineStartLatency returns the time between a goroutine's creation and it first running. It returns false if the
+// goroutine was created before the trace started or never ran.
+func goroutineStartLatency(tr *Trace, g *ptrace.Goroutine) (time.Duration, bool) {
+	if g.Spans.At(0).State != ptrace.StateCreated {
+		return 0, false
+	}
+	created := g.Spans.At(0).Start
+	for i := 1; i < g.Spans.Len(); i++ {
+		if s := g.Spans.AtPtr(i); tr.BaseState(s.State) == ptrace.StateActive {
+			return time.Duration(s.Start - created), true
+		}
+	}
+	return 0, false
+}
+
+// computeLiveGoroutinesPlot computes a plot of the number of goroutines of a function that are alive over time.
+// Goroutines that exist at the start of the trace are counted from the beginning of the trace, and goroutines that
+// don't return are counted until the end of the trace.
+func computeLiveGoroutinesPlot(fn *ptrace.Function) *Plot {
+	type delta struct {
+		when trace.Timestamp
+		d    int
+	}
+	deltas := make([]delta, 0, 2*len(fn.Goroutines))
+	for _, g := range fn.Goroutines {
+		deltas = append(deltas, delta{g.Spans.At(0).Start, 1})
+		if last := LastSpan(g.Spans); last.State == ptrace.StateDone {
+			deltas = append(deltas, delta{last.End, -1})
+		}
+	}
+	slices.SortFunc(deltas, func(a, b delta) bool {
+		return a.when < b.when
+	})
+
+	points := []ptrace.Point{{When: 0, Value: 0}}
+	var n int
+	for _, d := range deltas {
+		n += d.d
+		if last := &points[len(points)-1]; last.When == d.when {
+			// Collapse changes at the same timestamp into a single point.
+			last.Value = uint64(n)
+		} else {
+			points = append(points, ptrace.Point{When: d.when, Value: uint64(n)})
+		}
+	}
+
+	pl := &Plot{
+		Name: local.Sprintf("Live goroutines: %s", fn.Fn),
+		Unit: "goroutines",
+	}
+	pl.AddSeries(PlotSeries{
+		Name:   "Live goroutines",
+		Points: points,
+		Filled: true,
+		Color:  colors[colorStateActive],
+	})
+	return pl
+}
+
+func (fi *FunctionInfo) computeLatencyHistogram(win *theme.Window) {
+	cfg := &fi.latencyHist.Config
+	var latencies []time.Duration
+	for _, g := range fi.fn.Goroutines {
+		d, ok := goroutineStartLatency(fi.mwin.trace, g)
+		if !ok {
+			continue
+		}
+		if fd := widget.FloatDuration(d); fd >= cfg.Start && (cfg.End == 0 || fd <= cfg.End) {
+			latencies = append(latencies, d)
+		}
+	}
+
+	fi.latencyHist.Set(win, latencies)
+}
+
 func (fi *FunctionInfo) computeHistogram(win *theme.Window, cfg *widget.HistogramConfig) []*ptrace.Goroutine {
 	var goroutineDurations []time.Duration
 
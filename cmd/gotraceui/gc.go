@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	rtrace "runtime/trace"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/op"
+	"gioui.org/text"
+	"golang.org/x/exp/constraints"
+	"golang.org/x/exp/slices"
+)
+
+// formatBytes formats n using binary prefixes, returning the value and unit separately so that tables can align them,
+// like durationNumberFormat.format does for durations.
+func formatBytes(n uint64) (value string, unit string) {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return local.Sprintf("%d", n), "B"
+	}
+	v := float64(n)
+	i := -1
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.2f", v), string(units[i]) + "iB"
+}
+
+type gcColumn struct {
+	name  string
+	width int
+	// Exactly one of duration, bytes and number is set, and is used both for displaying and sorting the column.
+	duration func(c *ptrace.GCCycle) time.Duration
+	bytes    func(c *ptrace.GCCycle) uint64
+	number   func(c *ptrace.GCCycle) int64
+}
+
+// XXX the widths depend on the font and scaling
+var gcColumns = [...]gcColumn{
+	{name: "Cycle", width: 100, number: func(c *ptrace.GCCycle) int64 { return int64(c.Seq) }},
+	// Sorting by start time is the same as sorting by sequence number, but the column is more useful for navigating.
+	{name: "Start", width: 200, number: func(c *ptrace.GCCycle) int64 { return int64(c.Span.Start) }},
+	{name: "Duration", width: 150, duration: func(c *ptrace.GCCycle) time.Duration { return c.Span.Duration() }},
+	{name: "STW", width: 150, duration: (*ptrace.GCCycle).STWDuration},
+	{name: "Mark assist", width: 150, duration: func(c *ptrace.GCCycle) time.Duration { return c.MarkAssistTotal }},
+	{name: "Assisting Gs", width: 130, number: func(c *ptrace.GCCycle) int64 { return int64(len(c.MarkAssist)) }},
+	{name: "Heap before", width: 130, bytes: func(c *ptrace.GCCycle) uint64 { return c.HeapSizeBefore }},
+	{name: "Heap after", width: 130, bytes: func(c *ptrace.GCCycle) uint64 { return c.HeapSizeAfter }},
+	{name: "Goal before", width: 130, bytes: func(c *ptrace.GCCycle) uint64 { return c.HeapGoalBefore }},
+	{name: "Goal after", width: 130, bytes: func(c *ptrace.GCCycle) uint64 { return c.HeapGoalAfter }},
+	{name: "Swept", width: 130, bytes: func(c *ptrace.GCCycle) uint64 { return c.Swept }},
+	{name: "Reclaimed", width: 130, bytes: func(c *ptrace.GCCycle) uint64 { return c.Reclaimed }},
+}
+
+func sortGCCycles[T constraints.Ordered](cycles []*ptrace.GCCycle, descending bool, get func(*ptrace.GCCycle) T) {
+	if descending {
+		slices.SortStableFunc(cycles, func(a, b *ptrace.GCCycle) bool {
+			return get(a) > get(b)
+		})
+	} else {
+		slices.SortStableFunc(cycles, func(a, b *ptrace.GCCycle) bool {
+			return get(a) < get(b)
+		})
+	}
+}
+
+// GCPanel lists all GC cycles in the trace.
+type GCPanel struct {
+	mwin *MainWindow
+
+	description Description
+	cycles      gcCycleList
+
+	theme.PanelButtons
+}
+
+func NewGCPanel(mwin *MainWindow) *GCPanel {
+	gp := &GCPanel{
+		mwin: mwin,
+		cycles: gcCycleList{
+			// Don't sort tr.GCCycles in place, other code relies on it being in chronological order.
+			cycles: slices.Clone(mwin.trace.GCCycles),
+		},
+	}
+	return gp
+}
+
+func (gp *GCPanel) Title() string {
+	return "GC cycles"
+}
+
+func (gp *GCPanel) init(win *theme.Window) {
+	var total, stw, assist time.Duration
+	var reclaimed uint64
+	for _, c := range gp.mwin.trace.GCCycles {
+		total += c.Span.Duration()
+		stw += c.STWDuration()
+		assist += c.MarkAssistTotal
+		reclaimed += c.Reclaimed
+	}
+	reclaimedValue, reclaimedUnit := formatBytes(reclaimed)
+
+	value := func(s *TextSpan) *theme.Future[TextSpan] {
+		return theme.Immediate(*s)
+	}
+	tb := TextBuilder{Theme: win.Theme}
+	gp.description.Attributes = []DescriptionAttribute{
+		{Key: "Cycles", Value: value(tb.Span(local.Sprintf("%d", len(gp.mwin.trace.GCCycles))))},
+		{Key: "Time in GC", Value: value(tb.Span(total.String()))},
+		{Key: "Time in STW", Value: value(tb.Span(stw.String()))},
+		{Key: "Time in mark assist", Value: value(tb.Span(assist.String()))},
+		{Key: "Reclaimed", Value: value(tb.Span(reclaimedValue + " " + reclaimedUnit))},
+	}
+}
+
+func (gp *GCPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.GCPanel.Layout").End()
+
+	if gp.description.Attributes == nil {
+		gp.init(win)
+	}
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, gp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			return gp.description.Layout(win, gtx)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if len(gp.cycles.cycles) == 0 {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "The trace contains no GC cycles.", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			return gp.cycles.Layout(win, gtx)
+		}),
+	)
+
+	for _, ev := range gp.cycles.Clicked() {
+		if handleGCCycleTaxClick(gp.mwin, ev) {
+			continue
+		}
+		handleLinkClick(win, gp.mwin, ev)
+	}
+
+	for gp.PanelButtons.Backed() {
+		gp.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+type gcCycleList struct {
+	cycles []*ptrace.GCCycle
+	list   widget.List
+
+	sortCol        int
+	sortDescending bool
+	columnClicks   [len(gcColumns)]widget.PrimaryClickable
+
+	taxObjects allocator[gcCycleTax]
+	texts      allocator[Text]
+}
+
+func (cl *gcCycleList) sort() {
+	col := &gcColumns[cl.sortCol]
+	switch {
+	case col.duration != nil:
+		sortGCCycles(cl.cycles, cl.sortDescending, col.duration)
+	case col.bytes != nil:
+		sortGCCycles(cl.cycles, cl.sortDescending, col.bytes)
+	case col.number != nil:
+		sortGCCycles(cl.cycles, cl.sortDescending, col.number)
+	default:
+		panic("unreachable")
+	}
+}
+
+func (cl *gcCycleList) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.gcCycleList.Layout").End()
+
+	for col := range cl.columnClicks {
+		for cl.columnClicks[col].Clicked() {
+			if col == cl.sortCol {
+				cl.sortDescending = !cl.sortDescending
+			} else {
+				cl.sortCol = col
+				// Users are usually interested in the largest values, except for the columns that are about
+				// chronological order.
+				cl.sortDescending = col > 1
+			}
+			cl.sort()
+		}
+	}
+
+	cl.list.Axis = layout.Vertical
+	cl.taxObjects.Reset()
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < cl.texts.Len() {
+			txt = cl.texts.Ptr(txtCnt)
+		} else {
+			txt = cl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+		txt.Alignment = text.End
+
+		c := cl.cycles[row]
+		switch col {
+		case 0: // Cycle
+			txt.Link(local.Sprintf("%d", c.Seq), c)
+		case 1: // Start
+			txt.Link(formatTimestamp(c.Span.Start), c)
+		case 5: // Assisting Gs
+			txt.Link(local.Sprintf("%d", len(c.MarkAssist)), cl.taxObjects.Allocate(gcCycleTax{c}))
+		default:
+			var value, unit string
+			switch gcCol := &gcColumns[col]; {
+			case gcCol.duration != nil:
+				value, unit = durationNumberFormatSITable.format(gcCol.duration(c))
+			case gcCol.bytes != nil:
+				value, unit = formatBytes(gcCol.bytes(c))
+			case gcCol.number != nil:
+				value = local.Sprintf("%d", gcCol.number(c))
+			}
+			txt.Span(value)
+			if unit != "" {
+				txt.Span(" ")
+				s := txt.Span(unit)
+				s.Font.Variant = "Mono"
+			}
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	var columns [len(gcColumns)]theme.TableListColumn
+	for i := range gcColumns {
+		col := &gcColumns[i]
+		name := col.name
+		if i == cl.sortCol {
+			if cl.sortDescending {
+				name += "▼"
+			} else {
+				name += "▲"
+			}
+		}
+		columns[i] = theme.TableListColumn{Name: name, MinWidth: col.width, MaxWidth: col.width}
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns[:],
+		List:          &cl.list,
+		ColumnPadding: gtx.Dp(10),
+		ColumnClicks:  cl.columnClicks[:],
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(cl.cycles), cellFn)
+	cl.texts.Truncate(txtCnt)
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (cl *gcCycleList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < cl.texts.Len(); i++ {
+		txt := cl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/gesture"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+	"gioui.org/text"
+	"golang.org/x/exp/slices"
+)
+
+// gcTax is the time goroutines spent working for the GC instead of running their own code, either by assisting the
+// mark phase or by waiting for the GC.
+type gcTax struct {
+	MarkAssist time.Duration
+	BlockedGC  time.Duration
+}
+
+func (t *gcTax) Total() time.Duration {
+	return t.MarkAssist + t.BlockedGC
+}
+
+// add adds span s, which is in the built-in state state.
+func (t *gcTax) add(state ptrace.SchedulingState, s *ptrace.Span) {
+	switch state {
+	case ptrace.StateGCMarkAssist:
+		t.MarkAssist += s.Duration()
+	case ptrace.StateBlockedGC:
+		t.BlockedGC += s.Duration()
+	}
+}
+
+type goroutineGCTax struct {
+	Goroutine *ptrace.Goroutine
+	// The longest span of mark assist or being blocked on the GC
+	Longest ptrace.Span
+	gcTax
+}
+
+// gcTaxGroup is the tax of a group of spans, such as the spans with the same call site.
+type gcTaxGroup struct {
+	Label string
+	// The object the label links to, or nil
+	Object     any
+	Goroutines int
+	gcTax
+
+	// The goroutine whose spans were added last, for counting goroutines
+	lastG *ptrace.Goroutine
+}
+
+func (grp *gcTaxGroup) add(g *ptrace.Goroutine, state ptrace.SchedulingState, s *ptrace.Span) {
+	if grp.lastG != g {
+		grp.lastG = g
+		grp.Goroutines++
+	}
+	grp.gcTax.add(state, s)
+}
+
+type gcTaxes struct {
+	// Taxes per goroutine, per call site of the spans, and per function that goroutines started in, sorted by total
+	// tax in descending order
+	Goroutines []*goroutineGCTax
+	CallSites  []*gcTaxGroup
+	Functions  []*gcTaxGroup
+
+	gcTax
+}
+
+// gcCycleTax is the link object for the GC tax of a single GC cycle.
+type gcCycleTax struct {
+	Cycle *ptrace.GCCycle
+}
+
+// computeGCTaxes collects the time goroutines spent in mark assist or blocked on the GC. If cycle is not nil, only
+// spans that started during the cycle or the sweep phase following it are considered.
+//
+// Spans are attributed to the user code that caused them, which is the code that allocated. Grouping by the
+// goroutines' functions instead would lump together all goroutines of, say, an HTTP server, no matter what they were
+// doing.
+func computeGCTaxes(tr *Trace, cycle *ptrace.GCCycle, cancelled <-chan struct{}) *gcTaxes {
+	start, end := trace.Timestamp(0), tr.Events[len(tr.Events)-1].Ts
+	if cycle != nil {
+		start = cycle.Span.Start
+		if idx := slices.Index(tr.GCCycles, cycle); idx+1 < len(tr.GCCycles) {
+			end = tr.GCCycles[idx+1].Span.Start
+		}
+	}
+
+	out := &gcTaxes{}
+	byCallSite := map[trace.Frame]*gcTaxGroup{}
+	byFn := map[*ptrace.Function]*gcTaxGroup{}
+	for i, g := range tr.Goroutines {
+		if i%1000 == 0 {
+			select {
+			case <-cancelled:
+				return nil
+			default:
+			}
+		}
+
+		var gt *goroutineGCTax
+		for j := 0; j < g.Spans.Len(); j++ {
+			s := g.Spans.AtPtr(j)
+			state := tr.BaseState(s.State)
+			if state != ptrace.StateGCMarkAssist && state != ptrace.StateBlockedGC {
+				continue
+			}
+			if s.Start < start || s.Start >= end {
+				continue
+			}
+			if gt == nil {
+				gt = &goroutineGCTax{Goroutine: g}
+			}
+			gt.add(state, s)
+			if s.Duration() > gt.Longest.Duration() {
+				gt.Longest = *s
+			}
+
+			var site trace.Frame
+			if stk := tr.Stacks[tr.Event(s.Event).StkID]; len(stk) != 0 {
+				site = userCallSite(tr, stk)
+			}
+			grp, ok := byCallSite[site]
+			if !ok {
+				grp = &gcTaxGroup{Label: callSiteLabel(site)}
+				byCallSite[site] = grp
+			}
+			grp.add(g, state, s)
+		}
+		if gt == nil {
+			continue
+		}
+
+		out.Goroutines = append(out.Goroutines, gt)
+		out.MarkAssist += gt.MarkAssist
+		out.BlockedGC += gt.BlockedGC
+
+		grp, ok := byFn[g.Function]
+		if !ok {
+			grp = &gcTaxGroup{Label: g.Function.Fn, Object: g.Function}
+			byFn[g.Function] = grp
+		}
+		grp.Goroutines++
+		grp.MarkAssist += gt.MarkAssist
+		grp.BlockedGC += gt.BlockedGC
+	}
+
+	slices.SortFunc(out.Goroutines, func(a, b *goroutineGCTax) bool {
+		if a.Total() != b.Total() {
+			return a.Total() > b.Total()
+		}
+		return a.Goroutine.ID < b.Goroutine.ID
+	})
+
+	sortGroups := func(groups []*gcTaxGroup) {
+		slices.SortFunc(groups, func(a, b *gcTaxGroup) bool {
+			if a.Total() != b.Total() {
+				return a.Total() > b.Total()
+			}
+			return a.Label < b.Label
+		})
+	}
+	out.CallSites = make([]*gcTaxGroup, 0, len(byCallSite))
+	for _, grp := range byCallSite {
+		out.CallSites = append(out.CallSites, grp)
+	}
+	sortGroups(out.CallSites)
+	out.Functions = make([]*gcTaxGroup, 0, len(byFn))
+	for _, grp := range byFn {
+		out.Functions = append(out.Functions, grp)
+	}
+	sortGroups(out.Functions)
+
+	return out
+}
+
+// GCTaxPanel ranks call sites, goroutine functions and goroutines by the time they spent assisting or waiting for the
+// GC.
+type GCTaxPanel struct {
+	mwin *MainWindow
+	// The GC cycle to limit the analysis to, or nil for the whole trace
+	cycle       *ptrace.GCCycle
+	taxes       *theme.Future[*gcTaxes]
+	initialized bool
+
+	description   Description
+	tabbedState   theme.TabbedState
+	callSiteList  gcTaxGroupList
+	functionList  gcTaxGroupList
+	goroutineList goroutineGCTaxList
+
+	theme.PanelButtons
+}
+
+func NewGCTaxPanel(mwin *MainWindow, cycle *ptrace.GCCycle) *GCTaxPanel {
+	tr := mwin.trace
+	return &GCTaxPanel{
+		mwin:         mwin,
+		cycle:        cycle,
+		callSiteList: gcTaxGroupList{labelColumn: "Call site"},
+		functionList: gcTaxGroupList{labelColumn: "Goroutine function"},
+		taxes: theme.NewFuture(mwin.twin, func(cancelled <-chan struct{}) *gcTaxes {
+			return computeGCTaxes(tr, cycle, cancelled)
+		}),
+	}
+}
+
+func (tp *GCTaxPanel) Title() string {
+	if tp.cycle != nil {
+		return local.Sprintf("GC tax of cycle %d", tp.cycle.Seq)
+	}
+	return "GC tax"
+}
+
+func (tp *GCTaxPanel) init(win *theme.Window, taxes *gcTaxes) {
+	value := func(s *TextSpan) *theme.Future[TextSpan] {
+		return theme.Immediate(*s)
+	}
+	tb := TextBuilder{Theme: win.Theme}
+	var attrs []DescriptionAttribute
+	if tp.cycle != nil {
+		attrs = append(attrs, DescriptionAttribute{Key: "GC cycle", Value: value(tb.Link(local.Sprintf("%d", tp.cycle.Seq), tp.cycle))})
+	}
+	attrs = append(attrs,
+		DescriptionAttribute{Key: "Affected goroutines", Value: value(tb.Span(local.Sprintf("%d", len(taxes.Goroutines))))},
+		DescriptionAttribute{Key: "Mark assist", Value: value(tb.Span(taxes.MarkAssist.String()))},
+		DescriptionAttribute{Key: "Blocked on GC", Value: value(tb.Span(taxes.BlockedGC.String()))},
+		DescriptionAttribute{Key: "Total", Value: value(tb.Span(taxes.Total().String()))},
+	)
+	tp.description.Attributes = attrs
+}
+
+func (tp *GCTaxPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.GCTaxPanel.Layout").End()
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	taxes, ok := tp.taxes.Result()
+	if ok && !tp.initialized {
+		tp.init(win, taxes)
+		tp.initialized = true
+	}
+
+	tabs := []string{"Call sites", "Goroutine functions", "Goroutines"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, tp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !ok {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing GC tax…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min = image.Point{}
+					return tp.description.Layout(win, gtx)
+				}),
+
+				layout.Rigid(layout.Spacer{Height: 10}.Layout),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					if len(taxes.Goroutines) == 0 {
+						return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "No goroutines assisted or waited for the GC.", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+					}
+					return theme.Tabbed(&tp.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+						switch tabs[tp.tabbedState.Current] {
+						case "Call sites":
+							return tp.callSiteList.Layout(win, gtx, taxes.CallSites)
+						case "Goroutine functions":
+							return tp.functionList.Layout(win, gtx, taxes.Functions)
+						case "Goroutines":
+							return tp.goroutineList.Layout(win, gtx, taxes.Goroutines)
+						default:
+							panic("unreachable")
+						}
+					})
+				}),
+			)
+		}),
+	)
+
+	for _, ev := range tp.description.Events() {
+		handleLinkClick(win, tp.mwin, ev)
+	}
+	for _, ev := range tp.callSiteList.Clicked() {
+		handleLinkClick(win, tp.mwin, ev)
+	}
+	for _, ev := range tp.functionList.Clicked() {
+		handleLinkClick(win, tp.mwin, ev)
+	}
+	for _, ev := range tp.goroutineList.Clicked() {
+		handleLinkClick(win, tp.mwin, ev)
+	}
+
+	for tp.PanelButtons.Backed() {
+		tp.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+// layoutDuration adds d to txt, in the format used by tables.
+func layoutDuration(txt *Text, d time.Duration) {
+	value, unit := durationNumberFormatSITable.format(d)
+	txt.Span(value)
+	txt.Span(" ")
+	s := txt.Span(unit)
+	s.Font.Variant = "Mono"
+	txt.Alignment = text.End
+}
+
+type gcTaxGroupList struct {
+	// The name of the column that contains the groups' labels
+	labelColumn string
+
+	list  widget.List
+	texts allocator[Text]
+}
+
+func (fl *gcTaxGroupList) Layout(win *theme.Window, gtx layout.Context, groups []*gcTaxGroup) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.gcTaxGroupList.Layout").End()
+
+	fl.list.Axis = layout.Vertical
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < fl.texts.Len() {
+			txt = fl.texts.Ptr(txtCnt)
+		} else {
+			txt = fl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		grp := groups[row]
+		switch col {
+		case 0: // Rank
+			txt.Span(local.Sprintf("%d", row+1))
+			txt.Alignment = text.End
+		case 1: // Label
+			if grp.Object != nil {
+				txt.Link(grp.Label, grp.Object)
+			} else {
+				txt.Span(grp.Label)
+			}
+		case 2: // Goroutines
+			txt.Span(local.Sprintf("%d", grp.Goroutines))
+			txt.Alignment = text.End
+		case 3:
+			layoutDuration(txt, grp.MarkAssist)
+		case 4:
+			layoutDuration(txt, grp.BlockedGC)
+		case 5:
+			layoutDuration(txt, grp.Total())
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	// XXX the widths depend on the font and scaling
+	columns := []theme.TableListColumn{
+		{Name: "Rank", MinWidth: 60, MaxWidth: 60},
+		{Name: fl.labelColumn, MinWidth: 400, MaxWidth: 400},
+		{Name: "Goroutines", MinWidth: 120, MaxWidth: 120},
+		{Name: "Mark assist", MinWidth: 150, MaxWidth: 150},
+		{Name: "Blocked on GC", MinWidth: 150, MaxWidth: 150},
+		{Name: "Total", MinWidth: 150, MaxWidth: 150},
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns,
+		List:          &fl.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(groups), cellFn)
+	fl.texts.Truncate(txtCnt)
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (fl *gcTaxGroupList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < fl.texts.Len(); i++ {
+		txt := fl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
+
+type goroutineGCTaxList struct {
+	list widget.List
+
+	spanObjects allocator[SpanRef]
+	texts       allocator[Text]
+}
+
+func (gl *goroutineGCTaxList) Layout(win *theme.Window, gtx layout.Context, gs []*goroutineGCTax) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.goroutineGCTaxList.Layout").End()
+
+	gl.list.Axis = layout.Vertical
+	gl.spanObjects.Reset()
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < gl.texts.Len() {
+			txt = gl.texts.Ptr(txtCnt)
+		} else {
+			txt = gl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		gt := gs[row]
+		switch col {
+		case 0: // Rank
+			txt.Span(local.Sprintf("%d", row+1))
+			txt.Alignment = text.End
+		case 1: // Goroutine
+			txt.Link(local.Sprintf("%d", gt.Goroutine.ID), gt.Goroutine)
+			txt.Alignment = text.End
+		case 2: // Function
+			txt.Link(gt.Goroutine.Function.Fn, gt.Goroutine.Function)
+		case 3:
+			layoutDuration(txt, gt.MarkAssist)
+		case 4:
+			layoutDuration(txt, gt.BlockedGC)
+		case 5:
+			layoutDuration(txt, gt.Total())
+		case 6: // Longest
+			value, unit := durationNumberFormatSITable.format(gt.Longest.Duration())
+			txt.Link(value, gl.spanObjects.Allocate(SpanRef{gt.Goroutine, gt.Longest}))
+			txt.Span(" ")
+			s := txt.Span(unit)
+			s.Font.Variant = "Mono"
+			txt.Alignment = text.End
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	// XXX the widths depend on the font and scaling
+	columns := []theme.TableListColumn{
+		{Name: "Rank", MinWidth: 60, MaxWidth: 60},
+		{Name: "Goroutine", MinWidth: 120, MaxWidth: 120},
+		{Name: "Function", MinWidth: 400, MaxWidth: 400},
+		{Name: "Mark assist", MinWidth: 150, MaxWidth: 150},
+		{Name: "Blocked on GC", MinWidth: 150, MaxWidth: 150},
+		{Name: "Total", MinWidth: 150, MaxWidth: 150},
+		{Name: "Longest", MinWidth: 150, MaxWidth: 150},
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns,
+		List:          &gl.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(gs), cellFn)
+	gl.texts.Truncate(txtCnt)
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (gl *goroutineGCTaxList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < gl.texts.Len(); i++ {
+		txt := gl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
+
+// handleGCCycleTaxClick opens the GC tax panel for a cycle if ev is a click on a gcCycleTax link, and reports whether
+// it handled the event.
+func handleGCCycleTaxClick(mwin *MainWindow, ev TextEvent) bool {
+	obj, ok := ev.Span.Object.(*gcCycleTax)
+	if !ok {
+		return false
+	}
+	if ev.Event.Type == gesture.TypeClick && ev.Event.Button == pointer.ButtonPrimary {
+		mwin.openPanel(NewGCTaxPanel(mwin, obj.Cycle))
+	}
+	return true
+}
@@ -39,6 +39,7 @@ var stateNames = [ptrace.StateLast]string{
 	ptrace.StateRunningG:                "active",
 	ptrace.StateUserRegion:              "user region",
 	ptrace.StateStack:                   "stack frame",
+	ptrace.StateTask:                    "task",
 }
 
 var stateNamesCapitalized = [ptrace.StateLast]string{
@@ -67,6 +68,7 @@ var stateNamesCapitalized = [ptrace.StateLast]string{
 	ptrace.StateRunningG:                "Active",
 	ptrace.StateUserRegion:              "User region",
 	ptrace.StateStack:                   "Stack frame",
+	ptrace.StateTask:                    "Task",
 }
 
 func goroutineTrack0SpanLabel(spans ptrace.Spans, tr *Trace, out []string) []string {
@@ -87,7 +89,7 @@ func goroutineTrack0SpanLabel(spans ptrace.Spans, tr *Trace, out []string) []str
 			)
 		}
 	}
-	return append(out, spanStateLabels[state]...)
+	return append(out, tr.stateLabels(state)...)
 }
 
 func goroutineTrack0SpanContextMenu(spans ptrace.Spans, cv *Canvas) []*theme.MenuItem {
@@ -95,7 +97,7 @@ func goroutineTrack0SpanContextMenu(spans ptrace.Spans, cv *Canvas) []*theme.Men
 	items = append(items, newZoomMenuItem(cv, spans))
 
 	if spans.Len() == 1 {
-		switch spans.At(0).State {
+		switch cv.trace.BaseState(spans.At(0).State) {
 		case ptrace.StateActive, ptrace.StateGCIdle, ptrace.StateGCDedicated, ptrace.StateGCFractional, ptrace.StateGCMarkAssist, ptrace.StateGCSweep:
 			// These are the states that are actually on-CPU
 			pid := cv.trace.Event((spans.At(0).Event)).P
@@ -253,10 +255,10 @@ func (tt GoroutineTooltip) Layout(win *theme.Window, gtx layout.Context) layout.
 	d := time.Duration(end - start)
 
 	stats := tt.g.Statistics()
-	blocked := stats.Blocked()
-	inactive := stats.Inactive()
-	gcAssist := stats.GCAssist()
-	running := stats.Running()
+	blocked := stats.Blocked(tt.trace.Trace)
+	inactive := stats.Inactive(tt.trace.Trace)
+	gcAssist := stats.GCAssist(tt.trace.Trace)
+	running := stats.Running(tt.trace.Trace)
 	blockedPct := float32(blocked) / float32(d) * 100
 	inactivePct := float32(inactive) / float32(d) * 100
 	gcAssistPct := float32(gcAssist) / float32(d) * 100
@@ -324,9 +326,20 @@ var reasonLabels = [256]string{
 	reasonPreempted:    "got preempted",
 }
 
+// lookupGoroutine returns the goroutine with the given ID. Unlike Trace.G, it doesn't panic for unknown IDs, which
+// makes it suitable for IDs provided by the user.
+func lookupGoroutine(tr *Trace, id uint64) (*ptrace.Goroutine, bool) {
+	for _, g := range tr.Goroutines {
+		if g.ID == id {
+			return g, true
+		}
+	}
+	return nil, false
+}
+
 func unblockedByGoroutine(tr *Trace, s ptrace.Span) (uint64, bool) {
 	ev := tr.Event(s.Event)
-	switch s.State {
+	switch tr.BaseState(s.State) {
 	case ptrace.StateBlocked, ptrace.StateBlockedSend, ptrace.StateBlockedRecv, ptrace.StateBlockedSelect, ptrace.StateBlockedSync,
 		ptrace.StateBlockedSyncOnce, ptrace.StateBlockedSyncTriggeringGC, ptrace.StateBlockedCond, ptrace.StateBlockedNet, ptrace.StateBlockedGC:
 		if link := ptrace.EventID(ev.Link); link != -1 {
@@ -404,12 +417,14 @@ func goroutineSpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace, stat
 					l.Args[trace.ArgGCSweepDoneSwept], l.Args[trace.ArgGCSweepDoneReclaimed])
 			}
 		default:
-			if debug {
+			if ptrace.IsUserState(state) {
+				label += tr.stateName(state)
+			} else if debug {
 				panic(fmt.Sprintf("unhandled state %d", state))
 			}
 		}
 
-		tags := spanTagStrings(s.Tags)
+		tags := spanTagStrings(tr, s.Tags)
 		if len(tags) != 0 {
 			label += " (" + strings.Join(tags, ", ") + ")"
 		}
@@ -434,7 +449,7 @@ func goroutineSpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace, stat
 		label += fmt.Sprintf("In: %s\n", at)
 	}
 	if state.spans.Len() == 1 {
-		switch state.spans.At(0).State {
+		switch tr.BaseState(state.spans.At(0).State) {
 		case ptrace.StateActive, ptrace.StateGCIdle, ptrace.StateGCDedicated, ptrace.StateGCMarkAssist, ptrace.StateGCSweep:
 			pid := tr.Event(state.spans.At(0).Event).P
 			label += local.Sprintf("On: processor %d\n", pid)
@@ -544,7 +559,8 @@ var spanStateLabels = [...][]string{
 	ptrace.StateDone:         {},
 	ptrace.StateGCMarkAssist: {"GC mark assist", "M"},
 	ptrace.StateGCSweep:      {"GC sweep", "S"},
-	ptrace.StateLast:         nil,
+	// Labels of user-defined states are looked up with Trace.stateLabels
+	ptrace.StateLast: nil,
 }
 
 type stackSpanMeta struct {
@@ -708,7 +724,7 @@ func addStackTracks(tl *Timeline, g *ptrace.Goroutine, tr *Trace) {
 }
 
 func goroutineLinkContextMenu(mwin *MainWindow, obj *ptrace.Goroutine) []*theme.MenuItem {
-	return []*theme.MenuItem{
+	items := []*theme.MenuItem{
 		{
 			Label: PlainLabel("Scroll to goroutine"),
 			Do: func(gtx layout.Context) {
@@ -728,6 +744,23 @@ func goroutineLinkContextMenu(mwin *MainWindow, obj *ptrace.Goroutine) []*theme.
 			},
 		},
 	}
+	if obj.Parent != nil {
+		items = append(items, &theme.MenuItem{
+			Label: PlainLabel("Show parent"),
+			Do: func(gtx layout.Context) {
+				mwin.OpenLink(&GoroutineLink{Goroutine: obj.Parent, Kind: GoroutineLinkKindOpen})
+			},
+		})
+	}
+	if len(obj.Children) != 0 {
+		items = append(items, &theme.MenuItem{
+			Label: PlainLabel("Show children"),
+			Do: func(gtx layout.Context) {
+				mwin.openPanel(NewCreationTreePanel(mwin, obj))
+			},
+		})
+	}
+	return items
 }
 
 func NewGoroutineInfo(mwin *MainWindow, g *ptrace.Goroutine) *SpansInfo {
@@ -803,6 +836,30 @@ func NewGoroutineInfo(mwin *MainWindow, g *ptrace.Goroutine) *SpansInfo {
 		})
 	}
 
+	if g.Parent != nil {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Parent",
+			Value: value(tb.Link(local.Sprintf("goroutine %d", g.Parent.ID), g.Parent)),
+		})
+	} else {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Parent",
+			Value: value(tb.Span("created before trace start")),
+		})
+	}
+
+	if len(g.Children) != 0 {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Children",
+			Value: value(tb.Link(local.Sprintf("%d goroutines", len(g.Children)), &goroutineChildren{g})),
+		})
+	} else {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Children",
+			Value: value(tb.Span("none")),
+		})
+	}
+
 	if observedStart && observedEnd {
 		attrs = append(attrs, DescriptionAttribute{
 			Key:   "Lifetime",
@@ -837,9 +894,10 @@ func NewGoroutineInfo(mwin *MainWindow, g *ptrace.Goroutine) *SpansInfo {
 			},
 		},
 		Statistics: theme.NewFuture(mwin.twin, func(cancelled <-chan struct{}) *SpansStats {
-			return NewGoroutineStats(g)
+			return NewGoroutineStats(mwin.trace, g)
 		}),
 		Description: &desc,
+		Goroutine:   g,
 	}
 
 	return NewSpansInfo(cfg, mwin, spans, g.Events)
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+
+	"golang.org/x/exp/slices"
+)
+
+// goroutineCountCategory groups goroutine states for the purpose of counting goroutines.
+type goroutineCountCategory uint8
+
+const (
+	goroutineCountNone goroutineCountCategory = iota
+
+	// Categories of the goroutines plot
+	goroutineCountRunning
+	goroutineCountRunnable
+	goroutineCountSyscall
+
+	// Categories of the blocked goroutines plot
+	goroutineCountBlockedChannel
+	goroutineCountBlockedSync
+	goroutineCountBlockedNet
+	goroutineCountBlockedGC
+	goroutineCountBlockedOther
+
+	goroutineCountLast
+)
+
+// We don't count inactive goroutines. They're usually runtime goroutines that spend most of their lives waiting for
+// work, and would drown out the interesting blocked goroutines.
+var goroutineCountCategories = [ptrace.StateLast]goroutineCountCategory{
+	ptrace.StateActive:                  goroutineCountRunning,
+	ptrace.StateGCIdle:                  goroutineCountRunning,
+	ptrace.StateGCDedicated:             goroutineCountRunning,
+	ptrace.StateGCFractional:            goroutineCountRunning,
+	ptrace.StateGCMarkAssist:            goroutineCountRunning,
+	ptrace.StateGCSweep:                 goroutineCountRunning,
+	ptrace.StateReady:                   goroutineCountRunnable,
+	ptrace.StateCreated:                 goroutineCountRunnable,
+	ptrace.StateBlockedSyscall:          goroutineCountSyscall,
+	ptrace.StateBlockedSend:             goroutineCountBlockedChannel,
+	ptrace.StateBlockedRecv:             goroutineCountBlockedChannel,
+	ptrace.StateBlockedSelect:           goroutineCountBlockedChannel,
+	ptrace.StateBlockedSync:             goroutineCountBlockedSync,
+	ptrace.StateBlockedSyncOnce:         goroutineCountBlockedSync,
+	ptrace.StateBlockedSyncTriggeringGC: goroutineCountBlockedSync,
+	ptrace.StateBlockedCond:             goroutineCountBlockedSync,
+	ptrace.StateBlockedNet:              goroutineCountBlockedNet,
+	ptrace.StateBlockedGC:               goroutineCountBlockedGC,
+	ptrace.StateBlocked:                 goroutineCountBlockedOther,
+	ptrace.StateStuck:                   goroutineCountBlockedOther,
+}
+
+// goroutineCountPlots computes the number of goroutines in each category at every point in time and returns two
+// stacked plots, one for running, runnable and syscall goroutines, and one for blocked goroutines.
+func goroutineCountPlots(tr *ptrace.Trace, progress func(float64)) (goroutines, blocked *Plot) {
+	type delta struct {
+		ts       trace.Timestamp
+		category goroutineCountCategory
+		delta    int8
+	}
+
+	var n int
+	for _, g := range tr.Goroutines {
+		n += g.Spans.Len()
+	}
+	deltas := make([]delta, 0, 2*n)
+	for i, g := range tr.Goroutines {
+		for j := 0; j < g.Spans.Len(); j++ {
+			s := g.Spans.AtPtr(j)
+			c := goroutineCountCategories[tr.BaseState(s.State)]
+			if c == goroutineCountNone {
+				continue
+			}
+			deltas = append(deltas, delta{s.Start, c, 1}, delta{s.End, c, -1})
+		}
+		progress(float64(i+1) / float64(len(tr.Goroutines)) / 2)
+	}
+	slices.SortFunc(deltas, func(a, b delta) bool {
+		return a.ts < b.ts
+	})
+
+	const (
+		firstGoroutines = goroutineCountRunning
+		lastGoroutines  = goroutineCountSyscall
+		firstBlocked    = goroutineCountBlockedChannel
+		lastBlocked     = goroutineCountBlockedOther
+	)
+
+	var points [goroutineCountLast][]ptrace.Point
+	emit := func(ts trace.Timestamp, counts *[goroutineCountLast]int64, first, last goroutineCountCategory) {
+		// All series of a stacked plot need points at the same timestamps.
+		for c := first; c <= last; c++ {
+			points[c] = append(points[c], ptrace.Point{When: ts, Value: uint64(counts[c])})
+		}
+	}
+
+	var counts, prevCounts [goroutineCountLast]int64
+	changed := func(first, last goroutineCountCategory) bool {
+		for c := first; c <= last; c++ {
+			if counts[c] != prevCounts[c] {
+				return true
+			}
+		}
+		return false
+	}
+	for i, iter := 0, 0; i < len(deltas); iter++ {
+		ts := deltas[i].ts
+		for ; i < len(deltas) && deltas[i].ts == ts; i++ {
+			counts[deltas[i].category] += int64(deltas[i].delta)
+		}
+
+		// Spans of the same category that directly follow each other cancel out, don't emit redundant points for them.
+		if changed(firstGoroutines, lastGoroutines) {
+			emit(ts, &counts, firstGoroutines, lastGoroutines)
+		}
+		if changed(firstBlocked, lastBlocked) {
+			emit(ts, &counts, firstBlocked, lastBlocked)
+		}
+		prevCounts = counts
+
+		if iter%10000 == 0 {
+			progress(0.5 + float64(i)/float64(len(deltas))/2)
+		}
+	}
+
+	goroutines = &Plot{
+		Name:    "Goroutines",
+		Unit:    "goroutines",
+		Stacked: true,
+	}
+	goroutines.AddSeries(
+		PlotSeries{Name: "Running", Points: points[goroutineCountRunning], Filled: true, Color: colors[colorStateActive]},
+		PlotSeries{Name: "Runnable", Points: points[goroutineCountRunnable], Filled: true, Color: colors[colorStateReady]},
+		PlotSeries{Name: "Syscall", Points: points[goroutineCountSyscall], Filled: true, Color: colors[colorStateBlockedSyscall]},
+	)
+
+	blocked = &Plot{
+		Name:    "Blocked goroutines",
+		Unit:    "goroutines",
+		Stacked: true,
+	}
+	blocked.AddSeries(
+		PlotSeries{Name: "Channels and select", Points: points[goroutineCountBlockedChannel], Filled: true, Color: colors[colorStateBlockedHappensBefore]},
+		PlotSeries{Name: "Synchronization", Points: points[goroutineCountBlockedSync], Filled: true, Color: colors[colorStateBlocked]},
+		PlotSeries{Name: "Network", Points: points[goroutineCountBlockedNet], Filled: true, Color: colors[colorStateBlockedNet]},
+		PlotSeries{Name: "GC", Points: points[goroutineCountBlockedGC], Filled: true, Color: colors[colorStateBlockedGC]},
+		PlotSeries{Name: "Other", Points: points[goroutineCountBlockedOther], Filled: true, Color: colors[colorStateInactive]},
+	)
+
+	return goroutines, blocked
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"sort"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/gesture"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+	"gioui.org/text"
+	"golang.org/x/exp/constraints"
+	"golang.org/x/exp/slices"
+)
+
+// minIdleEpisode is the shortest period of time during which Ps have to be idle while goroutines are runnable for us to
+// report it. Waking up an idle P takes some time, and shorter episodes are a normal part of scheduling.
+const minIdleEpisode = 100 * time.Microsecond
+
+// idleEpisode is a period of time during which fewer Ps than GOMAXPROCS were running goroutines, while other goroutines
+// were ready to run. This points at scheduler starvation, problems with spinning Ms, or misuse of
+// runtime.LockOSThread.
+type idleEpisode struct {
+	Start, End trace.Timestamp
+	// The maximum number of idle Ps during the episode
+	IdlePs int
+	// The maximum number of simultaneously runnable goroutines during the episode
+	Runnable int
+	// The number of distinct goroutines that were runnable during the episode
+	Goroutines int
+	// The first processor that was idle during the episode, used for navigating to the episode
+	Processor *ptrace.Processor
+
+	lastG *ptrace.Goroutine
+}
+
+func (ep *idleEpisode) Duration() time.Duration {
+	return time.Duration(ep.End - ep.Start)
+}
+
+type idleEpisodes struct {
+	// Episodes in chronological order
+	Episodes []*idleEpisode
+	// Per processor, indexed by sequence ID, the parts of the episodes during which the processor was idle
+	Processors [][]timeRange
+}
+
+// computeIdleEpisodes finds idle episodes by sweeping over the changes in the number of runnable goroutines, running
+// processors and GOMAXPROCS.
+func computeIdleEpisodes(tr *ptrace.Trace, progress func(float64)) *idleEpisodes {
+	type delta struct {
+		ts       trace.Timestamp
+		runnable int32
+		running  int32
+		stw      int32
+	}
+
+	var deltas []delta
+	for i, g := range tr.Goroutines {
+		for j := 0; j < g.Spans.Len(); j++ {
+			s := g.Spans.AtPtr(j)
+			if tr.BaseState(s.State) == ptrace.StateReady && s.End > s.Start {
+				deltas = append(deltas, delta{ts: s.Start, runnable: 1}, delta{ts: s.End, runnable: -1})
+			}
+		}
+		progress(float64(i+1) / float64(len(tr.Goroutines)) / 2)
+	}
+	for _, p := range tr.Processors {
+		for j := 0; j < p.Spans.Len(); j++ {
+			s := p.Spans.AtPtr(j)
+			deltas = append(deltas, delta{ts: s.Start, running: 1}, delta{ts: s.End, running: -1})
+		}
+	}
+	// Goroutines can't run while the world is stopped, which would otherwise look like idle Ps.
+	for i := 0; i < tr.STW.Len(); i++ {
+		s := tr.STW.AtPtr(i)
+		deltas = append(deltas, delta{ts: s.Start, stw: 1}, delta{ts: s.End, stw: -1})
+	}
+	sort.SliceStable(deltas, func(i, j int) bool { return deltas[i].ts < deltas[j].ts })
+
+	out := &idleEpisodes{
+		Processors: make([][]timeRange, len(tr.Processors)),
+	}
+	gomaxprocs := len(tr.Processors)
+	var gomaxprocsIdx int
+	var runnable, running, stw int
+	var cur *idleEpisode
+	for i := 0; i < len(deltas); {
+		ts := deltas[i].ts
+		// Apply all changes that happen at the same time before looking at the new state.
+		for ; i < len(deltas) && deltas[i].ts == ts; i++ {
+			runnable += int(deltas[i].runnable)
+			running += int(deltas[i].running)
+			stw += int(deltas[i].stw)
+		}
+		for gomaxprocsIdx < len(tr.Gomaxprocs) && tr.Gomaxprocs[gomaxprocsIdx].When <= ts {
+			gomaxprocs = int(tr.Gomaxprocs[gomaxprocsIdx].Value)
+			gomaxprocsIdx++
+		}
+
+		idle := gomaxprocs - running
+		if runnable > 0 && idle > 0 && stw == 0 {
+			if cur == nil {
+				cur = &idleEpisode{Start: ts}
+			}
+			if idle > cur.IdlePs {
+				cur.IdlePs = idle
+			}
+			if runnable > cur.Runnable {
+				cur.Runnable = runnable
+			}
+		} else if cur != nil {
+			cur.End = ts
+			if cur.Duration() >= minIdleEpisode {
+				out.Episodes = append(out.Episodes, cur)
+			}
+			cur = nil
+		}
+	}
+	progress(0.75)
+
+	if len(out.Episodes) == 0 {
+		progress(1)
+		return out
+	}
+
+	// Count the distinct goroutines that were runnable during each episode.
+	for _, g := range tr.Goroutines {
+		for j := 0; j < g.Spans.Len(); j++ {
+			s := g.Spans.AtPtr(j)
+			if tr.BaseState(s.State) != ptrace.StateReady {
+				continue
+			}
+			idx := sort.Search(len(out.Episodes), func(i int) bool {
+				return out.Episodes[i].End > s.Start
+			})
+			for _, ep := range out.Episodes[idx:] {
+				if ep.Start >= s.End {
+					break
+				}
+				if ep.lastG != g {
+					ep.lastG = g
+					ep.Goroutines++
+				}
+			}
+		}
+	}
+	for _, ep := range out.Episodes {
+		ep.lastG = nil
+	}
+
+	end := tr.Events[len(tr.Events)-1].Ts
+	episodeRanges := make([]timeRange, len(out.Episodes))
+	for i, ep := range out.Episodes {
+		episodeRanges[i] = timeRange{ep.Start, ep.End}
+	}
+	for _, p := range tr.Processors {
+		busy := make([]timeRange, 0, p.Spans.Len())
+		for j := 0; j < p.Spans.Len(); j++ {
+			s := p.Spans.AtPtr(j)
+			busy = append(busy, timeRange{s.Start, s.End})
+		}
+		idle := intersectRanges(episodeRanges, complementRanges(busy, end))
+		idle = intersectRanges(idle, complementRanges(processorInactiveRanges(tr, p), end))
+		out.Processors[p.SeqID] = idle
+
+		for _, r := range idle {
+			idx := sort.Search(len(out.Episodes), func(i int) bool {
+				return out.Episodes[i].End > r.start
+			})
+			if ep := out.Episodes[idx]; ep.Processor == nil {
+				ep.Processor = p
+			}
+		}
+	}
+	progress(1)
+
+	return out
+}
+
+// complementRanges returns the parts of [0, end] not covered by rs, which must be sorted and non-overlapping.
+func complementRanges(rs []timeRange, end trace.Timestamp) []timeRange {
+	var out []timeRange
+	var prev trace.Timestamp
+	for _, r := range rs {
+		if r.start > prev {
+			out = append(out, timeRange{prev, r.start})
+		}
+		if r.end > prev {
+			prev = r.end
+		}
+	}
+	if prev < end {
+		out = append(out, timeRange{prev, end})
+	}
+	return out
+}
+
+// intersectRanges returns the intersection of a and b, which must be sorted and non-overlapping.
+func intersectRanges(a, b []timeRange) []timeRange {
+	var out []timeRange
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start, end := a[i].start, a[i].end
+		if b[j].start > start {
+			start = b[j].start
+		}
+		if b[j].end < end {
+			end = b[j].end
+		}
+		if start < end {
+			out = append(out, timeRange{start, end})
+		}
+		if a[i].end < b[j].end {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out
+}
+
+type idleColumn struct {
+	name  string
+	width int
+	// Exactly one of duration and number is set.
+	duration func(ep *idleEpisode) time.Duration
+	number   func(ep *idleEpisode) int64
+}
+
+// XXX the widths depend on the font and scaling
+var idleColumns = [...]idleColumn{
+	{name: "Start", width: 200, number: func(ep *idleEpisode) int64 { return int64(ep.Start) }},
+	{name: "Duration", width: 150, duration: (*idleEpisode).Duration},
+	{name: "Idle Ps", width: 100, number: func(ep *idleEpisode) int64 { return int64(ep.IdlePs) }},
+	{name: "Runnable Gs", width: 130, number: func(ep *idleEpisode) int64 { return int64(ep.Runnable) }},
+	{name: "Affected Gs", width: 130, number: func(ep *idleEpisode) int64 { return int64(ep.Goroutines) }},
+}
+
+func sortIdleEpisodes[T constraints.Ordered](eps []*idleEpisode, descending bool, get func(*idleEpisode) T) {
+	if descending {
+		slices.SortStableFunc(eps, func(a, b *idleEpisode) bool {
+			return get(a) > get(b)
+		})
+	} else {
+		slices.SortStableFunc(eps, func(a, b *idleEpisode) bool {
+			return get(a) < get(b)
+		})
+	}
+}
+
+// IdleProcessorsPanel lists episodes of idle processors while goroutines were runnable, ranked by duration.
+type IdleProcessorsPanel struct {
+	mwin *MainWindow
+
+	description Description
+	episodes    idleEpisodeList
+
+	theme.PanelButtons
+}
+
+func NewIdleProcessorsPanel(mwin *MainWindow) *IdleProcessorsPanel {
+	ip := &IdleProcessorsPanel{
+		mwin: mwin,
+		episodes: idleEpisodeList{
+			// Don't sort the trace's episodes in place, they have to remain in chronological order.
+			episodes:       slices.Clone(mwin.trace.idleEpisodes.Episodes),
+			sortCol:        1,
+			sortDescending: true,
+		},
+	}
+	ip.episodes.sort()
+	return ip
+}
+
+func (ip *IdleProcessorsPanel) Title() string {
+	return "Idle processors"
+}
+
+func (ip *IdleProcessorsPanel) init(win *theme.Window) {
+	var total, longest time.Duration
+	for _, ep := range ip.episodes.episodes {
+		d := ep.Duration()
+		total += d
+		if d > longest {
+			longest = d
+		}
+	}
+
+	value := func(s *TextSpan) *theme.Future[TextSpan] {
+		return theme.Immediate(*s)
+	}
+	tb := TextBuilder{Theme: win.Theme}
+	ip.description.Attributes = []DescriptionAttribute{
+		{Key: "Episodes", Value: value(tb.Span(local.Sprintf("%d", len(ip.episodes.episodes))))},
+		{Key: "Total duration", Value: value(tb.Span(total.String()))},
+		{Key: "Longest episode", Value: value(tb.Span(longest.String()))},
+		{Key: "Minimum duration", Value: value(tb.Span(minIdleEpisode.String()))},
+	}
+}
+
+func (ip *IdleProcessorsPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.IdleProcessorsPanel.Layout").End()
+
+	if ip.description.Attributes == nil {
+		ip.init(win)
+	}
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, ip.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			return ip.description.Layout(win, gtx)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if len(ip.episodes.episodes) == 0 {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "No processors were idle while goroutines were runnable.", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			return ip.episodes.Layout(win, gtx)
+		}),
+	)
+
+	for _, ev := range ip.episodes.Clicked() {
+		if ep, ok := ev.Span.Object.(*idleEpisode); ok {
+			if ev.Event.Type == gesture.TypeClick && ev.Event.Button == pointer.ButtonPrimary {
+				ip.openEpisode(ep, ev.Event.Modifiers)
+			}
+			continue
+		}
+		handleLinkClick(win, ip.mwin, ev)
+	}
+
+	for ip.PanelButtons.Backed() {
+		ip.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+// openEpisode navigates to the episode on the timeline of the first processor that was idle during it.
+func (ip *IdleProcessorsPanel) openEpisode(ep *idleEpisode, mods key.Modifiers) {
+	tl := ip.mwin.canvas.gcTimeline
+	if ep.Processor != nil {
+		tl = ip.mwin.canvas.timelineOf(ep.Processor)
+	}
+	l := &SpansLink{
+		Timeline: tl,
+		Spans:    ptrace.ToSpans([]ptrace.Span{{Start: ep.Start, End: ep.End}}),
+		Kind:     SpanLinkKindScrollAndPan,
+	}
+	if mods == key.ModShortcut {
+		l.Kind = SpanLinkKindZoom
+	}
+	ip.mwin.OpenLink(l)
+}
+
+type idleEpisodeList struct {
+	episodes []*idleEpisode
+	list     widget.List
+
+	sortCol        int
+	sortDescending bool
+	columnClicks   [len(idleColumns)]widget.PrimaryClickable
+
+	texts allocator[Text]
+}
+
+func (el *idleEpisodeList) sort() {
+	col := &idleColumns[el.sortCol]
+	switch {
+	case col.duration != nil:
+		sortIdleEpisodes(el.episodes, el.sortDescending, col.duration)
+	case col.number != nil:
+		sortIdleEpisodes(el.episodes, el.sortDescending, col.number)
+	default:
+		panic("unreachable")
+	}
+}
+
+func (el *idleEpisodeList) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.idleEpisodeList.Layout").End()
+
+	for col := range el.columnClicks {
+		for el.columnClicks[col].Clicked() {
+			if col == el.sortCol {
+				el.sortDescending = !el.sortDescending
+			} else {
+				el.sortCol = col
+				// Users are usually interested in the largest values, except for the start time.
+				el.sortDescending = col != 0
+			}
+			el.sort()
+		}
+	}
+
+	el.list.Axis = layout.Vertical
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < el.texts.Len() {
+			txt = el.texts.Ptr(txtCnt)
+		} else {
+			txt = el.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+		txt.Alignment = text.End
+
+		ep := el.episodes[row]
+		switch col {
+		case 0: // Start
+			txt.Link(formatTimestamp(ep.Start), ep)
+		case 1: // Duration
+			layoutDuration(txt, ep.Duration())
+		default:
+			txt.Span(local.Sprintf("%d", idleColumns[col].number(ep)))
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	var columns [len(idleColumns)]theme.TableListColumn
+	for i := range idleColumns {
+		col := &idleColumns[i]
+		name := col.name
+		if i == el.sortCol {
+			if el.sortDescending {
+				name += "▼"
+			} else {
+				name += "▲"
+			}
+		}
+		columns[i] = theme.TableListColumn{Name: name, MinWidth: col.width, MaxWidth: col.width}
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns[:],
+		List:          &el.list,
+		ColumnPadding: gtx.Dp(10),
+		ColumnClicks:  el.columnClicks[:],
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(el.episodes), cellFn)
+	el.texts.Truncate(txtCnt)
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (el *idleEpisodeList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < el.texts.Len(); i++ {
+		txt := el.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
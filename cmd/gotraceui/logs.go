@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"image"
+	"regexp"
+	rtrace "runtime/trace"
+	"strings"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/gesture"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+	"gioui.org/text"
+)
+
+// logKey is the link object of log categories. Clicking it filters the log to that category.
+type logKey string
+
+// LogsPanel lists all user log events in the trace.
+type LogsPanel struct {
+	mwin *MainWindow
+
+	keyEditor     widget.Editor
+	messageEditor widget.Editor
+	useRegexp     widget.Bool
+
+	// The currently active filters. filterMessage is only used if filterRegexp is nil.
+	filterKey     string
+	filterMessage string
+	filterRegexp  *regexp.Regexp
+
+	filtered []ptrace.EventID
+	list     widget.List
+
+	timestampObjects allocator[trace.Timestamp]
+	texts            allocator[Text]
+
+	theme.PanelButtons
+}
+
+func NewLogsPanel(mwin *MainWindow) *LogsPanel {
+	lp := &LogsPanel{
+		mwin:     mwin,
+		filtered: mwin.trace.UserLogs,
+	}
+	lp.keyEditor.SingleLine = true
+	lp.messageEditor.SingleLine = true
+	return lp
+}
+
+func (lp *LogsPanel) Title() string {
+	return "User logs"
+}
+
+func (lp *LogsPanel) validateMessage(s string) bool {
+	if !lp.useRegexp.Value {
+		return true
+	}
+	_, err := regexp.Compile(s)
+	return err == nil
+}
+
+func (lp *LogsPanel) updateFilter() {
+	lp.filterKey = lp.keyEditor.Text()
+	lp.filterMessage = lp.messageEditor.Text()
+	lp.filterRegexp = nil
+	if lp.useRegexp.Value && lp.filterMessage != "" {
+		re, err := regexp.Compile(lp.filterMessage)
+		if err != nil {
+			// Keep showing the previous results while the user is still typing. The text box indicates the invalid
+			// expression.
+			return
+		}
+		lp.filterRegexp = re
+	}
+
+	tr := lp.mwin.trace
+	if lp.filterKey == "" && lp.filterMessage == "" {
+		lp.filtered = tr.UserLogs
+		return
+	}
+
+	// Don't reuse the backing array, it might be tr.UserLogs.
+	lp.filtered = nil
+	for _, evID := range tr.UserLogs {
+		ev := tr.Event(evID)
+		if lp.filterKey != "" && tr.Strings[ev.Args[trace.ArgUserLogKeyID]] != lp.filterKey {
+			continue
+		}
+		msg := tr.Strings[ev.Args[trace.ArgUserLogMessage]]
+		if lp.filterRegexp != nil {
+			if !lp.filterRegexp.MatchString(msg) {
+				continue
+			}
+		} else if lp.filterMessage != "" && !strings.Contains(msg, lp.filterMessage) {
+			continue
+		}
+		lp.filtered = append(lp.filtered, evID)
+	}
+}
+
+func (lp *LogsPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.LogsPanel.Layout").End()
+
+	changed := lp.useRegexp.Changed()
+	for _, ed := range [...]*widget.Editor{&lp.keyEditor, &lp.messageEditor} {
+		for _, ev := range ed.Events() {
+			if _, ok := ev.(widget.ChangeEvent); ok {
+				changed = true
+			}
+		}
+	}
+	if changed {
+		lp.updateFilter()
+	}
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, lp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return theme.TextBox(win.Theme, &lp.keyEditor, "Category").Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: 5}.Layout),
+				layout.Flexed(3, func(gtx layout.Context) layout.Dimensions {
+					tb := theme.TextBox(win.Theme, &lp.messageEditor, "Message")
+					tb.Validate = lp.validateMessage
+					return tb.Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: 5}.Layout),
+				layout.Rigid(theme.Dumb(win, theme.CheckBox(win.Theme, &lp.useRegexp, "Regular expression").Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 5}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			var s string
+			if len(lp.filtered) == len(lp.mwin.trace.UserLogs) {
+				s = local.Sprintf("%d log messages", len(lp.filtered))
+			} else {
+				s = local.Sprintf("%d of %d log messages", len(lp.filtered), len(lp.mwin.trace.UserLogs))
+			}
+			return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, s, widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return lp.layoutTable(win, gtx)
+		}),
+	)
+
+	for i := 0; i < lp.texts.Len(); i++ {
+		for _, ev := range lp.texts.Ptr(i).Events() {
+			if key, ok := ev.Span.Object.(logKey); ok {
+				if ev.Event.Type == gesture.TypeClick && ev.Event.Button == pointer.ButtonPrimary {
+					lp.keyEditor.SetText(string(key))
+					lp.updateFilter()
+				}
+				continue
+			}
+			handleLinkClick(win, lp.mwin, ev)
+		}
+	}
+
+	for lp.PanelButtons.Backed() {
+		lp.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+func (lp *LogsPanel) layoutTable(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	lp.list.Axis = layout.Vertical
+	lp.timestampObjects.Reset()
+
+	tr := lp.mwin.trace
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < lp.texts.Len() {
+			txt = lp.texts.Ptr(txtCnt)
+		} else {
+			txt = lp.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		ev := tr.Event(lp.filtered[row])
+		switch col {
+		case 0: // Time
+			txt.Link(formatTimestamp(ev.Ts), lp.timestampObjects.Allocate(ev.Ts))
+			txt.Alignment = text.End
+		case 1: // Goroutine
+			txt.Link(local.Sprintf("%d", ev.G), tr.G(ev.G))
+			txt.Alignment = text.End
+		case 2: // Task
+			if taskID := ev.Args[trace.ArgUserLogTaskID]; taskID != 0 {
+				t := tr.Task(taskID)
+				txt.Link(taskLabel(t), t)
+			}
+		case 3: // Category
+			if key := tr.Strings[ev.Args[trace.ArgUserLogKeyID]]; key != "" {
+				txt.Link(key, logKey(key))
+			}
+		case 4: // Message
+			txt.Span(tr.Strings[ev.Args[trace.ArgUserLogMessage]])
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	// XXX the widths depend on the font and scaling
+	columns := []theme.TableListColumn{
+		{Name: "Time", MinWidth: 200, MaxWidth: 200},
+		{Name: "Goroutine", MinWidth: 120, MaxWidth: 120},
+		{Name: "Task", MinWidth: 200, MaxWidth: 200},
+		{Name: "Category", MinWidth: 150, MaxWidth: 150},
+		{Name: "Message"},
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns,
+		List:          &lp.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(lp.filtered), cellFn)
+	lp.texts.Truncate(txtCnt)
+	return dims
+}
@@ -8,7 +8,6 @@ import (
 
 	"honnef.co/go/gotraceui/layout"
 	"honnef.co/go/gotraceui/theme"
-	"honnef.co/go/gotraceui/trace"
 	"honnef.co/go/gotraceui/trace/ptrace"
 )
 
@@ -23,12 +22,26 @@ func machineTrack0SpanLabel(spans ptrace.Spans, tr *Trace, out []string) []strin
 		labels := tr.processorSpanLabels(p)
 		return append(out, labels...)
 	case ptrace.StateBlockedSyscall:
+		if isCgoCall(tr, s) {
+			return append(out, "cgo")
+		}
 		return append(out, "syscall")
 	default:
 		panic(fmt.Sprintf("unexpected state %d", s.State))
 	}
 }
 
+// isCgoCall reports whether a machine's blocking syscall span is a cgo call. The runtime treats cgo calls like
+// syscalls, which is why they can block Ms the same way.
+func isCgoCall(tr *Trace, s ptrace.Span) bool {
+	for _, pc := range tr.Stacks[tr.Event(s.Event).StkID] {
+		if tr.PCs[pc].Fn == "runtime.cgocall" {
+			return true
+		}
+	}
+	return false
+}
+
 func machineTrack0SpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace, state SpanTooltipState) layout.Dimensions {
 	var label string
 	if state.spans.Len() == 1 {
@@ -38,7 +51,12 @@ func machineTrack0SpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace,
 		case ptrace.StateRunningP:
 			label = local.Sprintf("Processor %d\n", ev.P)
 		case ptrace.StateBlockedSyscall:
-			label = "In blocking syscall\n"
+			if isCgoCall(tr, s) {
+				label = "In blocking cgo call\n"
+			} else {
+				label = "In blocking syscall\n"
+			}
+			label += local.Sprintf("On behalf of goroutine %d\n", ev.G)
 		default:
 			panic(fmt.Sprintf("unexpected state %d", s.State))
 		}
@@ -90,7 +108,7 @@ func machineTrack1SpanColor(spans ptrace.Spans, tr *Trace) [2]colorIndex {
 		case "runtime.bgscavenge", "runtime.bgsweep", "runtime.gcBgMarkWorker":
 			return colorStateGC
 		default:
-			return stateColors[s.State]
+			return stateColor(s.State)
 		}
 	}
 
@@ -184,14 +202,13 @@ func (tt MachineTooltip) Layout(win *theme.Window, gtx layout.Context) layout.Di
 		s := tt.m.Spans.AtPtr(i)
 		d := s.Duration()
 
-		ev := tr.Events[s.Event]
-		switch ev.Type {
-		case trace.EvProcStart:
+		switch s.State {
+		case ptrace.StateRunningP:
 			procD += d
-		case trace.EvGoSysBlock:
+		case ptrace.StateBlockedSyscall:
 			syscallD += d
 		default:
-			panic(fmt.Sprintf("unexepcted event type %d", ev.Type))
+			panic(fmt.Sprintf("unexpected state %d", s.State))
 		}
 	}
 
@@ -217,9 +234,6 @@ func (tt MachineTooltip) Layout(win *theme.Window, gtx layout.Context) layout.Di
 }
 
 func NewMachineTimeline(tr *Trace, cv *Canvas, m *ptrace.Machine) *Timeline {
-	if !supportMachineTimelines {
-		panic("NewMachineWidget was called despite supportmachineActivities == false")
-	}
 	l := local.Sprintf("Machine %d", m.ID)
 	return &Timeline{
 		tracks: []Track{
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestIsCgoCall(t *testing.T) {
+	// The events behind machine syscall spans are the EvGoSysCall events that entered the syscalls.
+	tr := &Trace{Trace: &ptrace.Trace{Trace: trace.Trace{
+		Events: []trace.Event{
+			{Type: trace.EvGoSysCall, StkID: 1},
+			{Type: trace.EvGoSysCall, StkID: 2},
+			{Type: trace.EvGoSysCall},
+		},
+		Stacks: map[uint32][]uint64{
+			1: {1, 2, 3},
+			2: {4, 5},
+		},
+		PCs: map[uint64]trace.Frame{
+			1: {Fn: "runtime.entersyscall"},
+			2: {Fn: "runtime.cgocall"},
+			3: {Fn: "main._Cfunc_sleep"},
+			4: {Fn: "syscall.Syscall"},
+			5: {Fn: "os.(*File).Read"},
+		},
+	}}}
+
+	for _, test := range []struct {
+		ev   ptrace.EventID
+		want bool
+	}{
+		{0, true},
+		{1, false},
+		{2, false},
+	} {
+		s := ptrace.Span{Event: test.ev, State: ptrace.StateBlockedSyscall}
+		if got := isCgoCall(tr, s); got != test.want {
+			t.Errorf("event %d: got %t, want %t", test.ev, got, test.want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
@@ -15,7 +17,6 @@ import (
 	rdebug "runtime/debug"
 	"runtime/pprof"
 	rtrace "runtime/trace"
-	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -92,11 +93,6 @@ func debugCaching(gtx layout.Context) {
 //   leading up to starting the trace. It will in no way reflect the code that actually, historically, started the
 //   goroutine. To avoid confusion, we should remove those stacks altogether.
 
-// This boolean guards all code involving displaying machine timelines. That feature is currently broken, because the
-// trace parser only produces an event ordering that is consistent for goroutines, but not for machines. For example, it
-// may try to start a P on an M that is currently blocked on a syscall.
-const supportMachineTimelines = false
-
 var (
 	cpuprofile         string
 	memprofileLoad     string
@@ -107,6 +103,7 @@ var (
 	exitAfterParsing   bool
 	measureFrameAllocs bool
 	invalidateFrames   bool
+	patternsFile       string
 )
 
 type reusableOps struct {
@@ -129,6 +126,11 @@ func (mwin *MainWindow) openFunction(fn *ptrace.Function) {
 	mwin.openPanel(fi)
 }
 
+func (mwin *MainWindow) openTask(t *ptrace.Task) {
+	ti := NewTaskInfo(mwin, t)
+	mwin.openPanel(ti)
+}
+
 func (mwin *MainWindow) openSpan(s ptrace.Spans, tl *Timeline, tr *Track, allEvents []ptrace.EventID) {
 	var labels []string
 	var label string
@@ -150,6 +152,15 @@ func (mwin *MainWindow) openSpan(s ptrace.Spans, tl *Timeline, tr *Track, allEve
 	mwin.openPanel(si)
 }
 
+// openSearch opens the span search bar, or focuses it if it's already open.
+func (mwin *MainWindow) openSearch() {
+	if mwin.search == nil {
+		mwin.search = NewSpanSearch(mwin)
+	} else {
+		mwin.search.Focus()
+	}
+}
+
 func (mwin *MainWindow) openPanel(p theme.Panel) {
 	if mwin.panel != nil {
 		mwin.panelHistory = append(mwin.panelHistory, mwin.panel)
@@ -237,8 +248,11 @@ func shortenFunctionName(s string) string {
 type Command func(*MainWindow, layout.Context)
 
 type MainWindow struct {
-	canvas          Canvas
-	trace           *Trace
+	canvas    Canvas
+	trace     *Trace
+	traceHash string
+	// The path of the trace file, if it was opened from the command line
+	tracePath       string
 	commands        chan Command
 	explorer        *explorer.Explorer
 	showingExplorer atomic.Bool
@@ -259,9 +273,24 @@ type MainWindow struct {
 	progressStage  int
 	progressStages []string
 	ww             *theme.ListWindow
+	search         *SpanSearch
 	err            error
+	// A location to navigate to once the canvas has been laid out
+	pendingLink *deepLink
 
 	debugWindow *DebugWindow
+	// User-defined stack patterns to apply to all traces, or nil
+	userPatterns *ptrace.UserPatterns
+
+	// Computed on demand and shared by all scheduling latency panels, so that they agree on the plot to display.
+	cachedSchedulingLatencies *theme.Future[*schedulingLatencies]
+	// Needed for sorting goroutines by the time they spent in different states
+	cachedGoroutineStateTotals *theme.Future[[]goroutineSummary]
+	// The sort order to apply to goroutine timelines once cachedGoroutineStateTotals is ready
+	pendingGoroutineSort goroutineSortOrder
+	// When to save the changed timeline arrangement, or the zero time if it hasn't changed
+	arrangementSaveAt time.Time
+	arrangementSaves  arrangementSaver
 }
 
 func NewMainWindow() *MainWindow {
@@ -274,91 +303,10 @@ func NewMainWindow() *MainWindow {
 	return mwin
 }
 
-type timelineFilter struct {
-	invalid bool
-	parts   []struct {
-		prefix string
-		value  struct {
-			s string
-			n uint64
-		}
-	}
-}
-
-func newTimelineFilter(s string) theme.Filter {
-	out := &timelineFilter{}
-	for _, field := range strings.Fields(s) {
-		prefix, value, found := strings.Cut(field, ":")
-		if !found {
-			prefix, value = value, prefix
-		}
-
-		var v struct {
-			s string
-			n uint64
-		}
-		switch prefix {
-		case "gid", "pid":
-			var err error
-			v.n, err = strconv.ParseUint(value, 10, 64)
-			if err != nil {
-				out.invalid = true
-			}
-		default:
-			v.s = value
-		}
-
-		out.parts = append(out.parts, struct {
-			prefix string
-			value  struct {
-				s string
-				n uint64
-			}
-		}{prefix, v})
-	}
-	return out
-}
-
-func (f *timelineFilter) Filter(item theme.ListWindowItem) bool {
-	if f.invalid {
-		return false
-	}
-
-	for _, p := range f.parts {
-		switch p.prefix {
-		case "gid":
-			if item, ok := item.Item.(*ptrace.Goroutine); !ok || item.ID != p.value.n {
-				return false
-			}
-
-		case "pid":
-			if item, ok := item.Item.(*ptrace.Processor); !ok || uint64(item.ID) != p.value.n {
-				return false
-			}
-
-		case "":
-			ss := item.FilterLabels
-			any := false
-			for _, s := range ss {
-				if strings.Contains(s, p.value.s) {
-					any = true
-					break
-				}
-			}
-			if !any {
-				return false
-			}
-
-		default:
-			return false
-		}
-	}
-	return true
-}
-
 // OpenTrace initiates loading of a trace. It changes the state to loadingTrace, loads the trace, and notifies the
 // window when it's done. OpenTrace should be called from a different goroutine than the render loop.
-func (mwin *MainWindow) OpenTrace(r io.Reader) {
+// OpenTrace loads the trace from r. path is the absolute path of the trace file, or the empty string if it isn't known.
+func (mwin *MainWindow) OpenTrace(r io.Reader, path string) {
 	// Unset the memory limit in case we've already loaded a trace but this trace needs more memory.
 	rdebug.SetMemoryLimit(-1)
 
@@ -398,6 +346,7 @@ func (mwin *MainWindow) OpenTrace(r io.Reader) {
 	runtime.ReadMemStats(&mem)
 	limit := int64(mem.Sys-mem.HeapReleased) + 1024*1024*1024 // 1 GiB
 	rdebug.SetMemoryLimit(limit)
+	res.path = path
 	mwin.LoadTrace(res)
 }
 
@@ -452,9 +401,11 @@ func (mwin *MainWindow) SetProgressLossy(p float64) {
 }
 
 func (mwin *MainWindow) LoadTrace(res loadTraceResult) {
-	mwin.commands <- func(mwin *MainWindow, _ layout.Context) {
+	mwin.commands <- func(mwin *MainWindow, gtx layout.Context) {
 		mwin.loadTraceImpl(res)
 		mwin.setState("main")
+		mwin.restoreTimelineArrangement(gtx)
+		mwin.restoreSavedSession(gtx)
 	}
 }
 
@@ -489,20 +440,21 @@ func (mwin *MainWindow) OpenLink(l Link) {
 		case *FunctionLink:
 			mwin.openFunction(l.Fn)
 
-		case *TimestampLink:
-			d := mwin.canvas.End() - mwin.canvas.start
-			var off trace.Timestamp
-			switch mwin.canvas.axis.anchor {
-			case AxisAnchorNone:
-				off = mwin.canvas.pxToTs(mwin.canvas.axis.position) - mwin.canvas.start
-			case AxisAnchorStart:
-				off = 0
-			case AxisAnchorCenter:
-				off = d / 2
-			case AxisAnchorEnd:
-				off = d
+		case *TaskLink:
+			switch l.Kind {
+			case TaskLinkKindOpen:
+				mwin.openTask(l.Task)
+			case TaskLinkKindScroll:
+				mwin.canvas.scrollToTimeline(gtx, l.Task)
+			case TaskLinkKindZoom:
+				y := mwin.canvas.timelineY(gtx, l.Task)
+				mwin.canvas.navigateToStartAndEnd(gtx, l.Task.Start, l.Task.End, y)
+			default:
+				panic(l.Kind)
 			}
-			mwin.canvas.navigateTo(gtx, l.Ts-off, mwin.canvas.nsPerPx, mwin.canvas.y)
+
+		case *TimestampLink:
+			mwin.canvas.navigateToTimestamp(gtx, l.Ts)
 
 		case *SpansLink:
 			switch l.Kind {
@@ -543,8 +495,11 @@ func PlainLabel(s string) func() string { return func() string { return s } }
 
 type MainMenu struct {
 	File struct {
-		OpenTrace theme.MenuItem
-		Quit      theme.MenuItem
+		OpenTrace     theme.MenuItem
+		SaveSession   theme.MenuItem
+		ExportSession theme.MenuItem
+		OpenSession   theme.MenuItem
+		Quit          theme.MenuItem
 	}
 
 	Display struct {
@@ -553,13 +508,46 @@ type MainMenu struct {
 		ZoomToFit            theme.MenuItem
 		JumpToBeginning      theme.MenuItem
 		HighlightSpans       theme.MenuItem
+		SearchSpans          theme.MenuItem
 		ToggleCompactDisplay theme.MenuItem
 		ToggleTimelineLabels theme.MenuItem
 		ToggleStackTracks    theme.MenuItem
+		ToggleLogMarkers     theme.MenuItem
+		ToggleFlowArrows     theme.MenuItem
+		HideIdleProcessors   theme.MenuItem
+		HideRuntimeGs        theme.MenuItem
+		ShowHiddenTimelines  theme.MenuItem
+		ResetTimelines       theme.MenuItem
+		ShowAllPlots         theme.MenuItem
+		CopyLink             theme.MenuItem
+		ShowBookmarks        theme.MenuItem
+		NextBookmark         theme.MenuItem
+		PrevBookmark         theme.MenuItem
+	}
+
+	Sort struct {
+		GoroutinesByID       theme.MenuItem
+		GoroutinesByCreation theme.MenuItem
+		GoroutinesByFunction theme.MenuItem
+		GoroutinesByRunning  theme.MenuItem
+		GoroutinesByBlocked  theme.MenuItem
+		GoroutinesByReady    theme.MenuItem
+		GoroutinesBySpans    theme.MenuItem
 	}
 
 	Analyze struct {
 		OpenHeatmap theme.MenuItem
+		OpenTasks   theme.MenuItem
+		OpenRegions theme.MenuItem
+		OpenLogs    theme.MenuItem
+
+		OpenSchedulingLatency theme.MenuItem
+		OpenGC                theme.MenuItem
+		OpenGCTax             theme.MenuItem
+		OpenSyscalls          theme.MenuItem
+		OpenNetwork           theme.MenuItem
+		OpenIdleProcessors    theme.MenuItem
+		OpenCreationTree      theme.MenuItem
 	}
 
 	Debug struct {
@@ -576,18 +564,56 @@ func NewMainMenu(mwin *MainWindow, win *theme.Window) *MainMenu {
 	m.File.Quit = theme.MenuItem{Label: PlainLabel("Quit")}
 
 	notMainDisabled := func() bool { return mwin.state != "main" }
+	m.File.SaveSession = theme.MenuItem{Label: PlainLabel("Save session"), Disabled: notMainDisabled}
+	m.File.ExportSession = theme.MenuItem{Label: PlainLabel("Export session…"), Disabled: notMainDisabled}
+	m.File.OpenSession = theme.MenuItem{Label: PlainLabel("Open session…"), Disabled: notMainDisabled}
 	m.Display.UndoNavigation = theme.MenuItem{Shortcut: key.ModShortcut.String() + "+Z", Label: PlainLabel("Undo previous navigation"), Disabled: notMainDisabled}
 	m.Display.ScrollToTop = theme.MenuItem{Shortcut: "Home", Label: PlainLabel("Scroll to top of canvas"), Disabled: notMainDisabled}
 	m.Display.ZoomToFit = theme.MenuItem{Shortcut: key.ModShortcut.String() + "+Home", Label: PlainLabel("Zoom to fit visible timelines"), Disabled: notMainDisabled}
 	m.Display.JumpToBeginning = theme.MenuItem{Shortcut: "Shift+Home", Label: PlainLabel("Jump to beginning of timeline"), Disabled: notMainDisabled}
 	m.Display.HighlightSpans = theme.MenuItem{Shortcut: "H", Label: PlainLabel("Highlight spans…"), Disabled: notMainDisabled}
+	m.Display.SearchSpans = theme.MenuItem{Shortcut: "F", Label: PlainLabel("Search spans…"), Disabled: notMainDisabled}
 	m.Display.ToggleCompactDisplay = theme.MenuItem{Shortcut: "C", Label: ToggleLabel("Disable compact display", "Enable compact display", &mwin.canvas.timeline.compact), Disabled: notMainDisabled}
 	m.Display.ToggleTimelineLabels = theme.MenuItem{Shortcut: "X", Label: ToggleLabel("Hide timeline labels", "Show timeline labels", &mwin.canvas.timeline.displayAllLabels), Disabled: notMainDisabled}
 	m.Display.ToggleStackTracks = theme.MenuItem{Shortcut: "S", Label: ToggleLabel("Hide stack frames", "Show stack frames", &mwin.canvas.timeline.displayStackTracks), Disabled: notMainDisabled}
+	m.Display.ToggleFlowArrows = theme.MenuItem{Shortcut: "A", Label: ToggleLabel("Hide all flow arrows", "Show all flow arrows", &mwin.canvas.timeline.displayAllFlows), Disabled: notMainDisabled}
+	m.Display.HideIdleProcessors = theme.MenuItem{Label: ToggleLabel("Show idle processors", "Hide idle processors", &mwin.canvas.arrangement.hideIdleProcessors), Disabled: notMainDisabled}
+	m.Display.HideRuntimeGs = theme.MenuItem{Label: ToggleLabel("Show runtime goroutines", "Hide runtime goroutines", &mwin.canvas.arrangement.hideRuntimeGoroutines), Disabled: notMainDisabled}
+	m.Display.ShowHiddenTimelines = theme.MenuItem{Label: PlainLabel("Show all hidden timelines"), Disabled: notMainDisabled}
+	m.Display.ResetTimelines = theme.MenuItem{Label: PlainLabel("Reset timeline arrangement"), Disabled: notMainDisabled}
+	m.Display.ShowAllPlots = theme.MenuItem{Label: PlainLabel("Show all plots"), Disabled: notMainDisabled}
+	m.Display.ToggleLogMarkers = theme.MenuItem{Shortcut: "L", Label: ToggleLabel("Hide log markers", "Show log markers", &mwin.canvas.timeline.displayLogMarkers), Disabled: notMainDisabled}
+	// Traces opened via the file dialog don't have a known path, and a link without one wouldn't be of use.
+	m.Display.CopyLink = theme.MenuItem{Label: PlainLabel("Copy link to this view"), Disabled: func() bool { return notMainDisabled() || mwin.tracePath == "" }}
+	m.Display.ShowBookmarks = theme.MenuItem{Label: PlainLabel("Show bookmarks"), Disabled: notMainDisabled}
+	m.Display.NextBookmark = theme.MenuItem{Shortcut: ".", Label: PlainLabel("Go to next bookmark"), Disabled: notMainDisabled}
+	m.Display.PrevBookmark = theme.MenuItem{Shortcut: ",", Label: PlainLabel("Go to previous bookmark"), Disabled: notMainDisabled}
+
+	// The item for the current sort order is disabled.
+	sortDisabled := func(order goroutineSortOrder) func() bool {
+		return func() bool { return notMainDisabled() || mwin.canvas.arrangement.goroutineSort == order }
+	}
+	m.Sort.GoroutinesByID = theme.MenuItem{Label: PlainLabel("Sort goroutines by ID"), Disabled: sortDisabled(goroutineSortID)}
+	m.Sort.GoroutinesByCreation = theme.MenuItem{Label: PlainLabel("Sort goroutines by creation time"), Disabled: sortDisabled(goroutineSortCreation)}
+	m.Sort.GoroutinesByFunction = theme.MenuItem{Label: PlainLabel("Sort goroutines by function name"), Disabled: sortDisabled(goroutineSortFunction)}
+	m.Sort.GoroutinesByRunning = theme.MenuItem{Label: PlainLabel("Sort goroutines by running time"), Disabled: sortDisabled(goroutineSortRunning)}
+	m.Sort.GoroutinesByBlocked = theme.MenuItem{Label: PlainLabel("Sort goroutines by blocked time"), Disabled: sortDisabled(goroutineSortBlocked)}
+	m.Sort.GoroutinesByReady = theme.MenuItem{Label: PlainLabel("Sort goroutines by ready time"), Disabled: sortDisabled(goroutineSortReady)}
+	m.Sort.GoroutinesBySpans = theme.MenuItem{Label: PlainLabel("Sort goroutines by number of spans"), Disabled: sortDisabled(goroutineSortSpans)}
 
 	m.Debug.Memprofile = theme.MenuItem{Label: PlainLabel("Write memory profile")}
 
 	m.Analyze.OpenHeatmap = theme.MenuItem{Label: PlainLabel("Open processor utilization heatmap"), Disabled: notMainDisabled}
+	m.Analyze.OpenTasks = theme.MenuItem{Label: PlainLabel("Show tasks"), Disabled: notMainDisabled}
+	m.Analyze.OpenRegions = theme.MenuItem{Label: PlainLabel("Show user regions"), Disabled: notMainDisabled}
+	m.Analyze.OpenLogs = theme.MenuItem{Label: PlainLabel("Show user logs"), Disabled: notMainDisabled}
+	m.Analyze.OpenSchedulingLatency = theme.MenuItem{Label: PlainLabel("Show scheduling latency"), Disabled: notMainDisabled}
+	m.Analyze.OpenGC = theme.MenuItem{Label: PlainLabel("Show GC cycles"), Disabled: notMainDisabled}
+	m.Analyze.OpenGCTax = theme.MenuItem{Label: PlainLabel("Show GC tax"), Disabled: notMainDisabled}
+	m.Analyze.OpenSyscalls = theme.MenuItem{Label: PlainLabel("Show syscalls"), Disabled: notMainDisabled}
+	m.Analyze.OpenNetwork = theme.MenuItem{Label: PlainLabel("Show network I/O"), Disabled: notMainDisabled}
+	m.Analyze.OpenIdleProcessors = theme.MenuItem{Label: PlainLabel("Show idle processors"), Disabled: notMainDisabled}
+	m.Analyze.OpenCreationTree = theme.MenuItem{Label: PlainLabel("Show goroutine creation tree"), Disabled: notMainDisabled}
 
 	m.menu = &theme.Menu{
 		Groups: []theme.MenuGroup{
@@ -595,6 +621,9 @@ func NewMainMenu(mwin *MainWindow, win *theme.Window) *MainMenu {
 				Label: "File",
 				Items: []theme.Widget{
 					theme.NewMenuItemStyle(win.Theme, &m.File.OpenTrace).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.File.SaveSession).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.File.ExportSession).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.File.OpenSession).Layout,
 					theme.NewMenuItemStyle(win.Theme, &m.File.Quit).Layout,
 				},
 			},
@@ -613,20 +642,69 @@ func NewMainMenu(mwin *MainWindow, win *theme.Window) *MainMenu {
 					theme.MenuDivider(win.Theme).Layout,
 
 					theme.NewMenuItemStyle(win.Theme, &m.Display.HighlightSpans).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Display.SearchSpans).Layout,
 
 					theme.MenuDivider(win.Theme).Layout,
 
 					theme.NewMenuItemStyle(win.Theme, &m.Display.ToggleCompactDisplay).Layout,
 					theme.NewMenuItemStyle(win.Theme, &m.Display.ToggleTimelineLabels).Layout,
 					theme.NewMenuItemStyle(win.Theme, &m.Display.ToggleStackTracks).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Display.ToggleLogMarkers).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Display.ToggleFlowArrows).Layout,
+
+					theme.MenuDivider(win.Theme).Layout,
+
+					theme.NewMenuItemStyle(win.Theme, &m.Display.HideIdleProcessors).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Display.HideRuntimeGs).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Display.ShowHiddenTimelines).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Display.ResetTimelines).Layout,
+
+					theme.MenuDivider(win.Theme).Layout,
+
+					theme.NewMenuItemStyle(win.Theme, &m.Display.ShowAllPlots).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Display.CopyLink).Layout,
+
+					theme.MenuDivider(win.Theme).Layout,
+
+					theme.NewMenuItemStyle(win.Theme, &m.Display.ShowBookmarks).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Display.NextBookmark).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Display.PrevBookmark).Layout,
 					// TODO(dh): add items for STW and GC overlays
 					// TODO(dh): add item for tooltip display
 				},
 			},
+			{
+				Label: "Sort",
+				Items: []theme.Widget{
+					theme.NewMenuItemStyle(win.Theme, &m.Sort.GoroutinesByID).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Sort.GoroutinesByCreation).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Sort.GoroutinesByFunction).Layout,
+
+					theme.MenuDivider(win.Theme).Layout,
+
+					theme.NewMenuItemStyle(win.Theme, &m.Sort.GoroutinesByRunning).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Sort.GoroutinesByBlocked).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Sort.GoroutinesByReady).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Sort.GoroutinesBySpans).Layout,
+				},
+			},
 			{
 				Label: "Analyze",
 				Items: []theme.Widget{
 					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenHeatmap).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenTasks).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenRegions).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenLogs).Layout,
+
+					theme.MenuDivider(win.Theme).Layout,
+
+					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenSchedulingLatency).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenGC).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenGCTax).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenSyscalls).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenNetwork).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenIdleProcessors).Layout,
+					theme.NewMenuItemStyle(win.Theme, &m.Analyze.OpenCreationTree).Layout,
 				},
 			},
 		},
@@ -644,8 +722,8 @@ func NewMainMenu(mwin *MainWindow, win *theme.Window) *MainMenu {
 	return m
 }
 
-func displayHighlightSpansDialog(win *theme.Window, filter *Filter) {
-	hd := HighlightDialog(win, filter)
+func displayHighlightSpansDialog(win *theme.Window, tr *Trace, filter *Filter) {
+	hd := HighlightDialog(win, tr, filter)
 	win.SetModal(func(win *theme.Window, gtx layout.Context) layout.Dimensions {
 		return theme.Dialog(win.Theme, "Highlight spans").Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
 			gtx.Constraints.Min = gtx.Constraints.Constrain(image.Pt(1000, 500))
@@ -760,6 +838,22 @@ func (mwin *MainWindow) Run(win *app.Window) error {
 						win.Menu.Close()
 						mwin.showFileOpenDialog()
 					}
+					if mainMenu.File.SaveSession.Clicked() {
+						win.Menu.Close()
+						if path, err := mwin.saveSession(); err != nil {
+							win.ShowNotification(gtx, fmt.Sprintf("Couldn't save session: %s", err))
+						} else {
+							win.ShowNotification(gtx, fmt.Sprintf("Saved session to %s", path))
+						}
+					}
+					if mainMenu.File.ExportSession.Clicked() {
+						win.Menu.Close()
+						mwin.showSessionExportDialog(gtx)
+					}
+					if mainMenu.File.OpenSession.Clicked() {
+						win.Menu.Close()
+						mwin.showSessionOpenDialog()
+					}
 
 					switch mwin.state {
 					case "empty":
@@ -842,18 +936,30 @@ func (mwin *MainWindow) Run(win *app.Window) error {
 									switch ev.Name {
 									case "G":
 										mwin.ww = theme.NewListWindow(mwin.twin.Theme)
-										items := make([]theme.ListWindowItem, 0, len(mwin.canvas.timelines))
+										items := make([]theme.ListWindowItem, 0, len(mwin.canvas.allTimelines))
 										items = append(items,
 											theme.ListWindowItem{
-												Item:  mwin.canvas.timelines[0].item,
-												Label: mwin.canvas.timelines[0].label,
+												Item:  mwin.canvas.gcTimeline.item,
+												Label: mwin.canvas.gcTimeline.label,
 											},
 
 											theme.ListWindowItem{
-												Item:  mwin.canvas.timelines[1].item,
-												Label: mwin.canvas.timelines[1].label,
+												Item:  mwin.canvas.stwTimeline.item,
+												Label: mwin.canvas.stwTimeline.label,
 											},
 										)
+										for _, m := range mwin.trace.Machines {
+											items = append(items, theme.ListWindowItem{
+												Item:  m,
+												Label: local.Sprintf("machine %d", m.ID),
+												// Allow queries like "machine 1234" and "m1234" to work.
+												FilterLabels: []string{
+													fmt.Sprintf("%d", m.ID),
+													fmt.Sprintf("m%d", m.ID),
+													"machine",
+												},
+											})
+										}
 										for _, p := range mwin.trace.Processors {
 											items = append(items, theme.ListWindowItem{
 												Item:         p,
@@ -875,8 +981,24 @@ func (mwin *MainWindow) Run(win *app.Window) error {
 												FilterLabels: mwin.trace.goroutineFilterLabels(g),
 											})
 										}
+										for _, t := range mwin.trace.Tasks {
+											items = append(items, theme.ListWindowItem{
+												Item:  t,
+												Label: taskLabel(t),
+												// Allow queries like "task 1234" and "task myname" to work.
+												FilterLabels: []string{
+													fmt.Sprintf("%d", t.ID),
+													t.Name,
+													strings.ToLower(t.Name),
+													"task",
+												},
+											})
+										}
 										mwin.ww.SetItems(items)
-										mwin.ww.BuildFilter = newTimelineFilter
+										cache := &timelineQueryCache{}
+										mwin.ww.BuildFilter = func(s string) (theme.Filter, error) {
+											return newTimelineFilter(mwin.trace, cache, s)
+										}
 										win.SetModal(func(win *theme.Window, gtx layout.Context) layout.Dimensions {
 											return theme.Dialog(win.Theme, "Go to timeline").Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
 												gtx.Constraints.Max = gtx.Constraints.Constrain(image.Pt(1000, 500))
@@ -885,7 +1007,19 @@ func (mwin *MainWindow) Run(win *app.Window) error {
 										})
 
 									case "H":
-										displayHighlightSpansDialog(win, &mwin.canvas.timeline.filter)
+										displayHighlightSpansDialog(win, mwin.trace, &mwin.canvas.timeline.filter)
+
+									case "F":
+										mwin.openSearch()
+
+									case "N":
+										if mwin.search != nil {
+											if ev.Modifiers.Contain(key.ModShift) {
+												mwin.search.Prev()
+											} else {
+												mwin.search.Next()
+											}
+										}
 									}
 								}
 							}
@@ -909,7 +1043,11 @@ func (mwin *MainWindow) Run(win *app.Window) error {
 						}
 						if mainMenu.Display.HighlightSpans.Clicked() {
 							win.Menu.Close()
-							displayHighlightSpansDialog(win, &mwin.canvas.timeline.filter)
+							displayHighlightSpansDialog(win, mwin.trace, &mwin.canvas.timeline.filter)
+						}
+						if mainMenu.Display.SearchSpans.Clicked() {
+							win.Menu.Close()
+							mwin.openSearch()
 						}
 						if mainMenu.Display.ToggleCompactDisplay.Clicked() {
 							win.Menu.Close()
@@ -923,10 +1061,112 @@ func (mwin *MainWindow) Run(win *app.Window) error {
 							win.Menu.Close()
 							mwin.canvas.ToggleStackTracks()
 						}
+						if mainMenu.Display.ToggleLogMarkers.Clicked() {
+							win.Menu.Close()
+							mwin.canvas.ToggleLogMarkers()
+						}
+						if mainMenu.Display.ToggleFlowArrows.Clicked() {
+							win.Menu.Close()
+							mwin.canvas.ToggleFlowArrows()
+						}
+						if mainMenu.Display.HideIdleProcessors.Clicked() {
+							win.Menu.Close()
+							mwin.canvas.ToggleIdleProcessors()
+						}
+						if mainMenu.Display.HideRuntimeGs.Clicked() {
+							win.Menu.Close()
+							mwin.canvas.ToggleRuntimeGoroutines()
+						}
+						for _, item := range [...]struct {
+							item  *theme.MenuItem
+							order goroutineSortOrder
+						}{
+							{&mainMenu.Sort.GoroutinesByID, goroutineSortID},
+							{&mainMenu.Sort.GoroutinesByCreation, goroutineSortCreation},
+							{&mainMenu.Sort.GoroutinesByFunction, goroutineSortFunction},
+							{&mainMenu.Sort.GoroutinesByRunning, goroutineSortRunning},
+							{&mainMenu.Sort.GoroutinesByBlocked, goroutineSortBlocked},
+							{&mainMenu.Sort.GoroutinesByReady, goroutineSortReady},
+							{&mainMenu.Sort.GoroutinesBySpans, goroutineSortSpans},
+						} {
+							if item.item.Clicked() {
+								win.Menu.Close()
+								mwin.pendingGoroutineSort = item.order
+							}
+						}
+						if mainMenu.Display.ShowHiddenTimelines.Clicked() {
+							win.Menu.Close()
+							mwin.canvas.ShowAllTimelines()
+						}
+						if mainMenu.Display.ResetTimelines.Clicked() {
+							win.Menu.Close()
+							mwin.canvas.ResetTimelineArrangement()
+						}
+						if mainMenu.Display.ShowAllPlots.Clicked() {
+							win.Menu.Close()
+							mwin.canvas.ShowAllPlots()
+						}
+						if mainMenu.Display.CopyLink.Clicked() {
+							win.Menu.Close()
+							mwin.win.WriteClipboard(mwin.currentDeepLink().commandLine(mwin.tracePath))
+							win.ShowNotification(gtx, "Copied link to clipboard")
+						}
+						if mainMenu.Display.ShowBookmarks.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewBookmarksPanel(mwin))
+						}
+						if mainMenu.Display.NextBookmark.Clicked() {
+							win.Menu.Close()
+							mwin.canvas.cycleBookmarks(gtx, 1)
+						}
+						if mainMenu.Display.PrevBookmark.Clicked() {
+							win.Menu.Close()
+							mwin.canvas.cycleBookmarks(gtx, -1)
+						}
 						if mainMenu.Analyze.OpenHeatmap.Clicked() {
 							win.Menu.Close()
 							mwin.openHeatmap()
 						}
+						if mainMenu.Analyze.OpenTasks.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewTasksPanel(mwin))
+						}
+						if mainMenu.Analyze.OpenRegions.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewRegionsPanel(mwin))
+						}
+						if mainMenu.Analyze.OpenLogs.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewLogsPanel(mwin))
+						}
+						if mainMenu.Analyze.OpenSchedulingLatency.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewSchedulingLatencyPanel(mwin))
+						}
+						if mainMenu.Analyze.OpenGC.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewGCPanel(mwin))
+						}
+						if mainMenu.Analyze.OpenGCTax.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewGCTaxPanel(mwin, nil))
+						}
+						if mainMenu.Analyze.OpenSyscalls.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewSyscallsPanel(mwin))
+						}
+						if mainMenu.Analyze.OpenNetwork.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewNetworkPanel(mwin))
+						}
+						if mainMenu.Analyze.OpenIdleProcessors.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewIdleProcessorsPanel(mwin))
+						}
+						if mainMenu.Analyze.OpenCreationTree.Clicked() {
+							win.Menu.Close()
+							mwin.openPanel(NewCreationTreePanel(mwin, nil))
+						}
 						if mainMenu.Debug.Memprofile.Clicked() {
 							win.Menu.Close()
 							path, err := func() (string, error) {
@@ -957,7 +1197,7 @@ func (mwin *MainWindow) Run(win *app.Window) error {
 							}
 						}
 
-						key.InputOp{Tag: &shortcuts, Keys: "G|H"}.Add(gtx.Ops)
+						key.InputOp{Tag: &shortcuts, Keys: "G|H|F|(Shift)-N"}.Add(gtx.Ops)
 
 						if mwin.ww != nil {
 							if item, ok := mwin.ww.Confirmed(); ok {
@@ -975,18 +1215,58 @@ func (mwin *MainWindow) Run(win *app.Window) error {
 						mwin.debugWindow.cvY.addValue(gtx.Now, float64(mwin.canvas.y))
 
 						var dims layout.Dimensions
-						if mwin.panel == nil {
-							dims = mwin.canvas.Layout(win, gtx)
+						layoutMain := func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+							if mwin.panel == nil {
+								return mwin.canvas.Layout(win, gtx)
+							} else {
+								return theme.Resize(win.Theme, &resize).Layout(win, gtx, mwin.canvas.Layout, mwin.panel.Layout)
+							}
+						}
+						if mwin.search == nil {
+							dims = layoutMain(win, gtx)
 						} else {
-							dims = theme.Resize(win.Theme, &resize).Layout(win, gtx, mwin.canvas.Layout, mwin.panel.Layout)
+							dims = layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+								layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+									return mwin.search.Layout(win, gtx)
+								}),
+								layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+									return layoutMain(win, gtx)
+								}),
+							)
+							if mwin.search.Closed() {
+								mwin.search = nil
+							}
 						}
 
 						for _, g := range mwin.canvas.clickedGoroutineTimelines {
 							mwin.openGoroutine(g)
 						}
+						for _, t := range mwin.canvas.clickedTaskTimelines {
+							mwin.openTask(t)
+						}
 						for _, clicked := range mwin.canvas.clickedSpans {
 							mwin.openSpan(clicked.Spans, clicked.Timeline, clicked.Track, clicked.AllEvents)
 						}
+						if start, end, ok := mwin.canvas.SelectedRange(); ok {
+							mwin.openPanel(NewRangePanel(mwin, start, end))
+						}
+						mwin.applyPendingGoroutineSort()
+						if mwin.canvas.arrangement.changed {
+							mwin.canvas.arrangement.changed = false
+							mwin.arrangementSaveAt = gtx.Now.Add(arrangementSaveDelay)
+						}
+						if !mwin.arrangementSaveAt.IsZero() {
+							if gtx.Now.Before(mwin.arrangementSaveAt) {
+								op.InvalidateOp{At: mwin.arrangementSaveAt}.Add(gtx.Ops)
+							} else {
+								mwin.saveTimelineArrangement()
+							}
+						}
+
+						if mwin.pendingLink != nil && mwin.canvas.width != 0 {
+							mwin.navigateToDeepLink(gtx, *mwin.pendingLink)
+							mwin.pendingLink = nil
+						}
 
 						return dims
 
@@ -1025,20 +1305,37 @@ func (mwin *MainWindow) showFileOpenDialog() {
 				return
 			}
 			defer rc.Close()
-			mwin.OpenTrace(rc)
+			// Some platforms give us the actual file, and with it the path we need for session files and links.
+			var path string
+			if f, ok := rc.(*os.File); ok {
+				if abs, err := filepath.Abs(f.Name()); err == nil {
+					path = abs
+				}
+			}
+			mwin.OpenTrace(rc, path)
 		}()
 	}
 }
 
 func (mwin *MainWindow) loadTraceImpl(res loadTraceResult) {
+	if !mwin.arrangementSaveAt.IsZero() {
+		// Don't lose the previous trace's arrangement.
+		mwin.saveTimelineArrangement()
+	}
 	NewCanvasInto(&mwin.canvas, mwin.debugWindow, res.trace)
 	mwin.canvas.start = res.start
-	mwin.canvas.memoryGraph = res.plot
-	mwin.canvas.timelines = append(mwin.canvas.timelines, res.timelines...)
+	mwin.canvas.plots = res.plots
+	mwin.canvas.addTimelines(res.timelines)
 	mwin.trace = res.trace
+	mwin.traceHash = res.hash
+	mwin.tracePath = res.path
+	mwin.pendingLink = nil
 	mwin.panel = nil
 	mwin.panelHistory = nil
 	mwin.ww = nil
+	mwin.cachedSchedulingLatencies = nil
+	mwin.cachedGoroutineStateTotals = nil
+	mwin.pendingGoroutineSort = goroutineSortNone
 }
 
 type durationNumberFormat uint8
@@ -1221,16 +1518,25 @@ func formatTimestamp(ts trace.Timestamp) string {
 }
 
 func openTraceFromCmdline(mwin *MainWindow) {
-	f, err := os.Open(flag.Args()[0])
+	path := flag.Args()[0]
+	f, err := os.Open(path)
 	if err != nil {
 		mwin.SetError(fmt.Errorf("couldn't load trace: %w", err))
 		return
 	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
 	// Set state explicitly so user doesn't see a flash of the start state.
 	mwin.SetState("loadingTrace")
 	go func() {
 		defer f.Close()
-		mwin.OpenTrace(f)
+		mwin.OpenTrace(f, path)
+		mwin.commands <- func(mwin *MainWindow, gtx layout.Context) {
+			if !cmdlineLink.isZero() {
+				mwin.openDeepLinkPanel(gtx, cmdlineLink)
+			}
+		}
 	}()
 }
 
@@ -1311,6 +1617,8 @@ func main() {
 	flag.BoolVar(&exitAfterParsing, "debug.exit-after-parsing", false, "Exit after parsing trace")
 	flag.BoolVar(&measureFrameAllocs, "debug.measure-frame-allocs", false, "Measure the number of allocations per frame")
 	flag.BoolVar(&invalidateFrames, "debug.invalidate-frames", false, "Invalidate frame after drawing it")
+	flag.StringVar(&patternsFile, "patterns", "", "Load user-defined stack patterns from this file (default: patterns.json in the gotraceui config directory)")
+	registerDeepLinkFlags(flag.CommandLine, &cmdlineLink)
 	fv := flag.Bool("version", false, "Print version and exit")
 	fdv := flag.Bool("debug.version", false, "Print extended version information and exit")
 	flag.Parse()
@@ -1323,6 +1631,21 @@ func main() {
 		return
 	}
 
+	if err := cmdlineLink.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if !cmdlineLink.isZero() && len(flag.Args()) == 0 {
+		fmt.Fprintln(os.Stderr, "-at, -span, -goroutine and -panel require a trace file")
+		os.Exit(2)
+	}
+
+	up, err := loadUserPatterns(patternsFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	go func() {
 		if cpuprofile != "" {
 			f, err := os.Create(cpuprofile)
@@ -1343,6 +1666,7 @@ func main() {
 	}()
 
 	mwin := NewMainWindow()
+	mwin.userPatterns = up
 	if debug {
 		go func() {
 			win := app.NewWindow(app.Title("gotraceui - debug window"))
@@ -1382,8 +1706,12 @@ func main() {
 }
 
 type loadTraceResult struct {
-	trace      *Trace
-	plot       Plot
+	trace *Trace
+	// The hex-encoded SHA-256 hash of the trace's contents
+	hash string
+	// The absolute path of the trace file, or the empty string if it isn't known
+	path       string
+	plots      []*Plot
 	start, end trace.Timestamp
 	timelines  []*Timeline
 }
@@ -1398,21 +1726,29 @@ func loadTrace(f io.Reader, mwin *MainWindow) (loadTraceResult, error) {
 		"Processing",
 		"Processing",
 		"Processing",
+		"Processing",
+		"Processing",
+		"Processing",
 	}
 
 	mwin.SetProgressStages(names)
 
 	mwin.SetProgressStage(0)
-	t, err := trace.Parse(f, mwin.SetProgressLossy)
+	h := sha256.New()
+	t, err := trace.Parse(io.TeeReader(f, h), mwin.SetProgressLossy)
 	if err != nil {
 		return loadTraceResult{}, err
 	}
+	// Hash any trailing data the parser didn't consume, so that the hash covers the whole file.
+	if _, err := io.Copy(h, f); err != nil {
+		return loadTraceResult{}, err
+	}
 	if exitAfterParsing {
 		return loadTraceResult{}, errExitAfterParsing
 	}
 
 	mwin.SetProgressStage(1)
-	pt, err := ptrace.Parse(t, mwin.SetProgressLossy)
+	pt, err := ptrace.Parse(t, mwin.userPatterns, mwin.SetProgressLossy)
 	if err != nil {
 		return loadTraceResult{}, err
 	}
@@ -1434,7 +1770,10 @@ func loadTrace(f io.Reader, mwin *MainWindow) (loadTraceResult, error) {
 	}
 
 	mwin.SetProgressStage(3)
-	tr := &Trace{Trace: pt}
+	idle := computeIdleEpisodes(pt, mwin.SetProgressLossy)
+
+	mwin.SetProgressStage(4)
+	tr := &Trace{Trace: pt, idleEpisodes: idle}
 	if len(pt.Goroutines) != 0 {
 		tr.allGoroutineSpanLabels = make([][]string, len(pt.Goroutines))
 		tr.allGoroutineFilterLabels = make([][]string, len(pt.Goroutines))
@@ -1477,7 +1816,7 @@ func loadTrace(f io.Reader, mwin *MainWindow) (loadTraceResult, error) {
 		}
 	}
 
-	mwin.SetProgressStage(4)
+	mwin.SetProgressStage(5)
 	if len(pt.Processors) != 0 {
 		tr.allProcessorSpanLabels = make([][]string, len(pt.Processors))
 		tr.allProcessorFilterLabels = make([][]string, len(pt.Processors))
@@ -1505,26 +1844,33 @@ func loadTrace(f io.Reader, mwin *MainWindow) (loadTraceResult, error) {
 	// TODO(dh): preallocate
 	var timelines []*Timeline
 
-	mwin.SetProgressStage(5)
-	if supportMachineTimelines {
-		for i, m := range tr.Machines {
-			timelines = append(timelines, NewMachineTimeline(tr, &mwin.canvas, m))
-			mwin.SetProgressLossy(float64(i+1) / float64(len(tr.Machines)))
-		}
+	mwin.SetProgressStage(6)
+	for i, m := range tr.Machines {
+		timelines = append(timelines, NewMachineTimeline(tr, &mwin.canvas, m))
+		mwin.SetProgressLossy(float64(i+1) / float64(len(tr.Machines)))
 	}
 
-	mwin.SetProgressStage(6)
+	mwin.SetProgressStage(7)
 	for i, proc := range tr.Processors {
 		timelines = append(timelines, NewProcessorTimeline(tr, &mwin.canvas, proc))
 		mwin.SetProgressLossy(float64(i+1) / float64(len(tr.Processors)))
 	}
 
-	mwin.SetProgressStage(7)
+	mwin.SetProgressStage(8)
 	for i, g := range tr.Goroutines {
 		timelines = append(timelines, NewGoroutineTimeline(tr, &mwin.canvas, g))
 		mwin.SetProgressLossy(float64(i+1) / float64(len(tr.Goroutines)))
 	}
 
+	mwin.SetProgressStage(9)
+	for i, t := range tr.Tasks {
+		timelines = append(timelines, NewTaskTimeline(tr, &mwin.canvas, t))
+		mwin.SetProgressLossy(float64(i+1) / float64(len(tr.Tasks)))
+	}
+
+	mwin.SetProgressStage(10)
+	goroutinesPlot, blockedPlot := goroutineCountPlots(pt, mwin.SetProgressLossy)
+
 	// We no longer need this.
 	tr.CPUSamples = nil
 
@@ -1536,7 +1882,7 @@ func loadTrace(f io.Reader, mwin *MainWindow) (loadTraceResult, error) {
 	start := trace.Timestamp(-slack)
 	end = trace.Timestamp(float64(end) + slack)
 
-	mg := Plot{
+	mg := &Plot{
 		Name: "Memory usage",
 		Unit: "bytes",
 	}
@@ -1555,6 +1901,21 @@ func loadTrace(f io.Reader, mwin *MainWindow) (loadTraceResult, error) {
 		},
 	)
 
+	gomaxprocs := &Plot{
+		Name: "GOMAXPROCS",
+		Unit: "procs",
+		// Most programs never change GOMAXPROCS, don't waste space on a flat line.
+		hidden: len(pt.Gomaxprocs) <= 1,
+	}
+	gomaxprocs.AddSeries(
+		PlotSeries{
+			Name:   "GOMAXPROCS",
+			Points: pt.Gomaxprocs,
+			Filled: false,
+			Color:  colors[colorStateActive],
+		},
+	)
+
 	var goroot, gopath string
 	for _, fn := range tr.Functions {
 		if strings.HasPrefix(fn.Fn, "runtime.") && strings.Count(fn.Fn, ".") == 1 && strings.Contains(fn.File, filepath.Join("go", "src", "runtime")) && !strings.ContainsRune(fn.Fn, os.PathSeparator) {
@@ -1599,7 +1960,8 @@ func loadTrace(f io.Reader, mwin *MainWindow) (loadTraceResult, error) {
 
 	return loadTraceResult{
 		trace:     tr,
-		plot:      mg,
+		hash:      hex.EncodeToString(h.Sum(nil)),
+		plots:     []*Plot{mg, gomaxprocs, goroutinesPlot, blockedPlot},
 		start:     start,
 		end:       end,
 		timelines: timelines,
@@ -1805,6 +2167,8 @@ func defaultLink(obj any) Link {
 		return &TimestampLink{Ts: obj}
 	case *ptrace.Function:
 		return &FunctionLink{Fn: obj}
+	case *ptrace.Task:
+		return &TaskLink{Task: obj, Kind: TaskLinkKindScroll}
 	default:
 		panic(fmt.Sprintf("unsupported type: %T", obj))
 	}
@@ -1821,6 +2185,37 @@ func handleLinkClick(win *theme.Window, mwin *MainWindow, ev TextEvent) {
 			case key.ModShift:
 				mwin.OpenLink(&GoroutineLink{Goroutine: obj, Kind: GoroutineLinkKindOpen})
 			}
+		} else if obj, ok := ev.Span.Object.(*SpanRef); ok {
+			l := &SpansLink{
+				Timeline: mwin.canvas.timelineOf(obj.Goroutine),
+				Spans:    ptrace.ToSpans([]ptrace.Span{obj.Span}),
+				Kind:     SpanLinkKindScrollAndPan,
+			}
+			if ev.Event.Modifiers == key.ModShortcut {
+				l.Kind = SpanLinkKindZoom
+			}
+			mwin.OpenLink(l)
+		} else if obj, ok := ev.Span.Object.(*ptrace.GCCycle); ok {
+			l := &SpansLink{
+				Timeline: mwin.canvas.gcTimeline,
+				Spans:    ptrace.ToSpans([]ptrace.Span{obj.Span}),
+				Kind:     SpanLinkKindScrollAndPan,
+			}
+			if ev.Event.Modifiers == key.ModShortcut {
+				l.Kind = SpanLinkKindZoom
+			}
+			mwin.OpenLink(l)
+		} else if obj, ok := ev.Span.Object.(*ptrace.Task); ok {
+			switch ev.Event.Modifiers {
+			case 0:
+				mwin.OpenLink(&TaskLink{Task: obj, Kind: TaskLinkKindScroll})
+			case key.ModShortcut:
+				mwin.OpenLink(&TaskLink{Task: obj, Kind: TaskLinkKindZoom})
+			case key.ModShift:
+				mwin.OpenLink(&TaskLink{Task: obj, Kind: TaskLinkKindOpen})
+			}
+		} else if obj, ok := ev.Span.Object.(*goroutineChildren); ok {
+			mwin.openPanel(NewCreationTreePanel(mwin, obj.Goroutine))
 		} else {
 			mwin.OpenLink(defaultLink(ev.Span.Object))
 		}
@@ -1830,6 +2225,8 @@ func handleLinkClick(win *theme.Window, mwin *MainWindow, ev TextEvent) {
 			win.SetContextMenu(goroutineLinkContextMenu(mwin, obj))
 		case *ptrace.Processor:
 			win.SetContextMenu(processorLinkContextMenu(mwin, obj))
+		case *ptrace.Task:
+			win.SetContextMenu(taskLinkContextMenu(mwin, obj))
 		}
 	}
 }
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	rtrace "runtime/trace"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/gesture"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+	"gioui.org/text"
+	"golang.org/x/exp/slices"
+)
+
+// networkGroup aggregates the time goroutines spent blocked on the network, either for one combination of span tags,
+// or for one call site.
+type networkGroup struct {
+	// Tags is zero for groups of call sites, and for the group of untagged spans.
+	Tags  ptrace.SpanTags
+	Label string
+
+	Count           int
+	Total, P99, Max time.Duration
+	Longest         SpanRef
+	// All durations, sorted in ascending order
+	Durations []time.Duration
+}
+
+func (grp *networkGroup) add(g *ptrace.Goroutine, s ptrace.Span) {
+	d := s.Duration()
+	grp.Count++
+	grp.Total += d
+	grp.Durations = append(grp.Durations, d)
+	if d > grp.Max || grp.Longest.Goroutine == nil {
+		grp.Max = d
+		grp.Longest = SpanRef{g, s}
+	}
+}
+
+func tagsLabel(tr *Trace, tags ptrace.SpanTags) string {
+	if tags == 0 {
+		return "<untagged>"
+	}
+	return strings.Join(spanTagStrings(tr, tags), ", ")
+}
+
+func callSiteLabel(site trace.Frame) string {
+	if site.Fn == "" {
+		return "<unknown>"
+	}
+	return fmt.Sprintf("%s %s:%d", site.Fn, site.File, site.Line)
+}
+
+type networkStatistics struct {
+	// Groups, sorted by total time in descending order
+	ByTags     []*networkGroup
+	ByCallSite []*networkGroup
+
+	Count int
+	Total time.Duration
+}
+
+// computeNetworkStatistics groups all spans of goroutines blocked on the network by their tags and by their call sites.
+func computeNetworkStatistics(tr *Trace, cancelled <-chan struct{}) *networkStatistics {
+	byTags := map[ptrace.SpanTags]*networkGroup{}
+	byCallSite := map[trace.Frame]*networkGroup{}
+
+	out := &networkStatistics{}
+	for i, g := range tr.Goroutines {
+		if i%1000 == 0 {
+			select {
+			case <-cancelled:
+				return nil
+			default:
+			}
+		}
+
+		for j := 0; j < g.Spans.Len(); j++ {
+			s := g.Spans.At(j)
+			if tr.BaseState(s.State) != ptrace.StateBlockedNet {
+				continue
+			}
+
+			tags := s.Tags &^ ptrace.SpanTagGC
+			grp, ok := byTags[tags]
+			if !ok {
+				grp = &networkGroup{Tags: tags, Label: tagsLabel(tr, tags)}
+				byTags[tags] = grp
+			}
+			grp.add(g, s)
+
+			var site trace.Frame
+			if stk := tr.Stacks[tr.Event(s.Event).StkID]; len(stk) != 0 {
+				site = userCallSite(tr, stk)
+			}
+			grp, ok = byCallSite[site]
+			if !ok {
+				grp = &networkGroup{Label: callSiteLabel(site)}
+				byCallSite[site] = grp
+			}
+			grp.add(g, s)
+
+			out.Count++
+			out.Total += s.Duration()
+		}
+	}
+
+	finish := func(groups []*networkGroup) {
+		for _, grp := range groups {
+			slices.Sort(grp.Durations)
+			grp.P99 = percentile(grp.Durations, 0.99)
+		}
+		slices.SortFunc(groups, func(a, b *networkGroup) bool {
+			return a.Total > b.Total
+		})
+	}
+
+	out.ByTags = make([]*networkGroup, 0, len(byTags))
+	for _, grp := range byTags {
+		out.ByTags = append(out.ByTags, grp)
+	}
+	finish(out.ByTags)
+
+	out.ByCallSite = make([]*networkGroup, 0, len(byCallSite))
+	for _, grp := range byCallSite {
+		out.ByCallSite = append(out.ByCallSite, grp)
+	}
+	finish(out.ByCallSite)
+
+	return out
+}
+
+// NetworkPanel summarizes the time goroutines spent blocked on the network.
+type NetworkPanel struct {
+	mwin        *MainWindow
+	stats       *theme.Future[*networkStatistics]
+	initialized bool
+
+	description  Description
+	tabbedState  theme.TabbedState
+	tagsList     networkGroupList
+	callSiteList networkGroupList
+
+	// The group whose histogram is being displayed
+	selected  *networkGroup
+	histLabel Text
+	hist      InteractiveHistogram
+
+	highlight      widget.PrimaryClickable
+	clearHighlight widget.PrimaryClickable
+
+	theme.PanelButtons
+}
+
+func NewNetworkPanel(mwin *MainWindow) *NetworkPanel {
+	tr := mwin.trace
+	return &NetworkPanel{
+		mwin: mwin,
+		stats: theme.NewFuture(mwin.twin, func(cancelled <-chan struct{}) *networkStatistics {
+			return computeNetworkStatistics(tr, cancelled)
+		}),
+		tagsList:     networkGroupList{byTags: true},
+		callSiteList: networkGroupList{byTags: false},
+	}
+}
+
+func (np *NetworkPanel) Title() string {
+	return "Network"
+}
+
+func (np *NetworkPanel) init(win *theme.Window, stats *networkStatistics) {
+	value := func(s *TextSpan) *theme.Future[TextSpan] {
+		return theme.Immediate(*s)
+	}
+	tb := TextBuilder{Theme: win.Theme}
+	np.description.Attributes = []DescriptionAttribute{
+		{Key: "Times blocked on the network", Value: value(tb.Span(local.Sprintf("%d", stats.Count)))},
+		{Key: "Time blocked on the network", Value: value(tb.Span(stats.Total.String()))},
+		{Key: "Tag combinations", Value: value(tb.Span(local.Sprintf("%d", len(stats.ByTags))))},
+		{Key: "Call sites", Value: value(tb.Span(local.Sprintf("%d", len(stats.ByCallSite))))},
+	}
+
+	np.hist.Config = widget.HistogramConfig{RejectOutliers: true, Bins: widget.DefaultHistogramBins}
+	if len(stats.ByTags) != 0 {
+		np.selectGroup(win, stats.ByTags[0])
+	}
+}
+
+func (np *NetworkPanel) selectGroup(win *theme.Window, grp *networkGroup) {
+	np.selected = grp
+	np.hist.Config.Start = 0
+	np.hist.Config.End = 0
+	np.computeHistogram(win)
+}
+
+func (np *NetworkPanel) computeHistogram(win *theme.Window) {
+	cfg := &np.hist.Config
+	var durations []time.Duration
+	for _, d := range np.selected.Durations {
+		if fd := widget.FloatDuration(d); fd >= cfg.Start && (cfg.End == 0 || fd <= cfg.End) {
+			durations = append(durations, d)
+		}
+	}
+	np.hist.Set(win, durations)
+}
+
+func (np *NetworkPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.NetworkPanel.Layout").End()
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	stats, ok := np.stats.Result()
+	if ok && !np.initialized {
+		np.init(win, stats)
+		np.initialized = true
+	}
+
+	tabs := []string{"Tag combinations", "Call sites", "Histogram"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			// Right-aligned buttons should be aligned with the right side of the visible panel, not the width of the
+			// panel contents, nor the infinite width of a possible surrounding list.
+			gtx.Constraints.Max.X = gtx.Constraints.Min.X
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if np.selected == nil || np.selected.Tags == 0 {
+						gtx.Queue = nil
+					}
+					return theme.Button(win.Theme, &np.highlight.Clickable, "Highlight on canvas").Layout(win, gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: 5}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return theme.Button(win.Theme, &np.clearHighlight.Clickable, "Clear highlight").Layout(win, gtx)
+				}),
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, np.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !ok {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing network statistics…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			if stats.Count == 0 {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "No goroutines blocked on the network.", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min = image.Point{}
+					return np.description.Layout(win, gtx)
+				}),
+
+				layout.Rigid(layout.Spacer{Height: 10}.Layout),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return theme.Tabbed(&np.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+						switch tabs[np.tabbedState.Current] {
+						case "Tag combinations":
+							return np.tagsList.Layout(win, gtx, stats.ByTags)
+						case "Call sites":
+							return np.callSiteList.Layout(win, gtx, stats.ByCallSite)
+						case "Histogram":
+							return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+								layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+									np.histLabel.Reset(win.Theme)
+									np.histLabel.Bold("Histogram of: ")
+									np.histLabel.Span(np.selected.Label)
+									return np.histLabel.Layout(win, gtx)
+								}),
+								layout.Rigid(layout.Spacer{Height: 5}.Layout),
+								layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+									return np.hist.Layout(win, gtx)
+								}),
+							)
+						default:
+							panic("unreachable")
+						}
+					})
+				}),
+			)
+		}),
+	)
+
+	for _, list := range [...]*networkGroupList{&np.tagsList, &np.callSiteList} {
+		for _, ev := range list.Clicked() {
+			if grp, ok := ev.Span.Object.(*networkGroup); ok {
+				if ev.Event.Type == gesture.TypeClick && ev.Event.Button == pointer.ButtonPrimary {
+					np.selectGroup(win, grp)
+					np.tabbedState.Current = slices.Index(tabs, "Histogram")
+				}
+				continue
+			}
+			handleLinkClick(win, np.mwin, ev)
+		}
+	}
+
+	for np.highlight.Clicked() {
+		if np.selected != nil && np.selected.Tags != 0 {
+			np.mwin.canvas.timeline.filter = Filter{Tags: np.selected.Tags}
+		}
+	}
+	for np.clearHighlight.Clicked() {
+		np.mwin.canvas.timeline.filter = Filter{}
+	}
+
+	if ok && np.selected != nil && np.hist.Changed() {
+		np.computeHistogram(win)
+	}
+
+	for np.PanelButtons.Backed() {
+		np.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+type networkGroupList struct {
+	// Whether the list shows groups of tag combinations, or of call sites
+	byTags bool
+
+	list        widget.List
+	spanObjects allocator[SpanRef]
+	texts       allocator[Text]
+}
+
+func (nl *networkGroupList) Layout(win *theme.Window, gtx layout.Context, groups []*networkGroup) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.networkGroupList.Layout").End()
+
+	nl.list.Axis = layout.Vertical
+	nl.spanObjects.Reset()
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < nl.texts.Len() {
+			txt = nl.texts.Ptr(txtCnt)
+		} else {
+			txt = nl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		grp := groups[row]
+		switch col {
+		case 0: // Tags or call site
+			txt.Link(grp.Label, grp)
+		case 1: // Count
+			txt.Span(local.Sprintf("%d", grp.Count))
+			txt.Alignment = text.End
+		case 2:
+			layoutDuration(txt, grp.Total)
+		case 3:
+			layoutDuration(txt, grp.P99)
+		case 4: // Max
+			value, unit := durationNumberFormatSITable.format(grp.Max)
+			txt.Link(value, nl.spanObjects.Allocate(grp.Longest))
+			txt.Span(" ")
+			s := txt.Span(unit)
+			s.Font.Variant = "Mono"
+			txt.Alignment = text.End
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	// XXX the widths depend on the font and scaling
+	columns := []theme.TableListColumn{
+		{Name: "Call site", MinWidth: 500, MaxWidth: 500},
+		{Name: "Count", MinWidth: 100, MaxWidth: 100},
+		{Name: "Total", MinWidth: 150, MaxWidth: 150},
+		{Name: "p99", MinWidth: 150, MaxWidth: 150},
+		{Name: "Max", MinWidth: 150, MaxWidth: 150},
+	}
+	if nl.byTags {
+		columns[0] = theme.TableListColumn{Name: "Tags", MinWidth: 300, MaxWidth: 300}
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns,
+		List:          &nl.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(groups), cellFn)
+	nl.texts.Truncate(txtCnt)
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (nl *networkGroupList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < nl.texts.Len(); i++ {
+		txt := nl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+// defaultPatternsPath returns the path of the pattern file that gets loaded if the -patterns flag isn't used.
+func defaultPatternsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gotraceui", "patterns.json")
+}
+
+// loadUserPatterns loads user-defined stack patterns from path. If path is empty, the default pattern file is used,
+// and it is not an error for it not to exist, in which case loadUserPatterns returns nil patterns.
+func loadUserPatterns(path string) (*ptrace.UserPatterns, error) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultPatternsPath()
+		if path == "" {
+			return nil, nil
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("couldn't load patterns: %w", err)
+	}
+	defer f.Close()
+	up, err := ptrace.ParseUserPatterns(f)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load patterns from %s: %w", path, err)
+	}
+	return up, nil
+}
+
+// userStateName returns the name of a user-defined state, if state is one.
+func (t *Trace) userStateName(state ptrace.SchedulingState) (string, bool) {
+	if !ptrace.IsUserState(state) || t.UserPatterns == nil {
+		return "", false
+	}
+	i := int(state - ptrace.StateUser0)
+	if i >= len(t.UserPatterns.StateNames) {
+		return "", false
+	}
+	return t.UserPatterns.StateNames[i], true
+}
+
+// stateName returns the name of a scheduling state, for use in the middle of sentences.
+func (t *Trace) stateName(state ptrace.SchedulingState) string {
+	if name, ok := t.userStateName(state); ok {
+		return name
+	}
+	return stateNames[state]
+}
+
+// stateNameCapitalized returns the name of a scheduling state, for use as a label.
+func (t *Trace) stateNameCapitalized(state ptrace.SchedulingState) string {
+	if name, ok := t.userStateName(state); ok {
+		return name
+	}
+	return stateNamesCapitalized[state]
+}
+
+// stateLabels returns the labels of spans in a scheduling state, from longest to shortest.
+func (t *Trace) stateLabels(state ptrace.SchedulingState) []string {
+	if name, ok := t.userStateName(state); ok {
+		return []string{name}
+	}
+	return spanStateLabels[state]
+}
+
+// userTagNames returns the names of user-defined span tags, indexed by their bit offset from ptrace.SpanTagUser0.
+func (t *Trace) userTagNames() []string {
+	if t.UserPatterns == nil {
+		return nil
+	}
+	return t.UserPatterns.TagNames
+}
@@ -25,6 +25,7 @@ import (
 	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
+	"gioui.org/x/component"
 	"golang.org/x/exp/constraints"
 	"golang.org/x/exp/slices"
 )
@@ -39,9 +40,15 @@ type PlotSeries struct {
 }
 
 type Plot struct {
-	Name   string
-	Unit   string
-	series []PlotSeries
+	Name string
+	Unit string
+	// FormatValue, if set, formats values for display in tooltips and legends. By default, values are printed as
+	// integers, followed by the unit.
+	FormatValue func(v uint64) string
+	// If Stacked is true, series are drawn on top of each other, in the order they were added. All series of a
+	// stacked plot must have points at the same timestamps.
+	Stacked bool
+	series  []PlotSeries
 
 	min uint64
 	max uint64
@@ -53,6 +60,10 @@ type Plot struct {
 	scratchStrings []string
 	hideLegends    bool
 	autoScale      bool
+	hidden         bool
+
+	// resize separates the plot from the plots below it.
+	resize component.Resize
 
 	// Used by drawOrthogonalLine to correctly overlap lines at changes in direction
 	prevDirection uint8
@@ -77,37 +88,72 @@ func (pl *Plot) AddSeries(series ...PlotSeries) {
 	pl.max = max
 }
 
+func (pl *Plot) formatValue(v uint64) string {
+	if pl.FormatValue != nil {
+		return pl.FormatValue(v)
+	}
+	return local.Sprintf("%d %s", v, pl.Unit)
+}
+
 func (pl *Plot) computeExtents(start, end trace.Timestamp) (min, max uint64) {
 	min = math.MaxUint64
 	max = 0
 
-	for _, s := range pl.series {
-		if s.disabled {
-			continue
+	if pl.Stacked {
+		// The bottom of a stacked plot is always zero.
+		min = 0
+
+		var ref []ptrace.Point
+		for _, s := range pl.series {
+			if !s.disabled {
+				ref = s.Points
+				break
+			}
 		}
-		idx := sort.Search(len(s.Points), func(i int) bool {
-			return s.Points[i].When >= start
+		idx := sort.Search(len(ref), func(i int) bool {
+			return ref[i].When >= start
 		})
 		// Decrement by one to consider a point that's out of view but extends into view
 		idx--
 		if idx < 0 {
 			idx = 0
 		}
-		for _, p := range s.Points[idx:] {
-			if p.When >= end {
-				break
+		for i := idx; i < len(ref) && ref[i].When < end; i++ {
+			if v := pl.stackedValue(len(pl.series)-1, i); v > max {
+				max = v
+			}
+		}
+	} else {
+		for _, s := range pl.series {
+			if s.disabled {
+				continue
 			}
-			if p.Value < min {
-				min = p.Value
+			idx := sort.Search(len(s.Points), func(i int) bool {
+				return s.Points[i].When >= start
+			})
+			// Decrement by one to consider a point that's out of view but extends into view
+			idx--
+			if idx < 0 {
+				idx = 0
 			}
-			if p.Value > max {
-				max = p.Value
+			for _, p := range s.Points[idx:] {
+				if p.When >= end {
+					break
+				}
+				if p.Value < min {
+					min = p.Value
+				}
+				if p.Value > max {
+					max = p.Value
+				}
 			}
 		}
 	}
 
 	if min == max {
-		min--
+		if min > 0 {
+			min--
+		}
 		max++
 	}
 
@@ -122,6 +168,18 @@ func (pl *Plot) computeExtents(start, end trace.Timestamp) (min, max uint64) {
 	return min, max
 }
 
+// stackedValue returns the sum of the values of the enabled series up to and including the i-th series, at the
+// specified point.
+func (pl *Plot) stackedValue(series int, point int) uint64 {
+	var sum uint64
+	for _, s := range pl.series[:series+1] {
+		if !s.disabled {
+			sum += s.Points[point].Value
+		}
+	}
+	return sum
+}
+
 func (pl *Plot) Layout(win *theme.Window, gtx layout.Context, cv *Canvas) layout.Dimensions {
 	defer rtrace.StartRegion(context.Background(), "main.Plot.Layout").End()
 	defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
@@ -159,11 +217,21 @@ func (pl *Plot) Layout(win *theme.Window, gtx layout.Context, cv *Canvas) layout
 
 	{
 		r := rtrace.StartRegion(context.Background(), "draw all points")
-		for _, s := range pl.series {
-			if s.disabled {
-				continue
+		if pl.Stacked {
+			// Draw the topmost series first, so that the series below it paint over the filled area.
+			for i := len(pl.series) - 1; i >= 0; i-- {
+				if pl.series[i].disabled {
+					continue
+				}
+				pl.drawPoints(gtx, cv, i)
+			}
+		} else {
+			for i, s := range pl.series {
+				if s.disabled {
+					continue
+				}
+				pl.drawPoints(gtx, cv, i)
 			}
-			pl.drawPoints(gtx, cv, s)
 		}
 		r.End()
 	}
@@ -192,7 +260,7 @@ func (pl *Plot) Layout(win *theme.Window, gtx layout.Context, cv *Canvas) layout
 				continue
 			}
 
-			lines = append(lines, local.Sprintf("%s: %d %s", s.Name, s.Points[idx].Value, pl.Unit))
+			lines = append(lines, fmt.Sprintf("%s: %s", s.Name, pl.formatValue(s.Points[idx].Value)))
 		}
 		pl.scratchStrings = lines[:0]
 
@@ -239,6 +307,24 @@ func (pl *Plot) Layout(win *theme.Window, gtx layout.Context, cv *Canvas) layout
 					pl.hideLegends = !pl.hideLegends
 				},
 			},
+			{
+				Label: PlainLabel("Hide plot"),
+				Do: func(gtx layout.Context) {
+					pl.hidden = true
+				},
+			},
+		}
+		for _, opl := range cv.plots {
+			if !opl.hidden {
+				continue
+			}
+			opl := opl
+			items = append(items, &theme.MenuItem{
+				Label: PlainLabel(fmt.Sprintf("Show %q plot", opl.Name)),
+				Do: func(gtx layout.Context) {
+					opl.hidden = false
+				},
+			})
 		}
 		for i := range pl.series {
 			s := &pl.series[i]
@@ -267,14 +353,14 @@ func (pl *Plot) Layout(win *theme.Window, gtx layout.Context, cv *Canvas) layout
 		r := rtrace.StartRegion(context.Background(), "legends")
 		// Print legends
 		rec := Record(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
-			return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, 12, local.Sprintf("%d %s", pl.max, pl.Unit), widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, 12, pl.formatValue(pl.max), widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
 		})
 		paint.FillShape(gtx.Ops, rgba(0xFFFFFFFF), clip.Rect{Max: rec.Dimensions.Size}.Op())
 		paint.ColorOp{Color: rgba(0x000000FF)}.Add(gtx.Ops)
 		rec.Layout(win, gtx)
 
 		rec = Record(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
-			return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, 12, local.Sprintf("%d %s", pl.min, pl.Unit), widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, 12, pl.formatValue(pl.min), widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
 		})
 		defer op.Offset(image.Pt(0, gtx.Constraints.Max.Y-rec.Dimensions.Size.Y)).Push(gtx.Ops).Pop()
 		paint.FillShape(gtx.Ops, rgba(0xFFFFFFFF), clip.Rect{Max: rec.Dimensions.Size}.Op())
@@ -312,10 +398,12 @@ func (pl *Plot) end(gtx layout.Context, cv *Canvas) int {
 	return timelineEnd
 }
 
-func (pl *Plot) drawPoints(gtx layout.Context, cv *Canvas, s PlotSeries) {
+func (pl *Plot) drawPoints(gtx layout.Context, cv *Canvas, seriesIdx int) {
 	defer rtrace.StartRegion(context.Background(), "draw points").End()
 	const lineWidth = 2
 
+	s := pl.series[seriesIdx]
+
 	var drawLine func(p *clip.Path, pt f32.Point, width float32)
 	if s.Filled {
 		drawLine = func(p *clip.Path, pt f32.Point, width float32) {
@@ -362,7 +450,11 @@ func (pl *Plot) drawPoints(gtx layout.Context, cv *Canvas, s PlotSeries) {
 		if idx == 0 {
 			continue
 		}
-		points[i] = f32.Pt(float32(i+canvasStart), scaleValue(values[idx-1].Value))
+		v := values[idx-1].Value
+		if pl.Stacked {
+			v = pl.stackedValue(seriesIdx, idx-1)
+		}
+		points[i] = f32.Pt(float32(i+canvasStart), scaleValue(v))
 	}
 
 	var first f32.Point
@@ -127,7 +127,7 @@ func processorTrackSpanColor(spans ptrace.Spans, tr *Trace) [2]colorIndex {
 			return colorStateGC
 		} else {
 			// TODO(dh): support goroutines that are currently doing GC assist work. this would require splitting spans, however.
-			return stateColors[s.State]
+			return stateColor(s.State)
 		}
 	}
 
@@ -165,10 +165,35 @@ func processorTrackSpanContextMenu(spans ptrace.Spans, cv *Canvas) []*theme.Menu
 	return items
 }
 
+// processorInactiveRanges returns the ranges of time during which the processor's ID was beyond GOMAXPROCS.
+func processorInactiveRanges(tr *ptrace.Trace, p *ptrace.Processor) []timeRange {
+	var out []timeRange
+	end := tr.Events[len(tr.Events)-1].Ts
+	for i, pt := range tr.Gomaxprocs {
+		if uint64(p.ID) < pt.Value {
+			continue
+		}
+		until := end
+		if i+1 < len(tr.Gomaxprocs) {
+			until = tr.Gomaxprocs[i+1].When
+		}
+		if len(out) > 0 && out[len(out)-1].end == pt.When {
+			out[len(out)-1].end = until
+		} else {
+			out = append(out, timeRange{pt.When, until})
+		}
+	}
+	return out
+}
+
 func NewProcessorTimeline(tr *Trace, cv *Canvas, p *ptrace.Processor) *Timeline {
 	l := local.Sprintf("Processor %d", p.ID)
 	return &Timeline{
-		tracks: []Track{{spans: (p.Spans)}},
+		tracks: []Track{{
+			spans:    (p.Spans),
+			inactive: processorInactiveRanges(tr.Trace, p),
+			idle:     tr.idleEpisodes.Processors[p.SeqID],
+		}},
 
 		buildTrackWidgets: func(tracks []Track) {
 			for i := range tracks {
@@ -0,0 +1,592 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+// The timeline query language is used by the "Go to timeline" dialog. A query consists of terms, which can be
+// combined with AND, OR and NOT, and grouped with parentheses. Terms that follow each other without an operator are
+// combined with AND, and AND binds more tightly than OR. The operators can also be written as &, | and !.
+//
+// The following terms are supported:
+//
+//	word                 substring match against the item's labels, such as "goroutine", "g123" or function names
+//	gid:123              goroutine with ID 123
+//	pid:1, mid:1         processor or machine with the given ID
+//	fn:regexp            goroutines whose function matches the regular expression
+//	created-by:regexp    goroutines created by a goroutine whose function matches the regular expression
+//	state:name           goroutines that spent time in the state, e.g. state:syscall or state:blocked-net
+//	active-in:[1s,2s]    timelines that were active between two points in time
+//	task:name            tasks whose names contain the string and goroutines that participated in them
+//	lifetime>10ms        goroutines and tasks that lived longer than 10ms
+//	blocked>50%          goroutines that were blocked for more than half of their lifetime. The running, inactive
+//	                     and gc-assist metrics work the same. Instead of a percentage, they also accept a duration.
+//
+// Comparisons support the >, >=, <, <= and = operators. Parentheses that are opened inside a term belong to the term,
+// as in fn:sync.(*Mutex).Lock, except directly after an operator, as in NOT(a OR b). Values that contain spaces or
+// unbalanced parentheses can be quoted with double quotes, such as task:"my task" or fn:"\)$".
+
+type queryPredicate func(item theme.ListWindowItem) bool
+
+type queryTokenKind uint8
+
+const (
+	queryTokenWord queryTokenKind = iota
+	queryTokenLParen
+	queryTokenRParen
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	s    string
+	// Offset of the token in the query, in runes
+	offset int
+}
+
+type queryError struct {
+	offset int
+	msg    string
+}
+
+func (err *queryError) Error() string {
+	return fmt.Sprintf("column %d: %s", err.offset+1, err.msg)
+}
+
+func lexQuery(s string) ([]queryToken, error) {
+	rs := []rune(s)
+	var out []queryToken
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			out = append(out, queryToken{queryTokenLParen, "(", i})
+			i++
+		case r == ')':
+			out = append(out, queryToken{queryTokenRParen, ")", i})
+			i++
+		default:
+			start := i
+			var sb strings.Builder
+			quoted := false
+			// Parentheses that are opened inside a word belong to the word, so that function names like
+			// sync.(*Mutex).Lock don't have to be quoted.
+			depth := 0
+		wordLoop:
+			for ; i < len(rs); i++ {
+				r := rs[i]
+				switch {
+				case r == '"':
+					quoted = !quoted
+				case quoted:
+					sb.WriteRune(r)
+				case unicode.IsSpace(r):
+					break wordLoop
+				case r == '(':
+					if depth == 0 && isQueryOperatorWord(sb.String()) {
+						// Operators don't need to be separated from groups, as in NOT(a OR b).
+						break wordLoop
+					}
+					depth++
+					sb.WriteRune(r)
+				case r == ')':
+					if depth == 0 {
+						break wordLoop
+					}
+					depth--
+					sb.WriteRune(r)
+				default:
+					sb.WriteRune(r)
+				}
+			}
+			if quoted {
+				return nil, &queryError{start, "unterminated quoted string"}
+			}
+			out = append(out, queryToken{queryTokenWord, sb.String(), start})
+		}
+	}
+	return out, nil
+}
+
+// A stateSet is a set of scheduling states.
+type stateSet [4]uint64
+
+func (set *stateSet) add(state ptrace.SchedulingState) {
+	set[state/64] |= 1 << (state % 64)
+}
+
+func (set *stateSet) has(state ptrace.SchedulingState) bool {
+	return set[state/64]&(1<<(state%64)) != 0
+}
+
+// goroutineSummary summarizes the states of a goroutine. It is used by timeline queries and for sorting goroutine
+// timelines.
+type goroutineSummary struct {
+	computed bool
+	// The states the goroutine was in at some point, including the states that user-defined states refine
+	states stateSet
+	// The times the goroutine spent in groups of states
+	blocked  time.Duration
+	running  time.Duration
+	inactive time.Duration
+	gcAssist time.Duration
+	ready    time.Duration
+}
+
+// summarizeGoroutine computes the summary of a goroutine. We only need the totals, not the full statistics, which are
+// much more expensive to compute.
+func summarizeGoroutine(tr *Trace, g *ptrace.Goroutine) goroutineSummary {
+	var stats ptrace.Statistics
+	var states stateSet
+	for i := 0; i < g.Spans.Len(); i++ {
+		s := g.Spans.AtPtr(i)
+		stats[s.State].Total += s.Duration()
+		states.add(s.State)
+		states.add(tr.BaseState(s.State))
+	}
+	return goroutineSummary{
+		computed: true,
+		states:   states,
+		blocked:  stats.Blocked(tr.Trace),
+		running:  stats.Running(tr.Trace),
+		inactive: stats.Inactive(tr.Trace),
+		gcAssist: stats.GCAssist(tr.Trace),
+		ready:    stats[ptrace.StateReady].Total,
+	}
+}
+
+// timelineQueryCache holds data that is expensive to compute and that can be shared by all queries of a single dialog.
+// Goroutine summaries are computed on demand, as most queries don't need them, and cached, as the filter gets evaluated
+// on every keystroke.
+type timelineQueryCache struct {
+	summaries []goroutineSummary
+}
+
+func (c *timelineQueryCache) goroutineSummary(tr *Trace, g *ptrace.Goroutine) *goroutineSummary {
+	if c.summaries == nil {
+		c.summaries = make([]goroutineSummary, len(tr.Goroutines))
+	}
+	t := &c.summaries[g.SeqID]
+	if !t.computed {
+		*t = summarizeGoroutine(tr, g)
+	}
+	return t
+}
+
+type queryParser struct {
+	tr     *Trace
+	cache  *timelineQueryCache
+	tokens []queryToken
+	pos    int
+	// The length of the query, in runes
+	end int
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func isQueryOperatorWord(s string) bool {
+	switch s {
+	case "AND", "&", "OR", "|", "NOT", "!":
+		return true
+	default:
+		return false
+	}
+}
+
+func isQueryOperator(tok queryToken, ops ...string) bool {
+	if tok.kind != queryTokenWord {
+		return false
+	}
+	for _, op := range ops {
+		if tok.s == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *queryParser) parseOr() (queryPredicate, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || !isQueryOperator(tok, "OR", "|") {
+			return lhs, nil
+		}
+		p.pos++
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = func(lhs, rhs queryPredicate) queryPredicate {
+			return func(item theme.ListWindowItem) bool { return lhs(item) || rhs(item) }
+		}(lhs, rhs)
+	}
+}
+
+func (p *queryParser) parseAnd() (queryPredicate, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind == queryTokenRParen || isQueryOperator(tok, "OR", "|") {
+			return lhs, nil
+		}
+		if isQueryOperator(tok, "AND", "&") {
+			p.pos++
+		}
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = func(lhs, rhs queryPredicate) queryPredicate {
+			return func(item theme.ListWindowItem) bool { return lhs(item) && rhs(item) }
+		}(lhs, rhs)
+	}
+}
+
+func (p *queryParser) parseUnary() (queryPredicate, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, &queryError{p.end, "unexpected end of query"}
+	}
+
+	switch {
+	case tok.kind == queryTokenRParen:
+		return nil, &queryError{tok.offset, "unexpected )"}
+	case tok.kind == queryTokenLParen:
+		p.pos++
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, ok := p.peek(); !ok {
+			return nil, &queryError{p.end, "missing )"}
+		} else if tok.kind != queryTokenRParen {
+			return nil, &queryError{tok.offset, "missing )"}
+		}
+		p.pos++
+		return x, nil
+	case isQueryOperator(tok, "NOT", "!"):
+		p.pos++
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(item theme.ListWindowItem) bool { return !x(item) }, nil
+	case isQueryOperator(tok, "AND", "&", "OR", "|"):
+		return nil, &queryError{tok.offset, fmt.Sprintf("unexpected %s", tok.s)}
+	default:
+		p.pos++
+		return p.parseTerm(tok)
+	}
+}
+
+// lookupQueryState looks up a state by its identifier. The "blocked-" prefix may be omitted, as in "syscall".
+func lookupQueryState(tr *Trace, name string) (ptrace.SchedulingState, bool) {
+	if state, ok := tr.LookupState(name); ok {
+		return state, true
+	}
+	return tr.LookupState("blocked-" + name)
+}
+
+var queryComparisonRe = regexp.MustCompile(`^(lifetime|blocked|running|inactive|gc-assist)(>=|<=|>|<|=)(.*)$`)
+
+func (p *queryParser) parseTerm(tok queryToken) (queryPredicate, error) {
+	errorf := func(format string, args ...any) error {
+		return &queryError{tok.offset, fmt.Sprintf(format, args...)}
+	}
+
+	if m := queryComparisonRe.FindStringSubmatch(tok.s); m != nil {
+		return p.parseComparison(tok, m[1], m[2], m[3])
+	}
+
+	prefix, value, found := strings.Cut(tok.s, ":")
+	if !found {
+		return func(item theme.ListWindowItem) bool {
+			for _, s := range item.FilterLabels {
+				if strings.Contains(s, tok.s) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	switch prefix {
+	case "gid", "pid", "mid":
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, errorf("%q is not a valid ID", value)
+		}
+		switch prefix {
+		case "gid":
+			return func(item theme.ListWindowItem) bool {
+				g, ok := item.Item.(*ptrace.Goroutine)
+				return ok && g.ID == n
+			}, nil
+		case "pid":
+			return func(item theme.ListWindowItem) bool {
+				proc, ok := item.Item.(*ptrace.Processor)
+				return ok && uint64(proc.ID) == n
+			}, nil
+		case "mid":
+			return func(item theme.ListWindowItem) bool {
+				m, ok := item.Item.(*ptrace.Machine)
+				return ok && uint64(m.ID) == n
+			}, nil
+		default:
+			panic("unreachable")
+		}
+
+	case "fn", "created-by":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, errorf("invalid regular expression: %s", err)
+		}
+		if prefix == "fn" {
+			return func(item theme.ListWindowItem) bool {
+				g, ok := item.Item.(*ptrace.Goroutine)
+				return ok && re.MatchString(g.Function.Fn)
+			}, nil
+		} else {
+			return func(item theme.ListWindowItem) bool {
+				g, ok := item.Item.(*ptrace.Goroutine)
+				return ok && g.Parent != nil && re.MatchString(g.Parent.Function.Fn)
+			}, nil
+		}
+
+	case "state":
+		state, ok := lookupQueryState(p.tr, value)
+		if !ok {
+			return nil, errorf("unknown state %q", value)
+		}
+		return func(item theme.ListWindowItem) bool {
+			g, ok := item.Item.(*ptrace.Goroutine)
+			return ok && p.cache.goroutineSummary(p.tr, g).states.has(state)
+		}, nil
+
+	case "active-in":
+		start, end, err := parseQueryRange(value)
+		if err != nil {
+			return nil, errorf("%s", err)
+		}
+		return func(item theme.ListWindowItem) bool {
+			switch obj := item.Item.(type) {
+			case *ptrace.Goroutine:
+				return spansActiveIn(obj.Spans, start, end, func(s *ptrace.Span) bool {
+					return p.tr.BaseState(s.State) == ptrace.StateActive
+				})
+			case *ptrace.Processor:
+				return spansActiveIn(obj.Spans, start, end, nil)
+			case *ptrace.Machine:
+				return spansActiveIn(obj.Spans, start, end, nil)
+			case *ptrace.Task:
+				return obj.Start < end && obj.End > start
+			default:
+				return false
+			}
+		}, nil
+
+	case "task":
+		gs := map[*ptrace.Goroutine]struct{}{}
+		for _, t := range p.tr.Tasks {
+			if strings.Contains(t.Name, value) {
+				for _, g := range t.Goroutines {
+					gs[g] = struct{}{}
+				}
+			}
+		}
+		return func(item theme.ListWindowItem) bool {
+			switch obj := item.Item.(type) {
+			case *ptrace.Goroutine:
+				_, ok := gs[obj]
+				return ok
+			case *ptrace.Task:
+				return strings.Contains(obj.Name, value)
+			default:
+				return false
+			}
+		}, nil
+
+	default:
+		return nil, errorf("unknown field %q", prefix)
+	}
+}
+
+func (p *queryParser) parseComparison(tok queryToken, metric, op, value string) (queryPredicate, error) {
+	errorf := func(format string, args ...any) error {
+		return &queryError{tok.offset, fmt.Sprintf(format, args...)}
+	}
+
+	var cmp func(a, b float64) bool
+	switch op {
+	case ">":
+		cmp = func(a, b float64) bool { return a > b }
+	case ">=":
+		cmp = func(a, b float64) bool { return a >= b }
+	case "<":
+		cmp = func(a, b float64) bool { return a < b }
+	case "<=":
+		cmp = func(a, b float64) bool { return a <= b }
+	case "=":
+		cmp = func(a, b float64) bool { return a == b }
+	default:
+		panic(fmt.Sprintf("unhandled operator %q", op))
+	}
+
+	var ref float64
+	percent := strings.HasSuffix(value, "%")
+	if percent {
+		if metric == "lifetime" {
+			return nil, errorf("lifetime has to be compared with a duration, not a percentage")
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return nil, errorf("%q is not a valid percentage", value)
+		}
+		ref = f / 100
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, errorf("%q is not a valid duration", value)
+		}
+		ref = float64(d)
+	}
+
+	if metric == "lifetime" {
+		return func(item theme.ListWindowItem) bool {
+			switch obj := item.Item.(type) {
+			case *ptrace.Goroutine:
+				return cmp(float64(ptrace.Duration(obj.Spans)), ref)
+			case *ptrace.Task:
+				return cmp(float64(obj.End-obj.Start), ref)
+			default:
+				return false
+			}
+		}, nil
+	}
+
+	return func(item theme.ListWindowItem) bool {
+		g, ok := item.Item.(*ptrace.Goroutine)
+		if !ok {
+			return false
+		}
+		times := p.cache.goroutineSummary(p.tr, g)
+		var d time.Duration
+		switch metric {
+		case "blocked":
+			d = times.blocked
+		case "running":
+			d = times.running
+		case "inactive":
+			d = times.inactive
+		case "gc-assist":
+			d = times.gcAssist
+		default:
+			panic(fmt.Sprintf("unhandled metric %q", metric))
+		}
+		if !percent {
+			return cmp(float64(d), ref)
+		}
+		lifetime := ptrace.Duration(g.Spans)
+		if lifetime == 0 {
+			return false
+		}
+		return cmp(float64(d)/float64(lifetime), ref)
+	}, nil
+}
+
+// parseQueryRange parses a time range of the form [start,end], where start and end are durations since the start of
+// the trace.
+func parseQueryRange(s string) (trace.Timestamp, trace.Timestamp, error) {
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return 0, 0, fmt.Errorf("expected a range of the form [start,end], got %q", s)
+	}
+	left, right, ok := strings.Cut(s[1:len(s)-1], ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected a range of the form [start,end], got %q", s)
+	}
+	start, err := time.ParseDuration(strings.TrimSpace(left))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q is not a valid duration", left)
+	}
+	end, err := time.ParseDuration(strings.TrimSpace(right))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q is not a valid duration", right)
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("the range %q ends before it starts", s)
+	}
+	return trace.Timestamp(start), trace.Timestamp(end), nil
+}
+
+// spansActiveIn reports whether any span that overlaps [start, end) satisfies fn. A nil fn is satisfied by all spans.
+func spansActiveIn(spans ptrace.Spans, start, end trace.Timestamp, fn func(s *ptrace.Span) bool) bool {
+	first := sort.Search(spans.Len(), func(i int) bool {
+		return spans.AtPtr(i).End > start
+	})
+	for i := first; i < spans.Len(); i++ {
+		s := spans.AtPtr(i)
+		if s.Start >= end {
+			break
+		}
+		if fn == nil || fn(s) {
+			return true
+		}
+	}
+	return false
+}
+
+type timelineFilter struct {
+	fn queryPredicate
+}
+
+// newTimelineFilter parses a timeline query. All queries built from the same cache must be for the same trace.
+func newTimelineFilter(tr *Trace, cache *timelineQueryCache, s string) (theme.Filter, error) {
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return &timelineFilter{}, nil
+	}
+
+	p := &queryParser{tr: tr, cache: cache, tokens: tokens, end: len([]rune(s))}
+	fn, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		// The only way for parseOr to stop early is an unbalanced closing parenthesis.
+		return nil, &queryError{tok.offset, fmt.Sprintf("unexpected %s", tok.s)}
+	}
+	return &timelineFilter{fn: fn}, nil
+}
+
+func (f *timelineFilter) Filter(item theme.ListWindowItem) bool {
+	if f.fn == nil {
+		return true
+	}
+	return f.fn(item)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+
+	"golang.org/x/exp/slices"
+)
+
+func newQueryTestTrace() (*Trace, []theme.ListWindowItem) {
+	ts := func(d time.Duration) trace.Timestamp { return trace.Timestamp(d) }
+
+	g1 := &ptrace.Goroutine{
+		ID:       1,
+		SeqID:    0,
+		Function: &ptrace.Function{Frame: trace.Frame{Fn: "main.main"}},
+		Spans: ptrace.ToSpans([]ptrace.Span{
+			{Start: ts(0), End: ts(time.Second), State: ptrace.StateActive},
+			{Start: ts(time.Second), End: ts(3 * time.Second), State: ptrace.StateBlockedRecv},
+		}),
+	}
+	g2 := &ptrace.Goroutine{
+		ID:       2,
+		SeqID:    1,
+		Function: &ptrace.Function{Frame: trace.Frame{Fn: "main.worker"}},
+		Parent:   g1,
+		Spans: ptrace.ToSpans([]ptrace.Span{
+			{Start: ts(500 * time.Millisecond), End: ts(600 * time.Millisecond), State: ptrace.StateActive},
+			{Start: ts(600 * time.Millisecond), End: ts(700 * time.Millisecond), State: ptrace.StateReady},
+		}),
+	}
+	g12 := &ptrace.Goroutine{
+		ID:       12,
+		SeqID:    2,
+		Function: &ptrace.Function{Frame: trace.Frame{Fn: "net/http.serve"}},
+		Parent:   g2,
+		Spans: ptrace.ToSpans([]ptrace.Span{
+			{Start: ts(1500 * time.Millisecond), End: ts(1505 * time.Millisecond), State: ptrace.StateActive},
+		}),
+	}
+	p0 := &ptrace.Processor{
+		ID: 0,
+		Spans: ptrace.ToSpans([]ptrace.Span{
+			{Start: ts(0), End: ts(time.Second)},
+		}),
+	}
+	p3 := &ptrace.Processor{
+		ID:    3,
+		SeqID: 1,
+		Spans: ptrace.ToSpans([]ptrace.Span{
+			{Start: ts(2500 * time.Millisecond), End: ts(2600 * time.Millisecond)},
+		}),
+	}
+	task := &ptrace.Task{
+		ID:         1,
+		Name:       "request",
+		Start:      ts(time.Second),
+		End:        ts(2 * time.Second),
+		Goroutines: []*ptrace.Goroutine{g2},
+	}
+
+	tr := &Trace{Trace: &ptrace.Trace{
+		Goroutines: []*ptrace.Goroutine{g1, g2, g12},
+		Processors: []*ptrace.Processor{p0, p3},
+		Tasks:      []*ptrace.Task{task},
+	}}
+
+	var items []theme.ListWindowItem
+	for _, g := range tr.Goroutines {
+		label := fmt.Sprintf("g%d", g.ID)
+		items = append(items, theme.ListWindowItem{
+			Item:         g,
+			Label:        label,
+			FilterLabels: []string{"goroutine", label, g.Function.Fn},
+		})
+	}
+	for _, p := range tr.Processors {
+		label := fmt.Sprintf("p%d", p.ID)
+		items = append(items, theme.ListWindowItem{
+			Item:         p,
+			Label:        label,
+			FilterLabels: []string{"processor", label},
+		})
+	}
+	items = append(items, theme.ListWindowItem{
+		Item:         task,
+		Label:        "task1",
+		FilterLabels: []string{"task", task.Name},
+	})
+	return tr, items
+}
+
+func TestTimelineQuery(t *testing.T) {
+	all := []string{"g1", "g2", "g12", "p0", "p3", "task1"}
+	for _, test := range []struct {
+		query string
+		want  []string
+	}{
+		{"", all},
+		{"   ", all},
+
+		// Substrings and ID lookups, as supported before the query language existed
+		{"worker", []string{"g2"}},
+		{"g1", []string{"g1", "g12"}},
+		{"goroutine main", []string{"g1", "g2"}},
+		{"gid:12", []string{"g12"}},
+		{"gid:3", nil},
+		{"pid:3", []string{"p3"}},
+		{"gid:1 pid:0", nil},
+
+		// Operators and precedence
+		{"gid:1 OR gid:2 worker", []string{"g1", "g2"}},
+		{"gid:1 OR gid:12 worker", []string{"g1"}},
+		{"gid:1 | gid:12 & worker", []string{"g1"}},
+		{"(gid:1 OR gid:12) goroutine", []string{"g1", "g12"}},
+		{"gid:1 AND gid:12", nil},
+		{"NOT goroutine", []string{"p0", "p3", "task1"}},
+		{"! processor task", []string{"task1"}},
+		{"NOT NOT worker", []string{"g2"}},
+		{"NOT (goroutine OR processor)", []string{"task1"}},
+
+		// Fields
+		{`fn:^main\.`, []string{"g1", "g2"}},
+		{"created-by:worker", []string{"g12"}},
+		{"state:blocked-recv", []string{"g1"}},
+		{"state:recv", []string{"g1"}},
+		{"state:ready", []string{"g2"}},
+		{"task:request", []string{"g2", "task1"}},
+		{"task:nope", nil},
+		{"active-in:[1s,2s]", []string{"g12", "task1"}},
+		{"active-in:[0s,500ms]", []string{"g1", "p0"}},
+
+		// Comparisons
+		{"lifetime>10ms", []string{"g1", "g2", "task1"}},
+		{"lifetime<=5ms", []string{"g12"}},
+		{"lifetime=1s", []string{"task1"}},
+		{"blocked>50%", []string{"g1"}},
+		{"blocked>=2s", []string{"g1"}},
+		{"running>50%", []string{"g12"}},
+		{"running>=50%", []string{"g2", "g12"}},
+		{"inactive>0s", []string{"g2"}},
+	} {
+		tr, items := newQueryTestTrace()
+		f, err := newTimelineFilter(tr, &timelineQueryCache{}, test.query)
+		if err != nil {
+			t.Errorf("query %q: unexpected error: %s", test.query, err)
+			continue
+		}
+		if got := matchingLabels(f, items); !slices.Equal(got, test.want) {
+			t.Errorf("query %q: got %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestTimelineQueryParentheses(t *testing.T) {
+	for _, test := range []struct {
+		query string
+		want  []string
+	}{
+		// Parentheses inside of terms belong to the terms
+		{"sync.(*Mutex).Lock", []string{"g20"}},
+		{`fn:\.\(\*Mutex\)\.Lock$`, []string{"g20"}},
+		{"fn:^main.(main|worker)$", []string{"g1", "g2"}},
+		{"NOT(sync.(*Mutex).Lock) goroutine", []string{"g1", "g2", "g12"}},
+
+		// Parentheses outside of terms group
+		{"(gid:1 OR gid:2)", []string{"g1", "g2"}},
+		{"(gid:1 OR gid:2) AND(worker)", []string{"g2"}},
+		{"NOT(goroutine OR processor)", []string{"task1"}},
+		{"!(goroutine)", []string{"p0", "p3", "task1"}},
+		{"gid:1 OR(gid:2)", []string{"g1", "g2"}},
+	} {
+		tr, items := newQueryTestTrace()
+		g := &ptrace.Goroutine{
+			ID:       20,
+			SeqID:    len(tr.Goroutines),
+			Function: &ptrace.Function{Frame: trace.Frame{Fn: "sync.(*Mutex).Lock"}},
+			Spans: ptrace.ToSpans([]ptrace.Span{
+				{Start: trace.Timestamp(2 * time.Second), End: trace.Timestamp(3 * time.Second), State: ptrace.StateActive},
+			}),
+		}
+		tr.Goroutines = append(tr.Goroutines, g)
+		items = append(items, theme.ListWindowItem{
+			Item:         g,
+			Label:        "g20",
+			FilterLabels: []string{"goroutine", "g20", g.Function.Fn},
+		})
+
+		f, err := newTimelineFilter(tr, &timelineQueryCache{}, test.query)
+		if err != nil {
+			t.Errorf("query %q: unexpected error: %s", test.query, err)
+			continue
+		}
+		if got := matchingLabels(f, items); !slices.Equal(got, test.want) {
+			t.Errorf("query %q: got %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func matchingLabels(f theme.Filter, items []theme.ListWindowItem) []string {
+	var out []string
+	for _, item := range items {
+		if f.Filter(item) {
+			out = append(out, item.Label)
+		}
+	}
+	return out
+}
+
+func TestTimelineQueryErrors(t *testing.T) {
+	for _, test := range []struct {
+		query  string
+		offset int
+		msg    string
+	}{
+		{"(gid:1", 6, "missing )"},
+		{"(gid:1 worker", 13, "missing )"},
+		{"gid:1)", 5, "unexpected )"},
+		{"()", 1, "unexpected )"},
+		{"OR gid:1", 0, "unexpected OR"},
+		{"gid:1 OR", 8, "unexpected end of query"},
+		{"NOT", 3, "unexpected end of query"},
+		{"worker !", 8, "unexpected end of query"},
+		{`worker task:"foo`, 7, "unterminated quoted string"},
+		{"gid:x", 0, `"x" is not a valid ID`},
+		{"worker bogus:1", 7, `unknown field "bogus"`},
+		{"state:bogus", 0, `unknown state "bogus"`},
+		{"lifetime>10%", 0, "lifetime has to be compared with a duration, not a percentage"},
+		{"blocked>lots", 0, `"lots" is not a valid duration`},
+		{"active-in:1s", 0, `expected a range of the form [start,end], got "1s"`},
+		{"active-in:[2s,1s]", 0, `the range "[2s,1s]" ends before it starts`},
+	} {
+		tr, _ := newQueryTestTrace()
+		_, err := newTimelineFilter(tr, &timelineQueryCache{}, test.query)
+		var qerr *queryError
+		if !errors.As(err, &qerr) {
+			t.Errorf("query %q: got error %v, want a query error", test.query, err)
+			continue
+		}
+		if qerr.offset != test.offset || qerr.msg != test.msg {
+			t.Errorf("query %q: got error at %d: %q, want error at %d: %q", test.query, qerr.offset, qerr.msg, test.offset, test.msg)
+		}
+	}
+}
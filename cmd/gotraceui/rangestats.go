@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"sort"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/op"
+	"gioui.org/text"
+	"golang.org/x/exp/slices"
+)
+
+type goroutineRangeStats struct {
+	Goroutine *ptrace.Goroutine
+	Running   time.Duration
+	Ready     time.Duration
+	Blocked   time.Duration
+}
+
+type processorRangeStats struct {
+	Processor *ptrace.Processor
+	Busy      time.Duration
+	// The number of distinct goroutines that ran on the processor
+	Goroutines int
+}
+
+type rangeStats struct {
+	// Statistics of all goroutine states in the range
+	States *SpansStats
+	// Goroutines that ran in the range, sorted by running time in descending order
+	Goroutines []*goroutineRangeStats
+	Processors []*processorRangeStats
+	// The average utilization of the processors allowed by GOMAXPROCS, in [0, 1]
+	Utilization float64
+}
+
+// clipSpans calls fn for each span that overlaps [start, end], with the span's bounds clamped to the range.
+func clipSpans(spans ptrace.Spans, start, end trace.Timestamp, fn func(s ptrace.Span)) {
+	first := sort.Search(spans.Len(), func(i int) bool {
+		return spans.AtPtr(i).End > start
+	})
+	for i := first; i < spans.Len(); i++ {
+		s := spans.At(i)
+		if s.Start >= end {
+			break
+		}
+		if s.Start < start {
+			s.Start = start
+		}
+		if s.End > end {
+			s.End = end
+		}
+		fn(s)
+	}
+}
+
+// computeRangeStats aggregates the states of all goroutines and processors in [start, end].
+func computeRangeStats(tr *Trace, start, end trace.Timestamp, cancelled <-chan struct{}) *rangeStats {
+	out := &rangeStats{}
+
+	var all []ptrace.Span
+	// Reused between goroutines to avoid allocating a full set of statistics per goroutine.
+	var stats ptrace.Statistics
+	for i, g := range tr.Goroutines {
+		if i%1000 == 0 {
+			select {
+			case <-cancelled:
+				return nil
+			default:
+			}
+		}
+
+		stats = ptrace.Statistics{}
+		var found bool
+		clipSpans(g.Spans, start, end, func(s ptrace.Span) {
+			found = true
+			all = append(all, s)
+			stats[s.State].Total += s.Duration()
+		})
+		if !found {
+			continue
+		}
+		if running := stats.Running(tr.Trace); running > 0 {
+			out.Goroutines = append(out.Goroutines, &goroutineRangeStats{
+				Goroutine: g,
+				Running:   running,
+				Ready:     stats[ptrace.StateReady].Total,
+				Blocked:   stats.Blocked(tr.Trace),
+			})
+		}
+	}
+	out.States = NewSpansStats(tr, ptrace.ToSpans(all))
+
+	slices.SortFunc(out.Goroutines, func(a, b *goroutineRangeStats) bool {
+		if a.Running != b.Running {
+			return a.Running > b.Running
+		}
+		return a.Goroutine.ID < b.Goroutine.ID
+	})
+
+	var busy time.Duration
+	for _, p := range tr.Processors {
+		ps := &processorRangeStats{Processor: p}
+		seen := map[uint64]struct{}{}
+		clipSpans(p.Spans, start, end, func(s ptrace.Span) {
+			ps.Busy += s.Duration()
+			seen[tr.Event(s.Event).G] = struct{}{}
+		})
+		ps.Goroutines = len(seen)
+		busy += ps.Busy
+		out.Processors = append(out.Processors, ps)
+	}
+	if capacity := gomaxprocsTime(tr, start, end); capacity > 0 {
+		out.Utilization = float64(busy) / capacity
+	}
+
+	return out
+}
+
+// gomaxprocsTime integrates GOMAXPROCS over the range [start, end], returning the processor time that was available to
+// goroutines. Like computeIdleEpisodes, it assumes that all processors were usable before the first change of
+// GOMAXPROCS.
+func gomaxprocsTime(tr *Trace, start, end trace.Timestamp) float64 {
+	var total float64
+	gomaxprocs := len(tr.Processors)
+	prev := start
+	for _, pt := range tr.Gomaxprocs {
+		if pt.When >= end {
+			break
+		}
+		if pt.When > prev {
+			total += float64(pt.When-prev) * float64(gomaxprocs)
+			prev = pt.When
+		}
+		gomaxprocs = int(pt.Value)
+	}
+	total += float64(end-prev) * float64(gomaxprocs)
+	return total
+}
+
+// RangePanel displays aggregate statistics for a range of time selected on the canvas.
+type RangePanel struct {
+	mwin        *MainWindow
+	start, end  trace.Timestamp
+	stats       *theme.Future[*rangeStats]
+	initialized bool
+
+	description   Description
+	tabbedState   theme.TabbedState
+	statsList     widget.List
+	processorList processorRangeStatsList
+	goroutineList goroutineRangeStatsList
+
+	buttons struct {
+		zoom  widget.PrimaryClickable
+		clear widget.PrimaryClickable
+	}
+
+	theme.PanelButtons
+}
+
+func NewRangePanel(mwin *MainWindow, start, end trace.Timestamp) *RangePanel {
+	tr := mwin.trace
+	return &RangePanel{
+		mwin:  mwin,
+		start: start,
+		end:   end,
+		stats: theme.NewFuture(mwin.twin, func(cancelled <-chan struct{}) *rangeStats {
+			return computeRangeStats(tr, start, end, cancelled)
+		}),
+	}
+}
+
+func (rp *RangePanel) Title() string {
+	return local.Sprintf("Range of %s", roundDuration(time.Duration(rp.end-rp.start)))
+}
+
+func (rp *RangePanel) init(win *theme.Window, stats *rangeStats) {
+	value := func(s *TextSpan) *theme.Future[TextSpan] {
+		return theme.Immediate(*s)
+	}
+	tb := TextBuilder{Theme: win.Theme}
+	rp.description.Attributes = []DescriptionAttribute{
+		{Key: "Start", Value: value(tb.Link(formatTimestamp(rp.start), rp.start))},
+		{Key: "End", Value: value(tb.Link(formatTimestamp(rp.end), rp.end))},
+		{Key: "Duration", Value: value(tb.Span(time.Duration(rp.end - rp.start).String()))},
+		{Key: "Active goroutines", Value: value(tb.Span(local.Sprintf("%d", len(stats.Goroutines))))},
+		{Key: "Processor utilization", Value: value(tb.Span(local.Sprintf("%.2f%%", stats.Utilization*100)))},
+	}
+}
+
+func (rp *RangePanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.RangePanel.Layout").End()
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	stats, ok := rp.stats.Result()
+	if ok && !rp.initialized {
+		rp.init(win, stats)
+		rp.initialized = true
+	}
+
+	tabs := []string{"Goroutine states", "Processors", "Active goroutines"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &rp.buttons.zoom.Clickable, "Zoom to range").Layout)),
+				layout.Rigid(layout.Spacer{Width: 5}.Layout),
+				layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &rp.buttons.clear.Clickable, "Clear selection").Layout)),
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, rp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !ok {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing statistics…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min = image.Point{}
+					return rp.description.Layout(win, gtx)
+				}),
+
+				layout.Rigid(layout.Spacer{Height: 10}.Layout),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return theme.Tabbed(&rp.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+						switch tabs[rp.tabbedState.Current] {
+						case "Goroutine states":
+							rp.statsList.Axis = layout.Vertical
+							return theme.List(win.Theme, &rp.statsList).Layout(gtx, 1, func(gtx layout.Context, index int) layout.Dimensions {
+								if index != 0 {
+									panic("impossible")
+								}
+								return stats.States.Layout(win, gtx)
+							})
+						case "Processors":
+							return rp.processorList.Layout(win, gtx, stats.Processors, rp.end-rp.start)
+						case "Active goroutines":
+							return rp.goroutineList.Layout(win, gtx, stats.Goroutines)
+						default:
+							panic("unreachable")
+						}
+					})
+				}),
+			)
+		}),
+	)
+
+	for _, ev := range rp.description.Events() {
+		handleLinkClick(win, rp.mwin, ev)
+	}
+	for _, ev := range rp.processorList.Clicked() {
+		handleLinkClick(win, rp.mwin, ev)
+	}
+	for _, ev := range rp.goroutineList.Clicked() {
+		handleLinkClick(win, rp.mwin, ev)
+	}
+
+	for rp.buttons.zoom.Clicked() {
+		rp.mwin.canvas.navigateToStartAndEnd(gtx, rp.start, rp.end, rp.mwin.canvas.y)
+	}
+	for rp.buttons.clear.Clicked() {
+		rp.mwin.canvas.ClearRangeSelection()
+	}
+
+	for rp.PanelButtons.Backed() {
+		rp.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+type processorRangeStatsList struct {
+	list  widget.List
+	texts allocator[Text]
+}
+
+func (pl *processorRangeStatsList) Layout(win *theme.Window, gtx layout.Context, ps []*processorRangeStats, d trace.Timestamp) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.processorRangeStatsList.Layout").End()
+
+	pl.list.Axis = layout.Vertical
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < pl.texts.Len() {
+			txt = pl.texts.Ptr(txtCnt)
+		} else {
+			txt = pl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		p := ps[row]
+		switch col {
+		case 0: // Processor
+			txt.Link(local.Sprintf("%d", p.Processor.ID), p.Processor)
+			txt.Alignment = text.End
+		case 1: // Busy
+			layoutDuration(txt, p.Busy)
+		case 2: // Idle
+			layoutDuration(txt, time.Duration(d)-p.Busy)
+		case 3: // Utilization
+			txt.Span(local.Sprintf("%.2f%%", float64(p.Busy)/float64(d)*100))
+			txt.Alignment = text.End
+		case 4: // Goroutines
+			txt.Span(local.Sprintf("%d", p.Goroutines))
+			txt.Alignment = text.End
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	// XXX the widths depend on the font and scaling
+	columns := []theme.TableListColumn{
+		{Name: "Processor", MinWidth: 120, MaxWidth: 120},
+		{Name: "Busy", MinWidth: 150, MaxWidth: 150},
+		{Name: "Idle", MinWidth: 150, MaxWidth: 150},
+		{Name: "Utilization", MinWidth: 120, MaxWidth: 120},
+		{Name: "Goroutines", MinWidth: 120, MaxWidth: 120},
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns,
+		List:          &pl.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(ps), cellFn)
+	pl.texts.Truncate(txtCnt)
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (pl *processorRangeStatsList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < pl.texts.Len(); i++ {
+		txt := pl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
+
+type goroutineRangeStatsList struct {
+	list  widget.List
+	texts allocator[Text]
+}
+
+func (gl *goroutineRangeStatsList) Layout(win *theme.Window, gtx layout.Context, gs []*goroutineRangeStats) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.goroutineRangeStatsList.Layout").End()
+
+	gl.list.Axis = layout.Vertical
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < gl.texts.Len() {
+			txt = gl.texts.Ptr(txtCnt)
+		} else {
+			txt = gl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		g := gs[row]
+		switch col {
+		case 0: // Rank
+			txt.Span(local.Sprintf("%d", row+1))
+			txt.Alignment = text.End
+		case 1: // Goroutine
+			txt.Link(local.Sprintf("%d", g.Goroutine.ID), g.Goroutine)
+			txt.Alignment = text.End
+		case 2: // Function
+			txt.Link(g.Goroutine.Function.Fn, g.Goroutine.Function)
+		case 3:
+			layoutDuration(txt, g.Running)
+		case 4:
+			layoutDuration(txt, g.Ready)
+		case 5:
+			layoutDuration(txt, g.Blocked)
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	// XXX the widths depend on the font and scaling
+	columns := []theme.TableListColumn{
+		{Name: "Rank", MinWidth: 60, MaxWidth: 60},
+		{Name: "Goroutine", MinWidth: 120, MaxWidth: 120},
+		{Name: "Function", MinWidth: 400, MaxWidth: 400},
+		{Name: "Running", MinWidth: 150, MaxWidth: 150},
+		{Name: "Ready", MinWidth: 150, MaxWidth: 150},
+		{Name: "Blocked", MinWidth: 150, MaxWidth: 150},
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns,
+		List:          &gl.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(gs), cellFn)
+	gl.texts.Truncate(txtCnt)
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (gl *goroutineRangeStatsList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < gl.texts.Len(); i++ {
+		txt := gl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestGomaxprocsTime(t *testing.T) {
+	tr := &Trace{Trace: &ptrace.Trace{
+		Processors: make([]*ptrace.Processor, 8),
+		Gomaxprocs: []ptrace.Point{
+			{When: 100, Value: 2},
+			{When: 200, Value: 4},
+		},
+	}}
+
+	for _, test := range []struct {
+		start, end trace.Timestamp
+		want       float64
+	}{
+		// Before the first change, all processors are usable.
+		{0, 50, 50 * 8},
+		{50, 150, 50*8 + 50*2},
+		{100, 200, 100 * 2},
+		{150, 300, 50*2 + 100*4},
+		{250, 300, 50 * 4},
+	} {
+		if got := gomaxprocsTime(tr, test.start, test.end); got != test.want {
+			t.Errorf("[%d, %d]: got %v, want %v", test.start, test.end, got, test.want)
+		}
+	}
+}
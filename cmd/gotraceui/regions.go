@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/gesture"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/io/pointer"
+	"gioui.org/op"
+	"gioui.org/text"
+	"golang.org/x/exp/slices"
+)
+
+type regionInstance struct {
+	Goroutine *ptrace.Goroutine
+	Span      ptrace.Span
+}
+
+// regionType groups all instances of user regions with the same name.
+type regionType struct {
+	Name string
+	// Instances, sorted by start time
+	Instances []regionInstance
+
+	Total, Min, Max, Mean, P50, P99 time.Duration
+}
+
+func computeRegionTypes(tr *Trace, cancelled <-chan struct{}) []*regionType {
+	byName := map[string]*regionType{}
+	for i, g := range tr.Goroutines {
+		if i%1000 == 0 {
+			select {
+			case <-cancelled:
+				return nil
+			default:
+			}
+		}
+
+		for _, spans := range g.UserRegions {
+			for j := 0; j < spans.Len(); j++ {
+				s := spans.At(j)
+				name := tr.Strings[tr.Event(s.Event).Args[trace.ArgUserRegionTypeID]]
+				rt := byName[name]
+				if rt == nil {
+					rt = &regionType{Name: name}
+					byName[name] = rt
+				}
+				rt.Instances = append(rt.Instances, regionInstance{g, s})
+			}
+		}
+	}
+
+	out := make([]*regionType, 0, len(byName))
+	var ds []time.Duration
+	for _, rt := range byName {
+		slices.SortFunc(rt.Instances, func(a, b regionInstance) bool { return a.Span.Start < b.Span.Start })
+
+		ds = ds[:0]
+		for _, inst := range rt.Instances {
+			d := inst.Span.Duration()
+			ds = append(ds, d)
+			rt.Total += d
+		}
+		slices.Sort(ds)
+		rt.Min = ds[0]
+		rt.Max = ds[len(ds)-1]
+		rt.Mean = rt.Total / time.Duration(len(ds))
+		rt.P50 = percentile(ds, 0.5)
+		rt.P99 = percentile(ds, 0.99)
+
+		out = append(out, rt)
+	}
+
+	// Sort by total time spent, which is usually what one is interested in.
+	slices.SortFunc(out, func(a, b *regionType) bool {
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Name < b.Name
+	})
+
+	return out
+}
+
+// RegionsPanel lists all distinct types of user regions, with statistics across all goroutines.
+type RegionsPanel struct {
+	mwin  *MainWindow
+	types *theme.Future[[]*regionType]
+
+	list  widget.List
+	texts allocator[Text]
+
+	theme.PanelButtons
+}
+
+func NewRegionsPanel(mwin *MainWindow) *RegionsPanel {
+	return &RegionsPanel{
+		mwin: mwin,
+		types: theme.NewFuture(mwin.twin, func(cancelled <-chan struct{}) []*regionType {
+			return computeRegionTypes(mwin.trace, cancelled)
+		}),
+	}
+}
+
+func (rp *RegionsPanel) Title() string {
+	return "User regions"
+}
+
+func (rp *RegionsPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.RegionsPanel.Layout").End()
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, rp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			types, ok := rp.types.Result()
+			if !ok {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing statistics…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			return rp.layoutTable(win, gtx, types)
+		}),
+	)
+
+	for i := 0; i < rp.texts.Len(); i++ {
+		for _, ev := range rp.texts.Ptr(i).Events() {
+			if rt, ok := ev.Span.Object.(*regionType); ok {
+				if ev.Event.Type == gesture.TypeClick && ev.Event.Button == pointer.ButtonPrimary {
+					rp.mwin.openPanel(NewRegionInfo(rp.mwin, rt))
+				}
+				continue
+			}
+			handleLinkClick(win, rp.mwin, ev)
+		}
+	}
+
+	for rp.PanelButtons.Backed() {
+		rp.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+func (rp *RegionsPanel) layoutTable(win *theme.Window, gtx layout.Context, types []*regionType) layout.Dimensions {
+	rp.list.Axis = layout.Vertical
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < rp.texts.Len() {
+			txt = rp.texts.Ptr(txtCnt)
+		} else {
+			txt = rp.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		duration := func(d time.Duration) {
+			value, unit := durationNumberFormatSITable.format(d)
+			txt.Span(value)
+			txt.Span(" ")
+			s := txt.Span(unit)
+			s.Font.Variant = "Mono"
+			txt.Alignment = text.End
+		}
+
+		rt := types[row]
+		switch col {
+		case 0: // Region
+			txt.Link(rt.Name, rt)
+		case 1: // Count
+			txt.Span(local.Sprintf("%d", len(rt.Instances)))
+			txt.Alignment = text.End
+		case 2:
+			duration(rt.Total)
+		case 3:
+			duration(rt.Mean)
+		case 4:
+			duration(rt.P50)
+		case 5:
+			duration(rt.P99)
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	// XXX the widths depend on the font and scaling
+	columns := []theme.TableListColumn{
+		{Name: "Region", MinWidth: 300, MaxWidth: 300},
+		{Name: "Count", MinWidth: 100, MaxWidth: 100},
+		{Name: "Total", MinWidth: 150, MaxWidth: 150},
+		{Name: "Mean", MinWidth: 150, MaxWidth: 150},
+		{Name: "p50", MinWidth: 150, MaxWidth: 150},
+		{Name: "p99", MinWidth: 150, MaxWidth: 150},
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns,
+		List:          &rp.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	return tbl.Layout(win, gtx, len(types), cellFn)
+}
+
+// RegionInfo displays statistics and all instances of a single type of user region.
+type RegionInfo struct {
+	rt           *regionType
+	mwin         *MainWindow
+	description  Description
+	tabbedState  theme.TabbedState
+	instanceList RegionInstanceList
+
+	filterInstances widget.Bool
+	histInstances   []regionInstance
+	hist            InteractiveHistogram
+
+	theme.PanelButtons
+}
+
+func NewRegionInfo(mwin *MainWindow, rt *regionType) *RegionInfo {
+	ri := &RegionInfo{
+		rt:            rt,
+		mwin:          mwin,
+		histInstances: rt.Instances,
+		instanceList:  RegionInstanceList{Trace: mwin.trace},
+	}
+
+	value := func(s *TextSpan) *theme.Future[TextSpan] {
+		return theme.Immediate(*s)
+	}
+	tb := TextBuilder{Theme: mwin.twin.Theme}
+	ri.description.Attributes = []DescriptionAttribute{
+		{Key: "Region", Value: value(tb.Span(rt.Name))},
+		{Key: "Count", Value: value(tb.Span(local.Sprintf("%d", len(rt.Instances))))},
+		{Key: "Total", Value: value(tb.Span(rt.Total.String()))},
+		{Key: "Min", Value: value(tb.Span(rt.Min.String()))},
+		{Key: "Max", Value: value(tb.Span(rt.Max.String()))},
+		{Key: "Mean", Value: value(tb.Span(rt.Mean.String()))},
+		{Key: "p50", Value: value(tb.Span(rt.P50.String()))},
+		{Key: "p99", Value: value(tb.Span(rt.P99.String()))},
+	}
+
+	cfg := &widget.HistogramConfig{RejectOutliers: true, Bins: widget.DefaultHistogramBins}
+	ri.computeHistogram(mwin.twin, cfg)
+
+	return ri
+}
+
+func (ri *RegionInfo) Title() string {
+	return "User region: " + ri.rt.Name
+}
+
+func (ri *RegionInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	tabs := []string{"Instances", "Histogram"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, ri.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			return ri.description.Layout(win, gtx)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return theme.Tabbed(&ri.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				switch tabs[ri.tabbedState.Current] {
+				case "Instances":
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							return theme.CheckBox(win.Theme, &ri.filterInstances, "Filter list to range of durations selected in histogram").Layout(win, gtx)
+						}),
+
+						layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+							instances := ri.rt.Instances
+							if ri.filterInstances.Value {
+								instances = ri.histInstances
+							}
+							return ri.instanceList.Layout(win, gtx, instances)
+						}),
+					)
+				case "Histogram":
+					return ri.hist.Layout(win, gtx)
+				default:
+					panic("unreachable")
+				}
+			})
+		}),
+	)
+
+	for _, ev := range ri.instanceList.Clicked() {
+		handleLinkClick(win, ri.mwin, ev)
+	}
+
+	for ri.PanelButtons.Backed() {
+		ri.mwin.prevPanel()
+	}
+
+	if ri.hist.Changed() {
+		ri.histInstances = ri.computeHistogram(win, &ri.hist.Config)
+		// The user selected a range of durations, show them the matching instances.
+		ri.filterInstances.Value = true
+	}
+
+	return dims
+}
+
+func (ri *RegionInfo) computeHistogram(win *theme.Window, cfg *widget.HistogramConfig) []regionInstance {
+	var durations []time.Duration
+	var instances []regionInstance
+	for _, inst := range ri.rt.Instances {
+		d := inst.Span.Duration()
+		if fd := widget.FloatDuration(d); fd >= cfg.Start && (cfg.End == 0 || fd <= cfg.End) {
+			durations = append(durations, d)
+			instances = append(instances, inst)
+		}
+	}
+
+	ri.hist.Set(win, durations)
+
+	return instances
+}
+
+type RegionInstanceList struct {
+	Trace *Trace
+	list  widget.List
+
+	spanObjects allocator[SpanRef]
+	texts       allocator[Text]
+}
+
+func (rl *RegionInstanceList) Layout(win *theme.Window, gtx layout.Context, instances []regionInstance) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.RegionInstanceList.Layout").End()
+
+	rl.list.Axis = layout.Vertical
+	rl.spanObjects.Reset()
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < rl.texts.Len() {
+			txt = rl.texts.Ptr(txtCnt)
+		} else {
+			txt = rl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		inst := instances[row]
+		switch col {
+		case 0: // Time
+			txt.Link(formatTimestamp(inst.Span.Start), rl.spanObjects.Allocate(SpanRef{inst.Goroutine, inst.Span}))
+			txt.Alignment = text.End
+		case 1: // Duration
+			value, unit := durationNumberFormatSITable.format(inst.Span.Duration())
+			txt.Span(value)
+			txt.Span(" ")
+			s := txt.Span(unit)
+			s.Font.Variant = "Mono"
+			txt.Alignment = text.End
+		case 2: // Goroutine
+			txt.Link(local.Sprintf("%d", inst.Goroutine.ID), inst.Goroutine)
+			txt.Alignment = text.End
+		case 3: // Task
+			if taskID := rl.Trace.Event(inst.Span.Event).Args[trace.ArgUserRegionTaskID]; taskID != 0 {
+				t := rl.Trace.Task(taskID)
+				txt.Link(taskLabel(t), t)
+			}
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	// XXX the widths depend on the font and scaling
+	columns := []theme.TableListColumn{
+		{Name: "Time", MinWidth: 200, MaxWidth: 200},
+		{Name: "Duration", MinWidth: 200, MaxWidth: 200},
+		{Name: "Goroutine", MinWidth: 120, MaxWidth: 120},
+		{Name: "Task", MinWidth: 300, MaxWidth: 300},
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns,
+		List:          &rl.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	return tbl.Layout(win, gtx, len(instances), cellFn)
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (rl *RegionInstanceList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < rl.texts.Len(); i++ {
+		txt := rl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
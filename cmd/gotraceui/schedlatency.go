@@ -0,0 +1,532 @@
+package main
+
+import (
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/op"
+	"gioui.org/text"
+	"golang.org/x/exp/slices"
+)
+
+// The number of buckets that the scheduling latency plot divides the trace into.
+const schedulingLatencyPlotBuckets = 1000
+
+// schedulingLatency describes a single period of time during which a goroutine was ready to run, but wasn't running
+// yet.
+type schedulingLatency struct {
+	Goroutine *ptrace.Goroutine
+	Span      ptrace.Span
+}
+
+type functionSchedulingLatencies struct {
+	Fn    *ptrace.Function
+	Count int
+
+	Total, Mean, P99, Max time.Duration
+}
+
+type schedulingLatencies struct {
+	// All latencies, sorted by duration in descending order
+	Latencies []schedulingLatency
+	// Per function statistics, sorted by total latency in descending order
+	Functions []*functionSchedulingLatencies
+	// Percentiles of latencies over time
+	Plot *Plot
+
+	Total, Mean, P50, P90, P99, Max time.Duration
+}
+
+// computeSchedulingLatencies collects all transitions from the ready state to the active state.
+func computeSchedulingLatencies(tr *Trace, cancelled <-chan struct{}) *schedulingLatencies {
+	out := &schedulingLatencies{}
+	for i, g := range tr.Goroutines {
+		if i%1000 == 0 {
+			select {
+			case <-cancelled:
+				return nil
+			default:
+			}
+		}
+
+		for j := 0; j < g.Spans.Len()-1; j++ {
+			s := g.Spans.At(j)
+			if tr.BaseState(s.State) == ptrace.StateReady && tr.BaseState(g.Spans.At(j+1).State) == ptrace.StateActive {
+				out.Latencies = append(out.Latencies, schedulingLatency{g, s})
+			}
+		}
+	}
+
+	if len(out.Latencies) == 0 {
+		return out
+	}
+
+	slices.SortFunc(out.Latencies, func(a, b schedulingLatency) bool {
+		return a.Span.Duration() > b.Span.Duration()
+	})
+
+	// sorted in ascending order, for use with percentile
+	ds := make([]time.Duration, len(out.Latencies))
+	for i, l := range out.Latencies {
+		d := l.Span.Duration()
+		ds[len(ds)-1-i] = d
+		out.Total += d
+	}
+	out.Mean = out.Total / time.Duration(len(ds))
+	out.P50 = percentile(ds, 0.5)
+	out.P90 = percentile(ds, 0.9)
+	out.P99 = percentile(ds, 0.99)
+	out.Max = ds[len(ds)-1]
+
+	// Iterating over the latencies in reverse means that the per-function durations will be sorted in ascending order.
+	byFn := map[*ptrace.Function][]time.Duration{}
+	for i := len(out.Latencies) - 1; i >= 0; i-- {
+		l := out.Latencies[i]
+		byFn[l.Goroutine.Function] = append(byFn[l.Goroutine.Function], l.Span.Duration())
+	}
+	out.Functions = make([]*functionSchedulingLatencies, 0, len(byFn))
+	for fn, ds := range byFn {
+		fl := &functionSchedulingLatencies{
+			Fn:    fn,
+			Count: len(ds),
+			P99:   percentile(ds, 0.99),
+			Max:   ds[len(ds)-1],
+		}
+		for _, d := range ds {
+			fl.Total += d
+		}
+		fl.Mean = fl.Total / time.Duration(len(ds))
+		out.Functions = append(out.Functions, fl)
+	}
+	slices.SortFunc(out.Functions, func(a, b *functionSchedulingLatencies) bool {
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Fn.Fn < b.Fn.Fn
+	})
+
+	select {
+	case <-cancelled:
+		return nil
+	default:
+	}
+
+	out.Plot = computeSchedulingLatencyPlot(tr, out.Latencies)
+
+	return out
+}
+
+// computeSchedulingLatencyPlot divides the trace into equally sized buckets and computes percentiles of the latencies
+// that ended in each bucket. Buckets without latencies are skipped.
+func computeSchedulingLatencyPlot(tr *Trace, latencies []schedulingLatency) *Plot {
+	end := tr.Events[len(tr.Events)-1].Ts
+	bucketWidth := end / schedulingLatencyPlotBuckets
+	if bucketWidth < 1 {
+		bucketWidth = 1
+	}
+	numBuckets := int(end/bucketWidth) + 1
+
+	// latencies is sorted by descending duration. Iterating over it in reverse means that the buckets will be sorted
+	// in ascending order.
+	buckets := make([][]time.Duration, numBuckets)
+	for i := len(latencies) - 1; i >= 0; i-- {
+		l := latencies[i]
+		idx := int(l.Span.End / bucketWidth)
+		buckets[idx] = append(buckets[idx], l.Span.Duration())
+	}
+
+	// Buckets without any latencies don't get points. Plots hold each value until the next point, which carries the
+	// previous bucket's latencies over instead of pretending that there was no latency.
+	var p50, p90, p99 []ptrace.Point
+	for i, ds := range buckets {
+		if len(ds) == 0 {
+			continue
+		}
+		ts := trace.Timestamp(i) * bucketWidth
+		p50 = append(p50, ptrace.Point{When: ts, Value: uint64(percentile(ds, 0.5))})
+		p90 = append(p90, ptrace.Point{When: ts, Value: uint64(percentile(ds, 0.9))})
+		p99 = append(p99, ptrace.Point{When: ts, Value: uint64(percentile(ds, 0.99))})
+	}
+
+	pl := &Plot{
+		Name: "Scheduling latency",
+		Unit: "ns",
+		FormatValue: func(v uint64) string {
+			return roundDuration(time.Duration(v)).String()
+		},
+	}
+	pl.AddSeries(
+		// Series are drawn in order, so draw the filled series first.
+		PlotSeries{
+			Name:   "p99",
+			Points: p99,
+			Filled: true,
+			Color:  rgba(0xA5D6DCFF),
+		},
+		PlotSeries{
+			Name:   "p90",
+			Points: p90,
+			Filled: false,
+			Color:  colors[colorStateReady],
+		},
+		PlotSeries{
+			Name:   "p50",
+			Points: p50,
+			Filled: false,
+			Color:  rgba(0x1F6F79FF),
+		},
+	)
+	return pl
+}
+
+func (mwin *MainWindow) schedulingLatencies() *theme.Future[*schedulingLatencies] {
+	if mwin.cachedSchedulingLatencies == nil {
+		tr := mwin.trace
+		mwin.cachedSchedulingLatencies = theme.NewFuture(mwin.twin, func(cancelled <-chan struct{}) *schedulingLatencies {
+			return computeSchedulingLatencies(tr, cancelled)
+		})
+	}
+	return mwin.cachedSchedulingLatencies
+}
+
+// SchedulingLatencyPanel displays how long goroutines had to wait between becoming ready and running.
+type SchedulingLatencyPanel struct {
+	mwin        *MainWindow
+	latencies   *theme.Future[*schedulingLatencies]
+	initialized bool
+
+	description  Description
+	tabbedState  theme.TabbedState
+	functionList functionSchedulingLatencyList
+	latencyList  schedulingLatencyList
+	hist         InteractiveHistogram
+	togglePlot   widget.PrimaryClickable
+
+	theme.PanelButtons
+}
+
+func NewSchedulingLatencyPanel(mwin *MainWindow) *SchedulingLatencyPanel {
+	return &SchedulingLatencyPanel{
+		mwin:      mwin,
+		latencies: mwin.schedulingLatencies(),
+	}
+}
+
+func (sp *SchedulingLatencyPanel) Title() string {
+	return "Scheduling latency"
+}
+
+func (sp *SchedulingLatencyPanel) init(win *theme.Window, sl *schedulingLatencies) {
+	value := func(s *TextSpan) *theme.Future[TextSpan] {
+		return theme.Immediate(*s)
+	}
+	tb := TextBuilder{Theme: win.Theme}
+	sp.description.Attributes = []DescriptionAttribute{
+		{Key: "Transitions from ready to running", Value: value(tb.Span(local.Sprintf("%d", len(sl.Latencies))))},
+		{Key: "Total", Value: value(tb.Span(sl.Total.String()))},
+		{Key: "Mean", Value: value(tb.Span(sl.Mean.String()))},
+		{Key: "p50", Value: value(tb.Span(sl.P50.String()))},
+		{Key: "p90", Value: value(tb.Span(sl.P90.String()))},
+		{Key: "p99", Value: value(tb.Span(sl.P99.String()))},
+		{Key: "Max", Value: value(tb.Span(sl.Max.String()))},
+	}
+
+	sp.hist.Config = widget.HistogramConfig{RejectOutliers: true, Bins: widget.DefaultHistogramBins}
+	sp.computeHistogram(win, sl)
+}
+
+func (sp *SchedulingLatencyPanel) computeHistogram(win *theme.Window, sl *schedulingLatencies) {
+	cfg := &sp.hist.Config
+	var durations []time.Duration
+	for _, l := range sl.Latencies {
+		d := l.Span.Duration()
+		if fd := widget.FloatDuration(d); fd >= cfg.Start && (cfg.End == 0 || fd <= cfg.End) {
+			durations = append(durations, d)
+		}
+	}
+	sp.hist.Set(win, durations)
+}
+
+func (sp *SchedulingLatencyPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.SchedulingLatencyPanel.Layout").End()
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	sl, ok := sp.latencies.Result()
+	if ok && !sp.initialized {
+		sp.init(win, sl)
+		sp.initialized = true
+	}
+
+	tabs := []string{"Functions", "Longest waits", "Histogram"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			// Right-aligned buttons should be aligned with the right side of the visible panel, not the width of the
+			// panel contents, nor the infinite width of a possible surrounding list.
+			gtx.Constraints.Max.X = gtx.Constraints.Min.X
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if !ok || sl.Plot == nil {
+						gtx.Queue = nil
+						return theme.Button(win.Theme, &sp.togglePlot.Clickable, "Show plot on canvas").Layout(win, gtx)
+					}
+					label := "Show plot on canvas"
+					if sp.mwin.canvas.HasPlot(sl.Plot) {
+						label = "Hide plot from canvas"
+					}
+					return theme.Button(win.Theme, &sp.togglePlot.Clickable, label).Layout(win, gtx)
+				}),
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, sp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !ok {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing scheduling latencies…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			if len(sl.Latencies) == 0 {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "The trace contains no transitions from ready to running.", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min = image.Point{}
+					return sp.description.Layout(win, gtx)
+				}),
+
+				layout.Rigid(layout.Spacer{Height: 10}.Layout),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return theme.Tabbed(&sp.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+						switch tabs[sp.tabbedState.Current] {
+						case "Functions":
+							return sp.functionList.Layout(win, gtx, sl.Functions)
+						case "Longest waits":
+							return sp.latencyList.Layout(win, gtx, sl.Latencies)
+						case "Histogram":
+							return sp.hist.Layout(win, gtx)
+						default:
+							panic("unreachable")
+						}
+					})
+				}),
+			)
+		}),
+	)
+
+	for _, ev := range sp.functionList.Clicked() {
+		handleLinkClick(win, sp.mwin, ev)
+	}
+	for _, ev := range sp.latencyList.Clicked() {
+		handleLinkClick(win, sp.mwin, ev)
+	}
+
+	for sp.togglePlot.Clicked() {
+		if ok && sl.Plot != nil {
+			if sp.mwin.canvas.HasPlot(sl.Plot) {
+				sp.mwin.canvas.RemovePlot(sl.Plot)
+			} else {
+				sp.mwin.canvas.AddPlot(sl.Plot)
+			}
+		}
+	}
+
+	if ok && sp.hist.Changed() {
+		sp.computeHistogram(win, sl)
+	}
+
+	for sp.PanelButtons.Backed() {
+		sp.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+type functionSchedulingLatencyList struct {
+	list  widget.List
+	texts allocator[Text]
+}
+
+func (fl *functionSchedulingLatencyList) Layout(win *theme.Window, gtx layout.Context, fns []*functionSchedulingLatencies) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.functionSchedulingLatencyList.Layout").End()
+
+	fl.list.Axis = layout.Vertical
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < fl.texts.Len() {
+			txt = fl.texts.Ptr(txtCnt)
+		} else {
+			txt = fl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		duration := func(d time.Duration) {
+			value, unit := durationNumberFormatSITable.format(d)
+			txt.Span(value)
+			txt.Span(" ")
+			s := txt.Span(unit)
+			s.Font.Variant = "Mono"
+			txt.Alignment = text.End
+		}
+
+		fn := fns[row]
+		switch col {
+		case 0: // Function
+			txt.Link(fn.Fn.Fn, fn.Fn)
+		case 1: // Count
+			txt.Span(local.Sprintf("%d", fn.Count))
+			txt.Alignment = text.End
+		case 2:
+			duration(fn.Total)
+		case 3:
+			duration(fn.Mean)
+		case 4:
+			duration(fn.P99)
+		case 5:
+			duration(fn.Max)
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	// XXX the widths depend on the font and scaling
+	columns := []theme.TableListColumn{
+		{Name: "Function", MinWidth: 400, MaxWidth: 400},
+		{Name: "Count", MinWidth: 100, MaxWidth: 100},
+		{Name: "Total", MinWidth: 150, MaxWidth: 150},
+		{Name: "Mean", MinWidth: 150, MaxWidth: 150},
+		{Name: "p99", MinWidth: 150, MaxWidth: 150},
+		{Name: "Max", MinWidth: 150, MaxWidth: 150},
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns,
+		List:          &fl.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(fns), cellFn)
+	fl.texts.Truncate(txtCnt)
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (fl *functionSchedulingLatencyList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < fl.texts.Len(); i++ {
+		txt := fl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
+
+type schedulingLatencyList struct {
+	list widget.List
+
+	spanObjects allocator[SpanRef]
+	texts       allocator[Text]
+}
+
+func (ll *schedulingLatencyList) Layout(win *theme.Window, gtx layout.Context, latencies []schedulingLatency) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.schedulingLatencyList.Layout").End()
+
+	ll.list.Axis = layout.Vertical
+	ll.spanObjects.Reset()
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < ll.texts.Len() {
+			txt = ll.texts.Ptr(txtCnt)
+		} else {
+			txt = ll.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		l := latencies[row]
+		switch col {
+		case 0: // Time
+			txt.Link(formatTimestamp(l.Span.Start), ll.spanObjects.Allocate(SpanRef{l.Goroutine, l.Span}))
+			txt.Alignment = text.End
+		case 1: // Latency
+			value, unit := durationNumberFormatSITable.format(l.Span.Duration())
+			txt.Span(value)
+			txt.Span(" ")
+			s := txt.Span(unit)
+			s.Font.Variant = "Mono"
+			txt.Alignment = text.End
+		case 2: // Goroutine
+			txt.Link(local.Sprintf("%d", l.Goroutine.ID), l.Goroutine)
+			txt.Alignment = text.End
+		case 3: // Function
+			txt.Link(l.Goroutine.Function.Fn, l.Goroutine.Function)
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	// XXX the widths depend on the font and scaling
+	columns := []theme.TableListColumn{
+		{Name: "Time", MinWidth: 200, MaxWidth: 200},
+		{Name: "Latency", MinWidth: 150, MaxWidth: 150},
+		{Name: "Goroutine", MinWidth: 120, MaxWidth: 120},
+		{Name: "Function"},
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns,
+		List:          &ll.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(latencies), cellFn)
+	ll.texts.Truncate(txtCnt)
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (ll *schedulingLatencyList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < ll.texts.Len(); i++ {
+		txt := ll.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
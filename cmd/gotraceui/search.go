@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"regexp"
+	rtrace "runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/io/key"
+	"golang.org/x/exp/slices"
+)
+
+// Span searches find goroutine spans that match all of a set of terms:
+//
+//	state:select,recv    spans in any of the states, using the names of the timeline query language
+//	dur>=5ms, dur<=1s    spans that lasted at least or at most the duration
+//	stack:regexp         spans whose stacks contain a function whose name matches the regular expression
+//	file:regexp          spans whose stacks contain a function in a file that matches the regular expression
+//	region:regexp        user regions whose names match the regular expression
+//	tag:network          spans that have the tag
+//	gid:123              spans of the goroutine with ID 123
+//	fn:pkg.Fn            spans of goroutines whose function names contain the string
+
+// Filters only support inclusive bounds, so we reject > and < instead of silently treating them as >= and <=.
+var spanSearchDurationRe = regexp.MustCompile(`^dur(>=|<=|>|<)(.+)$`)
+
+func parseSpanSearch(tr *Trace, s string) (Filter, error) {
+	f := Filter{Mode: FilterModeAnd}
+
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return Filter{}, err
+	}
+	for _, tok := range tokens {
+		errorf := func(format string, args ...any) error {
+			return &queryError{tok.offset, fmt.Sprintf(format, args...)}
+		}
+		if tok.kind != queryTokenWord {
+			return Filter{}, errorf("parentheses aren't supported in span searches")
+		}
+
+		if m := spanSearchDurationRe.FindStringSubmatch(tok.s); m != nil {
+			d, err := time.ParseDuration(m[2])
+			if err != nil {
+				return Filter{}, errorf("%q is not a valid duration", m[2])
+			}
+			switch m[1] {
+			case ">=":
+				f.Duration.Min = d
+			case "<=":
+				f.Duration.Max = d
+			default:
+				return Filter{}, errorf("durations can only be compared with >= and <=")
+			}
+			continue
+		}
+
+		prefix, value, found := strings.Cut(tok.s, ":")
+		if !found {
+			return Filter{}, errorf("expected one of state:, dur>=, dur<=, stack:, file:, region:, tag:, gid: or fn:")
+		}
+		if value == "" {
+			return Filter{}, errorf("missing value for %s", prefix)
+		}
+		switch prefix {
+		case "state":
+			for _, name := range strings.Split(value, ",") {
+				state, ok := lookupQueryState(tr, name)
+				if !ok {
+					return Filter{}, errorf("unknown state %q", name)
+				}
+				f.States |= 1 << state
+			}
+		case "stack", "file":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return Filter{}, errorf("invalid regular expression: %s", err)
+			}
+			if prefix == "stack" {
+				f.Stack.Function = re
+			} else {
+				f.Stack.File = re
+			}
+		case "region":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return Filter{}, errorf("invalid regular expression: %s", err)
+			}
+			f.Region.Name = re
+		case "tag":
+			tag, ok := spanTagByName(tr, value)
+			if !ok {
+				return Filter{}, errorf("unknown tag %q", value)
+			}
+			f.Tags |= tag
+		case "gid":
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return Filter{}, errorf("%q is not a valid goroutine ID", value)
+			}
+			f.Goroutine.ID = id
+		case "fn":
+			f.Goroutine.Function = value
+		default:
+			return Filter{}, errorf("unknown field %q", prefix)
+		}
+	}
+	return f, nil
+}
+
+type spanSearchMatch struct {
+	timeline *Timeline
+	span     ptrace.Span
+}
+
+// indexSpanSearch finds all goroutine spans and user regions that match the filter, sorted by start time.
+func indexSpanSearch(tr *Trace, timelines []*Timeline, f Filter, cancelled <-chan struct{}) []spanSearchMatch {
+	var out []spanSearchMatch
+	for i, tl := range timelines {
+		if i%1000 == 0 {
+			select {
+			case <-cancelled:
+				return nil
+			default:
+			}
+		}
+
+		for k := range tl.tracks {
+			track := &tl.tracks[k]
+			if track.kind != TrackKindUnspecified && track.kind != TrackKindUserRegions {
+				continue
+			}
+			container := SpanContainer{Timeline: tl, Track: track}
+			for j := 0; j < track.spans.Len(); j++ {
+				if f.Match(tr.Trace, track.spans.Slice(j, j+1), container) {
+					out = append(out, spanSearchMatch{tl, track.spans.At(j)})
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(out, func(a, b spanSearchMatch) bool {
+		return a.span.Start < b.span.Start
+	})
+	return out
+}
+
+// SpanSearch is the search bar that finds spans across all goroutine timelines, highlights them, and navigates between
+// them.
+type SpanSearch struct {
+	mwin *MainWindow
+
+	editor  widget.Editor
+	prev    widget.PrimaryClickable
+	next    widget.PrimaryClickable
+	close   widget.PrimaryClickable
+	closed  bool
+	focus   bool
+	err     error
+	query   string
+	matches *theme.Future[[]spanSearchMatch]
+	current int
+	restore Filter
+}
+
+// Don't bubble up normal key presses while typing in the search bar, like theme.ListWindow does.
+var spanSearchKeyset = key.Set("⎋|(Shift)-[A,B,C,D,E,F,G,H,I,J,K,L,M,N,O,P,Q,R,S,T,U,V,W,X,Y,Z]")
+
+func NewSpanSearch(mwin *MainWindow) *SpanSearch {
+	ss := &SpanSearch{
+		mwin:    mwin,
+		current: -1,
+		focus:   true,
+		restore: mwin.canvas.timeline.filter,
+	}
+	ss.editor.SingleLine = true
+	ss.editor.Submit = true
+	return ss
+}
+
+// Focus moves the keyboard focus to the search bar's input.
+func (ss *SpanSearch) Focus() { ss.focus = true }
+
+// Closed reports whether the user closed the search bar. Closing it restores the previous span highlighting.
+func (ss *SpanSearch) Closed() bool { return ss.closed }
+
+func (ss *SpanSearch) search() {
+	f, err := parseSpanSearch(ss.mwin.trace, ss.editor.Text())
+	ss.err = err
+	if err != nil {
+		return
+	}
+	ss.query = ss.editor.Text()
+	ss.current = -1
+	ss.mwin.canvas.timeline.filter = f
+
+	// Collect the timelines on the UI goroutine, the future mustn't access the canvas.
+	var timelines []*Timeline
+	for _, tl := range ss.mwin.canvas.allTimelines {
+		if _, ok := tl.item.(*ptrace.Goroutine); ok {
+			timelines = append(timelines, tl)
+		}
+	}
+	tr := ss.mwin.trace
+	ss.matches = theme.NewFuture(ss.mwin.twin, func(cancelled <-chan struct{}) []spanSearchMatch {
+		return indexSpanSearch(tr, timelines, f, cancelled)
+	})
+}
+
+// Next navigates to the next match. The first call navigates to the first match at or after the start of the visible
+// portion of the canvas.
+func (ss *SpanSearch) Next() { ss.step(1) }
+
+// Prev navigates to the previous match.
+func (ss *SpanSearch) Prev() { ss.step(-1) }
+
+func (ss *SpanSearch) step(dir int) {
+	if ss.matches == nil {
+		return
+	}
+	matches, ok := ss.matches.Result()
+	if !ok || len(matches) == 0 {
+		return
+	}
+
+	if ss.current == -1 {
+		start := ss.mwin.canvas.start
+		ss.current = sort.Search(len(matches), func(i int) bool {
+			return matches[i].span.Start >= start
+		})
+		if dir < 0 {
+			ss.current--
+		}
+	} else {
+		ss.current += dir
+	}
+	ss.current = (ss.current + len(matches)) % len(matches)
+
+	m := matches[ss.current]
+	ss.mwin.OpenLink(&SpansLink{
+		Timeline: m.timeline,
+		Spans:    ptrace.ToSpans([]ptrace.Span{m.span}),
+		Kind:     SpanLinkKindScrollAndPan,
+	})
+}
+
+func (ss *SpanSearch) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.SpanSearch.Layout").End()
+
+	key.InputOp{Tag: ss, Keys: spanSearchKeyset}.Add(gtx.Ops)
+	if ss.focus {
+		ss.editor.Focus()
+		ss.focus = false
+	}
+
+	var status string
+	if ss.matches == nil {
+		status = "Press enter to search"
+	} else if matches, ok := ss.matches.Result(); !ok {
+		status = "Searching…"
+	} else if len(matches) == 0 {
+		status = "No matches"
+	} else if ss.current == -1 {
+		status = local.Sprintf("%d matches", len(matches))
+	} else {
+		status = local.Sprintf("Match %d of %d", ss.current+1, len(matches))
+	}
+
+	dims := layout.UniformInset(5).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						tb := theme.TextBox(win.Theme, &ss.editor, "Search spans, e.g. state:sync dur>=1ms stack:Mutex.*Lock")
+						tb.Validate = func(s string) bool { return ss.err == nil }
+						return tb.Layout(gtx)
+					}),
+					layout.Rigid(layout.Spacer{Width: 10}.Layout),
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						gtx.Constraints.Min = image.Point{}
+						return widget.Label{MaxLines: 1}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, status, widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+					}),
+					layout.Rigid(layout.Spacer{Width: 10}.Layout),
+					layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &ss.prev.Clickable, "Previous").Layout)),
+					layout.Rigid(layout.Spacer{Width: 5}.Layout),
+					layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &ss.next.Clickable, "Next").Layout)),
+					layout.Rigid(layout.Spacer{Width: 5}.Layout),
+					layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &ss.close.Clickable, "Close").Layout)),
+				)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				if ss.err == nil {
+					return layout.Dimensions{}
+				}
+				return widget.TextLine{Color: rgba(0xFF0000FF)}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, ss.err.Error())
+			}),
+		)
+	})
+
+	for _, ev := range ss.editor.Events() {
+		switch ev.(type) {
+		case widget.ChangeEvent:
+			// Report syntax errors while typing, but only search once the user submits the query.
+			_, ss.err = parseSpanSearch(ss.mwin.trace, ss.editor.Text())
+		case widget.SubmitEvent:
+			if ss.matches != nil && ss.editor.Text() == ss.query {
+				ss.Next()
+			} else {
+				ss.search()
+			}
+		}
+	}
+	for _, ev := range gtx.Events(ss) {
+		if ev, ok := ev.(key.Event); ok && ev.State == key.Press && ev.Name == "⎋" {
+			ss.closed = true
+		}
+	}
+	for ss.prev.Clicked() {
+		ss.Prev()
+	}
+	for ss.next.Clicked() {
+		ss.Next()
+	}
+	for ss.close.Clicked() {
+		ss.closed = true
+	}
+	if ss.closed {
+		ss.mwin.canvas.timeline.filter = ss.restore
+	}
+
+	return dims
+}
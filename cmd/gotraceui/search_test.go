@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestParseSpanSearchDuration(t *testing.T) {
+	for _, test := range []struct {
+		query string
+		min   time.Duration
+		max   time.Duration
+		err   string
+	}{
+		{query: "dur>=5ms", min: 5 * time.Millisecond},
+		{query: "dur<=1s", max: time.Second},
+		{query: "dur>=5ms dur<=1s", min: 5 * time.Millisecond, max: time.Second},
+		{query: "dur>5ms", err: "durations can only be compared with >= and <="},
+		{query: "dur<1s", err: "durations can only be compared with >= and <="},
+		{query: "dur>=soon", err: `"soon" is not a valid duration`},
+	} {
+		tr := &Trace{Trace: &ptrace.Trace{}}
+		f, err := parseSpanSearch(tr, test.query)
+		if test.err != "" {
+			var qerr *queryError
+			if !errors.As(err, &qerr) {
+				t.Errorf("query %q: got error %v, want %q", test.query, err, test.err)
+			} else if qerr.msg != test.err {
+				t.Errorf("query %q: got error %q, want %q", test.query, qerr.msg, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("query %q: unexpected error: %s", test.query, err)
+			continue
+		}
+		if f.Duration.Min != test.min || f.Duration.Max != test.max {
+			t.Errorf("query %q: got [%s, %s], want [%s, %s]", test.query, f.Duration.Min, f.Duration.Max, test.min, test.max)
+		}
+	}
+}
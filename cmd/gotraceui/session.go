@@ -0,0 +1,480 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+
+	"gioui.org/x/explorer"
+)
+
+// A Session records the state of an investigation: the visible portion of the canvas, the navigation history, display
+// options, span highlighting, bookmarks and open panels. Sessions are stored next to the trace, in
+// <trace>.gotraceui-session.json, so that they travel with the trace when it is handed to others. If the trace's path
+// isn't known or its directory isn't writable, sessions are stored in the gotraceui config directory instead, keyed by
+// the hash of the trace's contents. Either way, they get restored automatically when the trace is opened again.
+// Sessions can also be exported to and opened from arbitrary files.
+type Session struct {
+	Version   int    `json:"version"`
+	TraceHash string `json:"traceHash"`
+
+	Start           trace.Timestamp   `json:"start"`
+	NsPerPx         float64           `json:"nsPerPx"`
+	Y               int               `json:"y"`
+	LocationHistory []sessionLocation `json:"locationHistory"`
+
+	Compact        bool `json:"compact"`
+	StackTracks    bool `json:"stackTracks"`
+	LogMarkers     bool `json:"logMarkers"`
+	TimelineLabels bool `json:"timelineLabels"`
+	FlowArrows     bool `json:"flowArrows"`
+
+	Filter    sessionFilter `json:"filter"`
+	Bookmarks []Bookmark    `json:"bookmarks"`
+	// Sessions written before timelines could be arranged don't have this.
+	Timelines *timelineArrangement `json:"timelines,omitempty"`
+	// The panel history, oldest first. The last panel is the one that was being displayed.
+	Panels []sessionPanel `json:"panels"`
+}
+
+const sessionVersion = 1
+
+type sessionLocation struct {
+	Start   trace.Timestamp `json:"start"`
+	NsPerPx float64         `json:"nsPerPx"`
+	Y       int             `json:"y"`
+}
+
+// sessionFilter is the serialized form of Filter. Regular expressions are stored as their source.
+type sessionFilter struct {
+	Mode              FilterMode      `json:"mode"`
+	States            uint64          `json:"states"`
+	Tags              ptrace.SpanTags `json:"tags"`
+	MinDuration       time.Duration   `json:"minDuration"`
+	MaxDuration       time.Duration   `json:"maxDuration"`
+	StackFunction     string          `json:"stackFunction,omitempty"`
+	StackFile         string          `json:"stackFile,omitempty"`
+	RegionName        string          `json:"regionName,omitempty"`
+	Goroutine         uint64          `json:"goroutine,omitempty"`
+	GoroutineFunction string          `json:"goroutineFunction,omitempty"`
+}
+
+// sessionPanel identifies a panel. Only panels that can be reconstructed from a kind and an ID or name are recorded.
+type sessionPanel struct {
+	Kind string `json:"kind"`
+	// The ID of the goroutine, task or GC cycle the panel is about
+	ID uint64 `json:"id,omitempty"`
+	// The name of the function the panel is about
+	Name string `json:"name,omitempty"`
+}
+
+func regexpSource(re *regexp.Regexp) string {
+	if re == nil {
+		return ""
+	}
+	return re.String()
+}
+
+func compileSessionRegexp(s string) (*regexp.Regexp, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return regexp.Compile(s)
+}
+
+func newSessionFilter(f Filter) sessionFilter {
+	return sessionFilter{
+		Mode:              f.Mode,
+		States:            f.States,
+		Tags:              f.Tags,
+		MinDuration:       f.Duration.Min,
+		MaxDuration:       f.Duration.Max,
+		StackFunction:     regexpSource(f.Stack.Function),
+		StackFile:         regexpSource(f.Stack.File),
+		RegionName:        regexpSource(f.Region.Name),
+		Goroutine:         f.Goroutine.ID,
+		GoroutineFunction: f.Goroutine.Function,
+	}
+}
+
+func (sf sessionFilter) Filter() (Filter, error) {
+	f := Filter{
+		Mode:   sf.Mode,
+		States: sf.States,
+		Tags:   sf.Tags,
+	}
+	f.Duration.Min = sf.MinDuration
+	f.Duration.Max = sf.MaxDuration
+	f.Goroutine.ID = sf.Goroutine
+	f.Goroutine.Function = sf.GoroutineFunction
+
+	var err error
+	if f.Stack.Function, err = compileSessionRegexp(sf.StackFunction); err != nil {
+		return Filter{}, err
+	}
+	if f.Stack.File, err = compileSessionRegexp(sf.StackFile); err != nil {
+		return Filter{}, err
+	}
+	if f.Region.Name, err = compileSessionRegexp(sf.RegionName); err != nil {
+		return Filter{}, err
+	}
+	return f, nil
+}
+
+func newSessionPanel(p theme.Panel) (sessionPanel, bool) {
+	switch p := p.(type) {
+	case *SpansInfo:
+		if g := p.cfg.Goroutine; g != nil {
+			return sessionPanel{Kind: "goroutine", ID: g.ID}, true
+		}
+	case *FunctionInfo:
+		return sessionPanel{Kind: "function", Name: p.fn.Fn}, true
+	case *TaskInfo:
+		return sessionPanel{Kind: "task", ID: p.task.ID}, true
+	case *TasksPanel:
+		return sessionPanel{Kind: "tasks"}, true
+	case *RegionsPanel:
+		return sessionPanel{Kind: "regions"}, true
+	case *LogsPanel:
+		return sessionPanel{Kind: "logs"}, true
+	case *SchedulingLatencyPanel:
+		return sessionPanel{Kind: "schedulingLatency"}, true
+	case *GCPanel:
+		return sessionPanel{Kind: "gc"}, true
+	case *GCTaxPanel:
+		if p.cycle != nil {
+			return sessionPanel{Kind: "gcTax", ID: p.cycle.Seq}, true
+		}
+		return sessionPanel{Kind: "gcTax"}, true
+	case *SyscallsPanel:
+		return sessionPanel{Kind: "syscalls"}, true
+	case *NetworkPanel:
+		return sessionPanel{Kind: "network"}, true
+	case *IdleProcessorsPanel:
+		return sessionPanel{Kind: "idleProcessors"}, true
+	case *CreationTreePanel:
+		if p.focus != nil {
+			return sessionPanel{Kind: "creationTree", ID: p.focus.ID}, true
+		}
+		return sessionPanel{Kind: "creationTree"}, true
+	case *BookmarksPanel:
+		return sessionPanel{Kind: "bookmarks"}, true
+	}
+	return sessionPanel{}, false
+}
+
+// panel recreates the panel. It returns false if the panel refers to objects that don't exist in the trace.
+func (sp sessionPanel) panel(mwin *MainWindow) (theme.Panel, bool) {
+	tr := mwin.trace
+	switch sp.Kind {
+	case "goroutine":
+		if g, ok := lookupGoroutine(tr, sp.ID); ok {
+			return NewGoroutineInfo(mwin, g), true
+		}
+	case "function":
+		if fn, ok := tr.Functions[sp.Name]; ok {
+			return NewFunctionInfo(mwin, fn), true
+		}
+	case "task":
+		for _, t := range tr.Tasks {
+			if t.ID == sp.ID {
+				return NewTaskInfo(mwin, t), true
+			}
+		}
+	case "tasks":
+		return NewTasksPanel(mwin), true
+	case "regions":
+		return NewRegionsPanel(mwin), true
+	case "logs":
+		return NewLogsPanel(mwin), true
+	case "schedulingLatency":
+		return NewSchedulingLatencyPanel(mwin), true
+	case "gc":
+		return NewGCPanel(mwin), true
+	case "gcTax":
+		if sp.ID == 0 {
+			return NewGCTaxPanel(mwin, nil), true
+		}
+		for _, c := range tr.GCCycles {
+			if c.Seq == sp.ID {
+				return NewGCTaxPanel(mwin, c), true
+			}
+		}
+	case "syscalls":
+		return NewSyscallsPanel(mwin), true
+	case "network":
+		return NewNetworkPanel(mwin), true
+	case "idleProcessors":
+		return NewIdleProcessorsPanel(mwin), true
+	case "creationTree":
+		if sp.ID == 0 {
+			return NewCreationTreePanel(mwin, nil), true
+		}
+		if g, ok := lookupGoroutine(tr, sp.ID); ok {
+			return NewCreationTreePanel(mwin, g), true
+		}
+	case "bookmarks":
+		return NewBookmarksPanel(mwin), true
+	}
+	return nil, false
+}
+
+// sidecarSessionPath returns the path of the session file that is stored next to the trace file at tracePath.
+func sidecarSessionPath(tracePath string) string {
+	return tracePath + ".gotraceui-session.json"
+}
+
+// configSessionPath returns the path of the session file in the config directory for the trace with the given hash, or
+// the empty string if there is no config directory.
+func configSessionPath(hash string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gotraceui", "sessions", hash+".json")
+}
+
+func readSession(r io.Reader) (*Session, error) {
+	var s Session
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("couldn't parse session: %w", err)
+	}
+	if s.Version != sessionVersion {
+		return nil, fmt.Errorf("unsupported session version %d", s.Version)
+	}
+	return &s, nil
+}
+
+// session captures the current state of the main window.
+func (mwin *MainWindow) session() *Session {
+	cv := &mwin.canvas
+	s := &Session{
+		Version:   sessionVersion,
+		TraceHash: mwin.traceHash,
+
+		Start:   cv.start,
+		NsPerPx: cv.nsPerPx,
+		Y:       cv.y,
+
+		Compact:        cv.timeline.compact,
+		StackTracks:    cv.timeline.displayStackTracks,
+		LogMarkers:     cv.timeline.displayLogMarkers,
+		TimelineLabels: cv.timeline.displayAllLabels,
+		FlowArrows:     cv.timeline.displayAllFlows,
+
+		Filter: newSessionFilter(cv.timeline.filter),
+	}
+	a := cv.timelineArrangement()
+	s.Timelines = &a
+	if cv.animateTo.animating {
+		s.Start = cv.animateTo.targetStart
+		s.NsPerPx = cv.animateTo.targetNsPerPx
+		s.Y = cv.animateTo.targetY
+	}
+	for _, e := range cv.locationHistory {
+		s.LocationHistory = append(s.LocationHistory, sessionLocation{e.start, e.nsPerPx, e.y})
+	}
+	for _, b := range cv.bookmarks {
+		s.Bookmarks = append(s.Bookmarks, *b)
+	}
+	panels := mwin.panelHistory
+	if mwin.panel != nil {
+		panels = append(panels[:len(panels):len(panels)], mwin.panel)
+	}
+	for _, p := range panels {
+		if sp, ok := newSessionPanel(p); ok {
+			s.Panels = append(s.Panels, sp)
+		}
+	}
+	return s
+}
+
+// applySession restores the state recorded in s. Panels that refer to objects that no longer exist are skipped.
+func (mwin *MainWindow) applySession(s *Session) error {
+	if s.TraceHash != mwin.traceHash {
+		return errors.New("the session belongs to a different trace")
+	}
+	f, err := s.Filter.Filter()
+	if err != nil {
+		return fmt.Errorf("couldn't restore span highlighting: %w", err)
+	}
+
+	cv := &mwin.canvas
+	cv.cancelNavigation()
+	if s.NsPerPx > 0 {
+		cv.start = s.Start
+		cv.nsPerPx = s.NsPerPx
+		cv.y = s.Y
+	}
+	cv.locationHistory = cv.locationHistory[:0]
+	for _, e := range s.LocationHistory {
+		if len(cv.locationHistory) == maxLocationHistoryEntries {
+			break
+		}
+		cv.locationHistory = append(cv.locationHistory, LocationHistoryEntry{e.Start, e.NsPerPx, e.Y})
+	}
+
+	cv.timeline.compact = s.Compact
+	cv.timeline.displayStackTracks = s.StackTracks
+	cv.timeline.displayLogMarkers = s.LogMarkers
+	cv.timeline.displayAllLabels = s.TimelineLabels
+	cv.timeline.displayAllFlows = s.FlowArrows
+	cv.timeline.filter = f
+	if s.Timelines != nil {
+		cv.applyTimelineArrangement(*s.Timelines)
+		cv.arrangement.changed = true
+	}
+
+	cv.bookmarks = cv.bookmarks[:0]
+	cv.bookmarksAdded = 0
+	for _, b := range s.Bookmarks {
+		cv.AddBookmark(b.Name, b.When)
+	}
+
+	mwin.panel = nil
+	mwin.panelHistory = nil
+	for _, sp := range s.Panels {
+		if p, ok := sp.panel(mwin); ok {
+			mwin.openPanel(p)
+		}
+	}
+	return nil
+}
+
+// saveSession writes the current session next to the trace, or to the config directory if that fails, and returns the
+// path of the session file.
+func (mwin *MainWindow) saveSession() (string, error) {
+	b, err := json.MarshalIndent(mwin.session(), "", "\t")
+	if err != nil {
+		return "", err
+	}
+	if mwin.tracePath != "" {
+		path := sidecarSessionPath(mwin.tracePath)
+		if err := os.WriteFile(path, b, 0o666); err == nil {
+			return path, nil
+		}
+	}
+
+	path := configSessionPath(mwin.traceHash)
+	if path == "" {
+		return "", errors.New("couldn't determine the config directory")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, b, 0o666); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// showSessionExportDialog lets the user write the current session to a file of their choosing.
+func (mwin *MainWindow) showSessionExportDialog(gtx layout.Context) {
+	b, err := json.MarshalIndent(mwin.session(), "", "\t")
+	if err != nil {
+		mwin.twin.ShowNotification(gtx, fmt.Sprintf("Couldn't export session: %s", err))
+		return
+	}
+	if mwin.showingExplorer.CompareAndSwap(false, true) {
+		go func() {
+			w, err := mwin.explorer.CreateFile("session.json")
+			mwin.showingExplorer.Store(false)
+			if err == nil {
+				_, err = w.Write(b)
+				if cerr := w.Close(); err == nil {
+					err = cerr
+				}
+			}
+			if err == explorer.ErrUserDecline {
+				return
+			}
+			mwin.commands <- func(mwin *MainWindow, gtx layout.Context) {
+				if err != nil {
+					mwin.twin.ShowNotification(gtx, fmt.Sprintf("Couldn't export session: %s", err))
+				} else {
+					mwin.twin.ShowNotification(gtx, "Exported session")
+				}
+			}
+		}()
+	}
+}
+
+// restoreSavedSession restores the trace's session file, if it has one. The session next to the trace takes
+// precedence over the one in the config directory, unless it belongs to a different trace, which happens when the
+// trace file gets overwritten.
+func (mwin *MainWindow) restoreSavedSession(gtx layout.Context) {
+	var paths []string
+	if mwin.tracePath != "" {
+		paths = append(paths, sidecarSessionPath(mwin.tracePath))
+	}
+	if path := configSessionPath(mwin.traceHash); path != "" {
+		paths = append(paths, path)
+	}
+
+	for _, path := range paths {
+		s, err := readSessionFile(path)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				mwin.twin.ShowNotification(gtx, fmt.Sprintf("Couldn't restore session: %s", err))
+			}
+			continue
+		}
+		if s.TraceHash != mwin.traceHash {
+			continue
+		}
+		if err := mwin.applySession(s); err != nil {
+			mwin.twin.ShowNotification(gtx, fmt.Sprintf("Couldn't restore session: %s", err))
+			return
+		}
+		mwin.twin.ShowNotification(gtx, "Restored previous session")
+		return
+	}
+}
+
+func readSessionFile(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readSession(f)
+}
+
+func (mwin *MainWindow) showSessionOpenDialog() {
+	if mwin.showingExplorer.CompareAndSwap(false, true) {
+		go func() {
+			rc, err := mwin.explorer.ChooseFile(".json")
+			mwin.showingExplorer.Store(false)
+			if err != nil {
+				if err == explorer.ErrUserDecline {
+					return
+				}
+				mwin.commands <- func(mwin *MainWindow, gtx layout.Context) {
+					mwin.twin.ShowNotification(gtx, fmt.Sprintf("Couldn't open session: %s", err))
+				}
+				return
+			}
+			defer rc.Close()
+			s, err := readSession(rc)
+			mwin.commands <- func(mwin *MainWindow, gtx layout.Context) {
+				if err == nil {
+					err = mwin.applySession(s)
+				}
+				if err != nil {
+					mwin.twin.ShowNotification(gtx, fmt.Sprintf("Couldn't open session: %s", err))
+				}
+			}
+		}()
+	}
+}
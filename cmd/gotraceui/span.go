@@ -76,6 +76,8 @@ type SpansInfoConfig struct {
 	Statistics    *theme.Future[*SpansStats]
 	Navigations   SpansInfoConfigNavigations
 	ShowHistogram bool
+	// The goroutine whose spans are being displayed, if the panel describes an entire goroutine
+	Goroutine *ptrace.Goroutine
 }
 
 type SpansInfoConfigNavigations struct {
@@ -119,12 +121,13 @@ func NewSpansInfo(cfg SpansInfoConfig, mwin *MainWindow, spans ptrace.Spans, all
 
 	if si.cfg.Statistics == nil {
 		si.cfg.Statistics = theme.NewFuture(mwin.twin, func(cancelled <-chan struct{}) *SpansStats {
-			return NewSpansStats(si.spans)
+			return NewSpansStats(si.trace, si.spans)
 		})
 	}
 
 	si.spansList = SpanList{
 		Spans: si.spans,
+		Trace: si.trace,
 	}
 
 	si.events = EventList{Trace: si.trace}
@@ -198,7 +201,7 @@ func (si *SpansInfo) buildDefaultDescription() {
 	attrs = append(attrs, DescriptionAttribute{
 		Key: "State",
 		Value: theme.NewFuture(si.mwin.twin, func(cancelled <-chan struct{}) TextSpan {
-			state := stateNames[firstSpan.State]
+			state := si.trace.stateName(firstSpan.State)
 			for i := 1; i < si.spans.Len(); i++ {
 				s := si.spans.At(i).State
 				if s != firstSpan.State {
@@ -211,7 +214,7 @@ func (si *SpansInfo) buildDefaultDescription() {
 	})
 
 	if si.spans.Len() == 1 && firstSpan.Tags != 0 {
-		tags := spanTagStrings(firstSpan.Tags)
+		tags := spanTagStrings(si.trace, firstSpan.Tags)
 		attrs = append(attrs, DescriptionAttribute{
 			Key:   "Tags",
 			Value: value(tb.Span(strings.Join(tags, ", "))),
@@ -364,7 +367,7 @@ func (si *SpansInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dimens
 
 	for si.buttons.copyAsCSV.Clicked() {
 		if stats, ok := si.cfg.Statistics.Result(); ok {
-			si.mwin.win.WriteClipboard(statisticsToCSV(&stats.stats))
+			si.mwin.win.WriteClipboard(statisticsToCSV(si.trace, &stats.stats))
 		}
 	}
 
@@ -409,7 +412,11 @@ func (si *SpansInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dimens
 	for si.buttons.selectUserRegion.Clicked() {
 		needle := si.trace.Strings[si.trace.Event(si.spans.At(0).Event).Args[2]]
 		var out MergedSpans
-		for _, tl := range si.mwin.canvas.timelines {
+		for _, tl := range si.mwin.canvas.allTimelines {
+			if _, ok := tl.item.(*ptrace.Goroutine); !ok {
+				// Task timelines contain the same user regions as goroutine timelines.
+				continue
+			}
 			for _, track := range tl.tracks {
 				if track.kind != TrackKindUserRegions {
 					continue
@@ -447,6 +454,13 @@ type SpansLink struct {
 	Kind     SpanLinkKind
 }
 
+// SpanRef refers to a single span of a goroutine, such as a user region, and is used as the object of text links to
+// that span.
+type SpanRef struct {
+	Goroutine *ptrace.Goroutine
+	Span      ptrace.Span
+}
+
 type SpanLinkKind uint8
 
 const (
@@ -454,7 +468,7 @@ const (
 	SpanLinkKindZoom
 )
 
-func spanTagStrings(tags ptrace.SpanTags) []string {
+func spanTagStrings(tr *Trace, tags ptrace.SpanTags) []string {
 	if tags == 0 {
 		return nil
 	}
@@ -481,9 +495,25 @@ func spanTagStrings(tags ptrace.SpanTags) []string {
 	if tags&ptrace.SpanTagHTTP != 0 {
 		out = append(out, "HTTP")
 	}
+	for i, name := range tr.userTagNames() {
+		if tags&(ptrace.SpanTagUser0<<i) != 0 {
+			out = append(out, name)
+		}
+	}
 	return out
 }
 
+// spanTagByName returns the tag with the given name, as returned by spanTagStrings. Names are matched
+// case-insensitively.
+func spanTagByName(tr *Trace, name string) (ptrace.SpanTags, bool) {
+	for tag := ptrace.SpanTags(1); tag != 0; tag <<= 1 {
+		if names := spanTagStrings(tr, tag); len(names) == 1 && strings.EqualFold(names[0], name) {
+			return tag, true
+		}
+	}
+	return 0, false
+}
+
 var spanListColumns = []theme.TableListColumn{
 	{
 		Name: "Time",
@@ -506,6 +536,7 @@ var spanListColumns = []theme.TableListColumn{
 
 type SpanList struct {
 	Spans ptrace.Spans
+	Trace *Trace
 	list  widget.List
 
 	timestampObjects allocator[trace.Timestamp]
@@ -544,7 +575,7 @@ func (spans *SpanList) Layout(win *theme.Window, gtx layout.Context) layout.Dime
 			s.Font.Variant = "Mono"
 			txt.Alignment = text.End
 		case 2: // State
-			label := stateNamesCapitalized[span.State]
+			label := spans.Trace.stateNameCapitalized(span.State)
 			txt.Span(label)
 		}
 
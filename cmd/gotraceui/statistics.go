@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"math"
 	rtrace "runtime/trace"
 	"sort"
 	"time"
@@ -53,6 +54,7 @@ var statLabels = [...][numStatLabels * 3]string{
 }
 
 type SpansStats struct {
+	tr    *Trace
 	stats ptrace.Statistics
 	// mapping maps from indices of displayed statistics to indices in the stats field
 	mapping []int
@@ -65,7 +67,7 @@ type SpansStats struct {
 	columnClicks [7]widget.PrimaryClickable
 }
 
-func statisticsToCSV(stats *ptrace.Statistics) string {
+func statisticsToCSV(tr *Trace, stats *ptrace.Statistics) string {
 	var buf bytes.Buffer
 	w := csv.NewWriter(&buf)
 
@@ -76,12 +78,13 @@ func statisticsToCSV(stats *ptrace.Statistics) string {
 			continue
 		}
 
-		if stateNamesCapitalized[state] == "" {
+		name := tr.stateNameCapitalized(ptrace.SchedulingState(state))
+		if name == "" {
 			continue
 		}
 		stat := &stats[state]
 		fields := []string{
-			stateNamesCapitalized[state],
+			name,
 			fmt.Sprintf("%d", stat.Count),
 			fmt.Sprintf("%d", stat.Min),
 			fmt.Sprintf("%d", stat.Max),
@@ -96,8 +99,8 @@ func statisticsToCSV(stats *ptrace.Statistics) string {
 	return buf.String()
 }
 
-func NewStats(stats ptrace.Statistics) *SpansStats {
-	gst := &SpansStats{stats: stats}
+func NewStats(tr *Trace, stats ptrace.Statistics) *SpansStats {
+	gst := &SpansStats{tr: tr, stats: stats}
 
 	gst.mapping = make([]int, 0, len(gst.stats))
 
@@ -116,12 +119,25 @@ func NewStats(stats ptrace.Statistics) *SpansStats {
 	return gst
 }
 
-func NewSpansStats(spans ptrace.Spans) *SpansStats {
-	return NewStats(ptrace.ComputeStatistics(spans))
+func NewSpansStats(tr *Trace, spans ptrace.Spans) *SpansStats {
+	return NewStats(tr, ptrace.ComputeStatistics(spans))
 }
 
-func NewGoroutineStats(g *ptrace.Goroutine) *SpansStats {
-	return NewStats(g.Statistics())
+// percentile returns the p-th percentile, with p in [0, 1], of a sorted slice of durations, using the nearest-rank
+// method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+func NewGoroutineStats(tr *Trace, g *ptrace.Goroutine) *SpansStats {
+	return NewStats(tr, g.Statistics())
 }
 
 func (gs *SpansStats) computeSizes(gtx layout.Context, th *theme.Theme) [numStatLabels]image.Point {
@@ -153,8 +169,8 @@ func (gs *SpansStats) computeSizes(gtx layout.Context, th *theme.Theme) [numStat
 
 	// Column 1 contains strings, so the width is that of the widest shaped string
 	size := shape(statLabels[gs.numberFormat][0+numStatLabels], fLabel)
-	for _, name := range stateNamesCapitalized {
-		size2 := shape(name, fContent)
+	for state := ptrace.SchedulingState(0); state < ptrace.StateLast; state++ {
+		size2 := shape(gs.tr.stateNameCapitalized(state), fContent)
 		if size2.X > size.X {
 			size.X = size2.X
 		}
@@ -243,17 +259,21 @@ func sortStats[T constraints.Ordered](stats *ptrace.Statistics, mapping []int, d
 	}
 }
 
+func (gs *SpansStats) stateName(idx int) string {
+	return gs.tr.stateNameCapitalized(ptrace.SchedulingState(idx))
+}
+
 func (gs *SpansStats) sort() {
 	switch gs.sortCol {
 	case 0:
 		// OPT(dh): don't use sort.Slice, it allocates
 		if gs.sortDescending {
 			sort.Slice(gs.mapping, func(i, j int) bool {
-				return stateNamesCapitalized[gs.mapping[i]] >= stateNamesCapitalized[gs.mapping[j]]
+				return gs.stateName(gs.mapping[i]) >= gs.stateName(gs.mapping[j])
 			})
 		} else {
 			sort.Slice(gs.mapping, func(i, j int) bool {
-				return stateNamesCapitalized[gs.mapping[i]] < stateNamesCapitalized[gs.mapping[j]]
+				return gs.stateName(gs.mapping[i]) < gs.stateName(gs.mapping[j])
 			})
 		}
 	case 1:
@@ -353,7 +373,7 @@ func (gs *SpansStats) Layout(win *theme.Window, gtx layout.Context) layout.Dimen
 			switch col {
 			case 0:
 				// type
-				value = stateNamesCapitalized[n]
+				value = gs.stateName(n)
 			case 1:
 				value = local.Sprintf("%d", gs.stats[n].Count)
 				if gs.stats[n].Count == 0 {
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	rtrace "runtime/trace"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/op"
+	"gioui.org/text"
+	"golang.org/x/exp/constraints"
+	"golang.org/x/exp/slices"
+)
+
+// Functions that merely enter the kernel on behalf of more descriptive wrappers, such as syscall.read.
+var genericSyscallPrefixes = [...]string{
+	"syscall.Syscall",
+	"syscall.RawSyscall",
+	"syscall.syscall",
+	"syscall.rawSyscall",
+	"syscall.rawVforkSyscall",
+	"golang.org/x/sys/unix.Syscall",
+	"golang.org/x/sys/unix.RawSyscall",
+	"golang.org/x/sys/unix.syscall",
+	"runtime.",
+}
+
+// functionPackage returns the import path of the package that declares fn.
+func functionPackage(fn string) string {
+	slash := strings.LastIndexByte(fn, '/')
+	if dot := strings.IndexByte(fn[slash+1:], '.'); dot != -1 {
+		return fn[:slash+1+dot]
+	}
+	return fn
+}
+
+// isStdlibFunction reports whether fn belongs to the standard library or one of the golang.org/x/sys packages, which
+// make syscalls on behalf of the user.
+func isStdlibFunction(fn string) bool {
+	pkg := functionPackage(fn)
+	if strings.HasPrefix(pkg, "golang.org/x/sys/") {
+		return true
+	}
+	first, _, _ := strings.Cut(pkg, "/")
+	return !strings.Contains(first, ".")
+}
+
+// syscallName returns the most descriptive name of the syscall made by the stack.
+func syscallName(tr *Trace, stk []uint64) string {
+	for _, pc := range stk {
+		fn := tr.PCs[pc].Fn
+		generic := false
+		for _, prefix := range genericSyscallPrefixes {
+			if strings.HasPrefix(fn, prefix) {
+				generic = true
+				break
+			}
+		}
+		if !generic {
+			return fn
+		}
+	}
+	return tr.PCs[stk[0]].Fn
+}
+
+// userCallSite returns the frame of the user code that caused a syscall or blocking operation. If all frames belong to
+// the standard library, it returns the outermost frame.
+func userCallSite(tr *Trace, stk []uint64) trace.Frame {
+	for _, pc := range stk {
+		if frame := tr.PCs[pc]; !isStdlibFunction(frame.Fn) {
+			return frame
+		}
+	}
+	return tr.PCs[stk[len(stk)-1]]
+}
+
+type syscallKey struct {
+	Name     string
+	CallSite trace.Frame
+}
+
+type syscallStatistics struct {
+	syscallKey
+
+	// The number of syscalls, including those that didn't block
+	Count int
+	// The number of syscalls that blocked for long enough for the P to be handed off to another M
+	Handoffs int
+	// Statistics of blocking syscalls. Non-blocking syscalls don't have a known duration.
+	Total, P99, Max time.Duration
+	Longest         SpanRef
+}
+
+type syscallStatisticsList []*syscallStatistics
+
+// computeSyscallStatistics groups all syscalls by name and call site. Syscalls that were already in progress when
+// tracing started have no stack and are grouped under an empty name.
+func computeSyscallStatistics(tr *Trace, cancelled <-chan struct{}) syscallStatisticsList {
+	type group struct {
+		stats     *syscallStatistics
+		durations []time.Duration
+	}
+	groups := map[syscallKey]*group{}
+	getGroup := func(stkID uint32) *group {
+		var key syscallKey
+		if stk := tr.Stacks[stkID]; len(stk) != 0 {
+			key = syscallKey{syscallName(tr, stk), userCallSite(tr, stk)}
+		}
+		grp, ok := groups[key]
+		if !ok {
+			grp = &group{stats: &syscallStatistics{syscallKey: key}}
+			groups[key] = grp
+		}
+		return grp
+	}
+
+	for i, g := range tr.Goroutines {
+		if i%1000 == 0 {
+			select {
+			case <-cancelled:
+				return nil
+			default:
+			}
+		}
+
+		for _, evID := range g.Events {
+			if ev := tr.Event(evID); ev.Type == trace.EvGoSysCall {
+				getGroup(ev.StkID).stats.Count++
+			}
+		}
+
+		for j := 0; j < g.Spans.Len(); j++ {
+			s := g.Spans.At(j)
+			if tr.BaseState(s.State) != ptrace.StateBlockedSyscall {
+				continue
+			}
+			ev := tr.Event(s.Event)
+			grp := getGroup(ev.StkID)
+			switch ev.Type {
+			case trace.EvGoSysBlock:
+				// The preceding EvGoSysCall has already been counted.
+				grp.stats.Handoffs++
+			case trace.EvGoInSyscall:
+				grp.stats.Count++
+			}
+			d := s.Duration()
+			grp.durations = append(grp.durations, d)
+			grp.stats.Total += d
+			if d > grp.stats.Max || grp.stats.Longest.Goroutine == nil {
+				grp.stats.Max = d
+				grp.stats.Longest = SpanRef{g, s}
+			}
+		}
+	}
+
+	out := make(syscallStatisticsList, 0, len(groups))
+	for _, grp := range groups {
+		slices.Sort(grp.durations)
+		grp.stats.P99 = percentile(grp.durations, 0.99)
+		out = append(out, grp.stats)
+	}
+	slices.SortFunc(out, func(a, b *syscallStatistics) bool {
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Count > b.Count
+	})
+	return out
+}
+
+// SyscallsPanel aggregates syscalls by name and call site.
+type SyscallsPanel struct {
+	mwin        *MainWindow
+	syscalls    *theme.Future[syscallStatisticsList]
+	initialized bool
+
+	description Description
+	list        syscallList
+
+	theme.PanelButtons
+}
+
+func NewSyscallsPanel(mwin *MainWindow) *SyscallsPanel {
+	tr := mwin.trace
+	return &SyscallsPanel{
+		mwin: mwin,
+		syscalls: theme.NewFuture(mwin.twin, func(cancelled <-chan struct{}) syscallStatisticsList {
+			return computeSyscallStatistics(tr, cancelled)
+		}),
+		list: syscallList{
+			sortCol:        syscallColumnTotal,
+			sortDescending: true,
+		},
+	}
+}
+
+func (sp *SyscallsPanel) Title() string {
+	return "Syscalls"
+}
+
+func (sp *SyscallsPanel) init(win *theme.Window, syscalls syscallStatisticsList) {
+	var count, handoffs int
+	var total time.Duration
+	for _, s := range syscalls {
+		count += s.Count
+		handoffs += s.Handoffs
+		total += s.Total
+	}
+
+	value := func(s *TextSpan) *theme.Future[TextSpan] {
+		return theme.Immediate(*s)
+	}
+	tb := TextBuilder{Theme: win.Theme}
+	sp.description.Attributes = []DescriptionAttribute{
+		{Key: "Syscalls", Value: value(tb.Span(local.Sprintf("%d", count)))},
+		{Key: "Distinct syscalls and call sites", Value: value(tb.Span(local.Sprintf("%d", len(syscalls))))},
+		{Key: "P handoffs", Value: value(tb.Span(local.Sprintf("%d", handoffs)))},
+		{Key: "Time blocked in syscalls", Value: value(tb.Span(total.String()))},
+	}
+
+	// Clone so that sorting doesn't affect the cached future's value.
+	sp.list.syscalls = slices.Clone(syscalls)
+	sp.list.sort()
+}
+
+func (sp *SyscallsPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.SyscallsPanel.Layout").End()
+
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	syscalls, ok := sp.syscalls.Result()
+	if ok && !sp.initialized {
+		sp.init(win, syscalls)
+		sp.initialized = true
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, sp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !ok {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing syscall statistics…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			if len(syscalls) == 0 {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "The trace contains no syscalls.", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min = image.Point{}
+					return sp.description.Layout(win, gtx)
+				}),
+
+				layout.Rigid(layout.Spacer{Height: 10}.Layout),
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return sp.list.Layout(win, gtx)
+				}),
+			)
+		}),
+	)
+
+	for _, ev := range sp.list.Clicked() {
+		handleLinkClick(win, sp.mwin, ev)
+	}
+
+	for sp.PanelButtons.Backed() {
+		sp.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+const (
+	syscallColumnName = iota
+	syscallColumnCallSite
+	syscallColumnCount
+	syscallColumnHandoffs
+	syscallColumnTotal
+	syscallColumnP99
+	syscallColumnMax
+	syscallColumnLast
+)
+
+// XXX the widths depend on the font and scaling
+var syscallColumns = [syscallColumnLast]theme.TableListColumn{
+	syscallColumnName:     {Name: "Syscall", MinWidth: 250, MaxWidth: 250},
+	syscallColumnCallSite: {Name: "Call site", MinWidth: 500, MaxWidth: 500},
+	syscallColumnCount:    {Name: "Count", MinWidth: 100, MaxWidth: 100},
+	syscallColumnHandoffs: {Name: "P handoffs", MinWidth: 120, MaxWidth: 120},
+	syscallColumnTotal:    {Name: "Total blocked", MinWidth: 150, MaxWidth: 150},
+	syscallColumnP99:      {Name: "p99 blocked", MinWidth: 150, MaxWidth: 150},
+	syscallColumnMax:      {Name: "Max blocked", MinWidth: 150, MaxWidth: 150},
+}
+
+func sortSyscalls[T constraints.Ordered](syscalls syscallStatisticsList, descending bool, get func(*syscallStatistics) T) {
+	if descending {
+		slices.SortStableFunc(syscalls, func(a, b *syscallStatistics) bool {
+			return get(a) > get(b)
+		})
+	} else {
+		slices.SortStableFunc(syscalls, func(a, b *syscallStatistics) bool {
+			return get(a) < get(b)
+		})
+	}
+}
+
+type syscallList struct {
+	syscalls syscallStatisticsList
+	list     widget.List
+
+	sortCol        int
+	sortDescending bool
+	columnClicks   [syscallColumnLast]widget.PrimaryClickable
+
+	spanObjects allocator[SpanRef]
+	texts       allocator[Text]
+}
+
+func (sl *syscallList) sort() {
+	switch sl.sortCol {
+	case syscallColumnName:
+		sortSyscalls(sl.syscalls, sl.sortDescending, func(s *syscallStatistics) string { return s.Name })
+	case syscallColumnCallSite:
+		sortSyscalls(sl.syscalls, sl.sortDescending, func(s *syscallStatistics) string { return s.CallSite.Fn })
+	case syscallColumnCount:
+		sortSyscalls(sl.syscalls, sl.sortDescending, func(s *syscallStatistics) int { return s.Count })
+	case syscallColumnHandoffs:
+		sortSyscalls(sl.syscalls, sl.sortDescending, func(s *syscallStatistics) int { return s.Handoffs })
+	case syscallColumnTotal:
+		sortSyscalls(sl.syscalls, sl.sortDescending, func(s *syscallStatistics) time.Duration { return s.Total })
+	case syscallColumnP99:
+		sortSyscalls(sl.syscalls, sl.sortDescending, func(s *syscallStatistics) time.Duration { return s.P99 })
+	case syscallColumnMax:
+		sortSyscalls(sl.syscalls, sl.sortDescending, func(s *syscallStatistics) time.Duration { return s.Max })
+	default:
+		panic("unreachable")
+	}
+}
+
+func (sl *syscallList) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.syscallList.Layout").End()
+
+	for col := range sl.columnClicks {
+		for sl.columnClicks[col].Clicked() {
+			if col == sl.sortCol {
+				sl.sortDescending = !sl.sortDescending
+			} else {
+				sl.sortCol = col
+				// Names sort alphabetically, numbers from largest to smallest.
+				sl.sortDescending = col != syscallColumnName && col != syscallColumnCallSite
+			}
+			sl.sort()
+		}
+	}
+
+	sl.list.Axis = layout.Vertical
+	sl.spanObjects.Reset()
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < sl.texts.Len() {
+			txt = sl.texts.Ptr(txtCnt)
+		} else {
+			txt = sl.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		s := sl.syscalls[row]
+		switch col {
+		case syscallColumnName:
+			if s.Name == "" {
+				txt.Span("<unknown>")
+			} else {
+				txt.Span(s.Name)
+			}
+		case syscallColumnCallSite:
+			if s.CallSite.Fn != "" {
+				txt.Span(fmt.Sprintf("%s %s:%d", s.CallSite.Fn, s.CallSite.File, s.CallSite.Line))
+			}
+		case syscallColumnCount:
+			txt.Span(local.Sprintf("%d", s.Count))
+			txt.Alignment = text.End
+		case syscallColumnHandoffs:
+			txt.Span(local.Sprintf("%d", s.Handoffs))
+			txt.Alignment = text.End
+		case syscallColumnTotal:
+			layoutDuration(txt, s.Total)
+		case syscallColumnP99:
+			layoutDuration(txt, s.P99)
+		case syscallColumnMax:
+			if s.Longest.Goroutine == nil {
+				// None of the syscalls blocked
+				layoutDuration(txt, s.Max)
+			} else {
+				value, unit := durationNumberFormatSITable.format(s.Max)
+				txt.Link(value, sl.spanObjects.Allocate(s.Longest))
+				txt.Span(" ")
+				s := txt.Span(unit)
+				s.Font.Variant = "Mono"
+				txt.Alignment = text.End
+			}
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	columns := syscallColumns
+	if sl.sortDescending {
+		columns[sl.sortCol].Name += "▼"
+	} else {
+		columns[sl.sortCol].Name += "▲"
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       columns[:],
+		List:          &sl.list,
+		ColumnPadding: gtx.Dp(10),
+		ColumnClicks:  sl.columnClicks[:],
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(sl.syscalls), cellFn)
+	sl.texts.Truncate(txtCnt)
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (sl *syscallList) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < sl.texts.Len(); i++ {
+		txt := sl.texts.Ptr(i)
+		out = append(out, txt.Events()...)
+	}
+	return out
+}
@@ -0,0 +1,673 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	rtrace "runtime/trace"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/clip"
+	"honnef.co/go/gotraceui/gesture"
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/op"
+	"gioui.org/text"
+)
+
+type TaskLinkKind uint8
+
+const (
+	TaskLinkKindOpen TaskLinkKind = iota
+	TaskLinkKindScroll
+	TaskLinkKindZoom
+)
+
+type TaskLink struct {
+	aLink
+
+	Task *ptrace.Task
+	Kind TaskLinkKind
+}
+
+func taskLabel(t *ptrace.Task) string {
+	if t.Stub() {
+		// We don't know the names of tasks that were created before tracing began.
+		return local.Sprintf("task %d", t.ID)
+	}
+	return local.Sprintf("task %d: %s", t.ID, t.Name)
+}
+
+func taskSpanTooltip(t *ptrace.Task) func(win *theme.Window, gtx layout.Context, tr *Trace, state SpanTooltipState) layout.Dimensions {
+	return func(win *theme.Window, gtx layout.Context, tr *Trace, state SpanTooltipState) layout.Dimensions {
+		return TaskTooltip{t, tr}.Layout(win, gtx)
+	}
+}
+
+func NewTaskTimeline(tr *Trace, cv *Canvas, t *ptrace.Task) *Timeline {
+	shortName := local.Sprintf("task %d", t.ID)
+	spanLabel := t.Name
+	if t.Stub() {
+		spanLabel = shortName
+	}
+
+	tl := &Timeline{
+		tracks: []Track{{
+			spans: ptrace.ToSpans([]ptrace.Span{{
+				Start: t.Start,
+				End:   t.End,
+				Event: t.Event,
+				State: ptrace.StateTask,
+			}}),
+		}},
+		buildTrackWidgets: func(tracks []Track) {
+			for i := range tracks {
+				track := &tracks[i]
+				switch track.kind {
+				case TrackKindUnspecified:
+					*track.TrackWidget = TrackWidget{
+						spanLabel:   singleSpanLabel(spanLabel, false),
+						spanTooltip: taskSpanTooltip(t),
+						spanColor:   singleSpanColor(colorStateTask),
+					}
+
+				case TrackKindUserRegions:
+					*track.TrackWidget = TrackWidget{
+						spanLabel:   userRegionSpanLabel,
+						spanTooltip: userRegionSpanTooltip,
+						spanColor:   singleSpanColor(colorStateUserRegion),
+					}
+
+				default:
+					panic(fmt.Sprintf("unexpected timeline track kind %d", track.kind))
+				}
+			}
+		},
+		widgetTooltip: func(win *theme.Window, gtx layout.Context, tl *Timeline) layout.Dimensions {
+			return TaskTooltip{t, cv.trace}.Layout(win, gtx)
+		},
+		item:      t,
+		label:     taskLabel(t),
+		shortName: shortName,
+	}
+
+	// Regions of a task can run concurrently on different goroutines. Distribute them over as few tracks as possible
+	// such that no two regions in the same track overlap. Regions are sorted by start time, so filling the first track
+	// with room is optimal.
+	var tracks [][]ptrace.Span
+	for i := 0; i < t.Regions.Len(); i++ {
+		s := t.Regions.At(i)
+		placed := false
+		for j, track := range tracks {
+			if track[len(track)-1].End <= s.Start {
+				tracks[j] = append(track, s)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			tracks = append(tracks, []ptrace.Span{s})
+		}
+	}
+	for _, spans := range tracks {
+		tl.tracks = append(tl.tracks, Track{spans: ptrace.ToSpans(spans), kind: TrackKindUserRegions})
+	}
+
+	return tl
+}
+
+type TaskTooltip struct {
+	t     *ptrace.Task
+	trace *Trace
+}
+
+func (tt TaskTooltip) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.TaskTooltip.Layout").End()
+
+	d := time.Duration(tt.t.End - tt.t.Start)
+	observedStart := !tt.t.Stub()
+	observedEnd := tt.t.EndEvent != 0
+
+	var fmts []string
+	var args []any
+
+	if observedStart {
+		fmts = append(fmts, "Task %d: %s\n")
+		args = append(args, tt.t.ID, tt.t.Name)
+	} else {
+		fmts = append(fmts, "Task %d\n")
+		args = append(args, tt.t.ID)
+	}
+
+	if observedStart {
+		fmts = append(fmts, "Created at: %s")
+		args = append(args, formatTimestamp(tt.t.Start))
+	} else {
+		fmts = append(fmts, "Created at: before trace start")
+	}
+
+	if observedEnd {
+		fmts = append(fmts, "Ended at: %s")
+		args = append(args, formatTimestamp(tt.t.End))
+	} else {
+		fmts = append(fmts, "Ended at: after trace end")
+	}
+
+	if observedStart && observedEnd {
+		fmts = append(fmts, "Lifetime: %s")
+	} else {
+		fmts = append(fmts, "Observed duration: %s")
+	}
+	args = append(args, roundDuration(d))
+
+	if tt.t.Parent != nil {
+		fmts = append(fmts, "Parent: task %d")
+		args = append(args, tt.t.Parent.ID)
+	}
+
+	fmts = append(fmts, "Subtasks: %d", "Goroutines: %d", "Regions: %d")
+	args = append(args, len(tt.t.Children), len(tt.t.Goroutines), tt.t.Regions.Len())
+
+	l := local.Sprintf(strings.Join(fmts, "\n"), args...)
+
+	return theme.Tooltip(win.Theme, l).Layout(win, gtx)
+}
+
+func taskLinkContextMenu(mwin *MainWindow, obj *ptrace.Task) []*theme.MenuItem {
+	return []*theme.MenuItem{
+		{
+			Label: PlainLabel("Scroll to task"),
+			Do: func(gtx layout.Context) {
+				mwin.OpenLink(&TaskLink{Task: obj, Kind: TaskLinkKindScroll})
+			},
+		},
+		{
+			Label: PlainLabel("Zoom to task"),
+			Do: func(gtx layout.Context) {
+				mwin.OpenLink(&TaskLink{Task: obj, Kind: TaskLinkKindZoom})
+			},
+		},
+		{
+			Label: PlainLabel("Show task information"),
+			Do: func(gtx layout.Context) {
+				mwin.OpenLink(&TaskLink{Task: obj, Kind: TaskLinkKindOpen})
+			},
+		},
+	}
+}
+
+type TaskInfo struct {
+	task          *ptrace.Task
+	mwin          *MainWindow
+	description   Description
+	tabbedState   theme.TabbedState
+	subtasks      TaskTree
+	goroutineList GoroutineList
+	regionList    SpanList
+
+	buttons struct {
+		scrollToTask widget.PrimaryClickable
+		zoomToTask   widget.PrimaryClickable
+	}
+
+	theme.PanelButtons
+}
+
+func NewTaskInfo(mwin *MainWindow, t *ptrace.Task) *TaskInfo {
+	ti := &TaskInfo{
+		task:       t,
+		mwin:       mwin,
+		subtasks:   TaskTree{Roots: t.Children},
+		regionList: SpanList{Spans: t.Regions, Trace: mwin.trace},
+	}
+
+	value := func(s *TextSpan) *theme.Future[TextSpan] {
+		return theme.Immediate(*s)
+	}
+	tb := TextBuilder{Theme: mwin.twin.Theme}
+	var attrs []DescriptionAttribute
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "Task",
+		Value: value(tb.Span(local.Sprintf("%d", t.ID))),
+	})
+
+	if !t.Stub() {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Name",
+			Value: value(tb.Span(t.Name)),
+		})
+	}
+
+	if t.Parent != nil {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Parent",
+			Value: value(tb.Link(taskLabel(t.Parent), t.Parent)),
+		})
+	}
+
+	observedStart := !t.Stub()
+	observedEnd := t.EndEvent != 0
+	if observedStart {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Created at",
+			Value: value(tb.Link(formatTimestamp(t.Start), t.Start)),
+		})
+	} else {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Created at",
+			Value: value(tb.Link("before trace start", t.Start)),
+		})
+	}
+
+	if observedEnd {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Ended at",
+			Value: value(tb.Link(formatTimestamp(t.End), t.End)),
+		})
+	} else {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Ended at",
+			Value: value(tb.Link("after trace end", t.End)),
+		})
+	}
+
+	d := time.Duration(t.End - t.Start)
+	if observedStart && observedEnd {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Lifetime",
+			Value: value(tb.Span(d.String())),
+		})
+	} else {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Observed duration",
+			Value: value(tb.Span(d.String())),
+		})
+	}
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "# of subtasks",
+		Value: value(tb.Span(local.Sprintf("%d", len(t.Children)))),
+	})
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "# of goroutines",
+		Value: value(tb.Span(local.Sprintf("%d", len(t.Goroutines)))),
+	})
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "# of regions",
+		Value: value(tb.Span(local.Sprintf("%d", t.Regions.Len()))),
+	})
+
+	ti.description.Attributes = attrs
+
+	return ti
+}
+
+func (ti *TaskInfo) Title() string {
+	return taskLabel(ti.task)
+}
+
+func (ti *TaskInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	var tabs []string
+	if len(ti.task.Children) != 0 {
+		tabs = append(tabs, "Subtasks")
+	}
+	tabs = append(tabs, "Goroutines")
+	if ti.task.Regions.Len() != 0 {
+		tabs = append(tabs, "Regions")
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			// Right-aligned buttons should be aligned with the right side of the visible panel, not the width of the
+			// panel contents, nor the infinite width of a possible surrounding list.
+			gtx.Constraints.Max.X = gtx.Constraints.Min.X
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return theme.Button(win.Theme, &ti.buttons.scrollToTask.Clickable, "Scroll to task").Layout(win, gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: 5}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return theme.Button(win.Theme, &ti.buttons.zoomToTask.Clickable, "Zoom to task").Layout(win, gtx)
+				}),
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, ti.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			return ti.description.Layout(win, gtx)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return theme.Tabbed(&ti.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				switch tabs[ti.tabbedState.Current] {
+				case "Subtasks":
+					return ti.subtasks.Layout(win, gtx)
+				case "Goroutines":
+					return ti.goroutineList.Layout(win, gtx, ti.task.Goroutines)
+				case "Regions":
+					return ti.regionList.Layout(win, gtx)
+				default:
+					panic("unreachable")
+				}
+			})
+		}),
+	)
+
+	for _, ev := range ti.subtasks.Clicked() {
+		handleLinkClick(win, ti.mwin, ev)
+	}
+	for _, ev := range ti.goroutineList.Clicked() {
+		handleLinkClick(win, ti.mwin, ev)
+	}
+	for _, ev := range ti.regionList.Clicked() {
+		handleLinkClick(win, ti.mwin, ev)
+	}
+	for _, ev := range ti.description.Events() {
+		handleLinkClick(win, ti.mwin, ev)
+	}
+
+	for ti.buttons.scrollToTask.Clicked() {
+		ti.mwin.OpenLink(&TaskLink{Task: ti.task, Kind: TaskLinkKindScroll})
+	}
+	for ti.buttons.zoomToTask.Clicked() {
+		ti.mwin.OpenLink(&TaskLink{Task: ti.task, Kind: TaskLinkKindZoom})
+	}
+
+	for ti.PanelButtons.Backed() {
+		ti.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+// TasksPanel displays the tree of all tasks in the trace.
+type TasksPanel struct {
+	mwin        *MainWindow
+	description Description
+	tree        TaskTree
+
+	theme.PanelButtons
+}
+
+func NewTasksPanel(mwin *MainWindow) *TasksPanel {
+	var roots []*ptrace.Task
+	for _, t := range mwin.trace.Tasks {
+		if t.Parent == nil {
+			roots = append(roots, t)
+		}
+	}
+
+	tp := &TasksPanel{
+		mwin: mwin,
+		tree: TaskTree{Roots: roots},
+	}
+
+	tb := TextBuilder{Theme: mwin.twin.Theme}
+	tp.description.Attributes = []DescriptionAttribute{
+		{
+			Key:   "# of tasks",
+			Value: theme.Immediate(*tb.Span(local.Sprintf("%d", len(mwin.trace.Tasks)))),
+		},
+		{
+			Key:   "# of top-level tasks",
+			Value: theme.Immediate(*tb.Span(local.Sprintf("%d", len(roots)))),
+		},
+	}
+
+	return tp
+}
+
+func (tp *TasksPanel) Title() string {
+	return "Tasks"
+}
+
+func (tp *TasksPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	// Inset of 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease the minimum constraint,
+	// which we do care about here.
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	defer op.Offset(image.Pt(5, 5)).Push(gtx.Ops).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, tp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			return tp.description.Layout(win, gtx)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return tp.tree.Layout(win, gtx)
+		}),
+	)
+
+	for _, ev := range tp.tree.Clicked() {
+		handleLinkClick(win, tp.mwin, ev)
+	}
+
+	for tp.PanelButtons.Backed() {
+		tp.mwin.prevPanel()
+	}
+
+	return dims
+}
+
+// taskTreeToggle is the object of the links that expand and collapse tasks in a TaskTree.
+type taskTreeToggle struct {
+	task *ptrace.Task
+}
+
+// TaskTree displays a forest of tasks as a table, with rows for subtasks that can be expanded and collapsed.
+type TaskTree struct {
+	Roots []*ptrace.Task
+
+	list     widget.List
+	expanded map[*ptrace.Task]bool
+	rows     []struct {
+		task  *ptrace.Task
+		depth int
+	}
+	dirty bool
+	built bool
+
+	timestampObjects allocator[trace.Timestamp]
+	texts            allocator[Text]
+}
+
+func (tt *TaskTree) buildRows() {
+	tt.rows = tt.rows[:0]
+	var add func(ts []*ptrace.Task, depth int)
+	add = func(ts []*ptrace.Task, depth int) {
+		for _, t := range ts {
+			tt.rows = append(tt.rows, struct {
+				task  *ptrace.Task
+				depth int
+			}{t, depth})
+			if tt.expanded[t] {
+				add(t.Children, depth+1)
+			}
+		}
+	}
+	add(tt.Roots, 0)
+}
+
+func (tt *TaskTree) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.TaskTree.Layout").End()
+
+	if !tt.built || tt.dirty {
+		tt.buildRows()
+		tt.built = true
+		tt.dirty = false
+	}
+
+	tt.list.Axis = layout.Vertical
+	tt.timestampObjects.Reset()
+
+	var txtCnt int
+	cellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
+		defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+		var txt *Text
+		if txtCnt < tt.texts.Len() {
+			txt = tt.texts.Ptr(txtCnt)
+		} else {
+			txt = tt.texts.Allocate(Text{})
+		}
+		txtCnt++
+		txt.Reset(win.Theme)
+
+		r := tt.rows[row]
+		t := r.task
+		switch col {
+		case 0: // Task
+			txt.Span(strings.Repeat("    ", r.depth))
+			if len(t.Children) != 0 {
+				if tt.expanded[t] {
+					txt.Link("▼ ", taskTreeToggle{t})
+				} else {
+					txt.Link("▶ ", taskTreeToggle{t})
+				}
+			} else {
+				txt.Span("   ")
+			}
+			txt.Link(taskLabel(t), t)
+		case 1: // Start time
+			if t.Stub() {
+				txt.Span("before trace start")
+			} else {
+				txt.Link(formatTimestamp(t.Start), tt.timestampObjects.Allocate(t.Start))
+			}
+			txt.Alignment = text.End
+		case 2: // Duration
+			value, unit := durationNumberFormatSITable.format(time.Duration(t.End - t.Start))
+			txt.Span(value)
+			txt.Span(" ")
+			s := txt.Span(unit)
+			s.Font.Variant = "Mono"
+			txt.Alignment = text.End
+		case 3: // Goroutines
+			txt.Span(local.Sprintf("%d", len(t.Goroutines)))
+			txt.Alignment = text.End
+		case 4: // Regions
+			txt.Span(local.Sprintf("%d", t.Regions.Len()))
+			txt.Alignment = text.End
+		}
+
+		dims := txt.Layout(win, gtx)
+		dims.Size = gtx.Constraints.Constrain(dims.Size)
+		return dims
+	}
+
+	var taskTreeColumns = []theme.TableListColumn{
+		{
+			Name: "Task",
+			// XXX the width depends on the font and scaling
+			MinWidth: 400,
+			MaxWidth: 400,
+		},
+
+		{
+			Name: "Start time",
+			// XXX the width depends on the font and scaling
+			MinWidth: 200,
+			MaxWidth: 200,
+		},
+
+		{
+			Name: "Duration",
+			// XXX the width depends on the font and scaling
+			MinWidth: 200,
+			MaxWidth: 200,
+		},
+
+		{
+			Name: "Goroutines",
+			// XXX the width depends on the font and scaling
+			MinWidth: 100,
+			MaxWidth: 100,
+		},
+
+		{
+			Name: "Regions",
+			// XXX the width depends on the font and scaling
+			MinWidth: 100,
+			MaxWidth: 100,
+		},
+	}
+
+	tbl := theme.TableListStyle{
+		Columns:       taskTreeColumns,
+		List:          &tt.list,
+		ColumnPadding: gtx.Dp(10),
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	dims := tbl.Layout(win, gtx, len(tt.rows), cellFn)
+
+	for i := 0; i < tt.texts.Len(); i++ {
+		for _, ev := range tt.texts.Ptr(i).Events() {
+			if toggle, ok := ev.Span.Object.(taskTreeToggle); ok && ev.Event.Type == gesture.TypeClick {
+				if tt.expanded == nil {
+					tt.expanded = map[*ptrace.Task]bool{}
+				}
+				tt.expanded[toggle.task] = !tt.expanded[toggle.task]
+				tt.dirty = true
+				op.InvalidateOp{}.Add(gtx.Ops)
+			}
+		}
+	}
+
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout, excluding the
+// toggles for expanding and collapsing tasks.
+func (tt *TaskTree) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < tt.texts.Len(); i++ {
+		txt := tt.texts.Ptr(i)
+		for _, ev := range txt.Events() {
+			if _, ok := ev.Span.Object.(taskTreeToggle); ok {
+				continue
+			}
+			out = append(out, ev)
+		}
+	}
+	return out
+}
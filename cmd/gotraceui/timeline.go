@@ -53,14 +53,31 @@ type Timeline struct {
 	label             string
 	// Set to true by Timeline.Layout. This is used to track which timelines have been shown during a frame.
 	displayed bool
+	// Whether the user pinned the timeline to the top of the canvas
+	pinned bool
+	// Whether the user hid the timeline
+	hidden bool
+	// Whether the user showed the timeline despite it belonging to a hidden group
+	shown bool
 
 	*TimelineWidget
 }
 
 type TimelineWidget struct {
-	cv          *Canvas
-	labelClick  widget.PrimaryClickable
-	labelClicks int
+	cv           *Canvas
+	labelClick   widget.PrimaryClickable
+	labelClicks  int
+	labelContext gesture.Click
+	labelDrag    gesture.Drag
+
+	// State for dragging the label to reorder timelines
+	reorder struct {
+		active bool
+		start  f32.Point
+		// The vertical distance the label has been dragged by
+		dy   float32
+		done bool
+	}
 
 	hover gesture.Hover
 
@@ -94,10 +111,20 @@ type Track struct {
 	kind   TrackKind
 	spans  ptrace.Spans
 	events []ptrace.EventID
+	// Sorted, non-overlapping ranges of time during which the track's entity couldn't be active, such as processors
+	// beyond GOMAXPROCS.
+	inactive []timeRange
+	// Sorted, non-overlapping ranges of time during which the track's processor was idle while goroutines were
+	// runnable. See idleEpisode.
+	idle []timeRange
 
 	*TrackWidget
 }
 
+type timeRange struct {
+	start, end trace.Timestamp
+}
+
 type MetadataSpans[T any] interface {
 	Metadata() []T
 	MetadataAt(index int) T
@@ -170,6 +197,7 @@ type TrackWidget struct {
 	highlightedPrimaryOutlinesOps   reusableOps
 	highlightedSecondaryOutlinesOps reusableOps
 	eventsOps                       reusableOps
+	logsOps                         reusableOps
 	labelsOps                       reusableOps
 
 	hover gesture.Hover
@@ -226,6 +254,16 @@ func (tw *TimelineWidget) LabelClicked() bool {
 	}
 }
 
+// Reordered reports whether the user finished dragging the timeline's label, and the vertical distance they dragged it
+// by.
+func (tw *TimelineWidget) Reordered() (float32, bool) {
+	if !tw.reorder.done {
+		return 0, false
+	}
+	tw.reorder.done = false
+	return tw.reorder.dy, true
+}
+
 func (tl *Timeline) Height(gtx layout.Context, cv *Canvas) int {
 	timelineGap := gtx.Dp(timelineGapDp)
 	enabledTracks := 0
@@ -290,19 +328,47 @@ func (tl *Timeline) Layout(win *theme.Window, gtx layout.Context, cv *Canvas, fo
 			paint.FillShape(gtx.Ops, colors[colorTimelineBorder], clip.Rect{Max: image.Pt(gtx.Constraints.Max.X, gtx.Dp(1))}.Op())
 		}
 
-		if tl.Hovered() || forceLabel {
+		// Keep laying out the label while it's being dragged, or we'd stop receiving the drag's events.
+		if tl.Hovered() || forceLabel || tl.reorder.active {
 			tl.labelClick.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
 				labelGtx := gtx
 				labelGtx.Constraints.Min = image.Point{}
 				labelDims := widget.TextLine{Color: colors[colorTimelineLabel]}.Layout(labelGtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, tl.label)
 				stack := clip.Rect{Max: labelDims.Size}.Push(gtx.Ops)
-				pointer.CursorPointer.Add(gtx.Ops)
+				if tl.reorder.active {
+					pointer.CursorGrabbing.Add(gtx.Ops)
+				} else {
+					pointer.CursorPointer.Add(gtx.Ops)
+				}
+				tl.labelContext.Add(gtx.Ops)
+				tl.labelDrag.Add(gtx.Ops)
 				stack.Pop()
 
 				return labelDims
 			})
 		}
 
+		for _, ev := range tl.labelContext.Events(gtx.Queue) {
+			if ev.Type == gesture.TypePress && ev.Button == pointer.ButtonSecondary {
+				win.SetContextMenu(cv.timelineContextMenu(win, tl))
+			}
+		}
+		for _, ev := range tl.labelDrag.Events(gtx.Metric, gtx.Queue, gesture.Vertical) {
+			switch ev.Type {
+			case pointer.Press:
+				tl.reorder.start = ev.Position
+				tl.reorder.dy = 0
+			case pointer.Drag:
+				tl.reorder.active = true
+				tl.reorder.dy = ev.Position.Y - tl.reorder.start.Y
+			case pointer.Release:
+				tl.reorder.done = tl.reorder.active
+				tl.reorder.active = false
+			case pointer.Cancel:
+				tl.reorder.active = false
+			}
+		}
+
 		if tl.widgetTooltip != nil && tl.cv.timeline.showTooltips == showTooltipsBoth && tl.labelClick.Hovered() {
 			win.SetTooltip(func(win *theme.Window, gtx layout.Context) layout.Dimensions {
 				// OPT(dh): this allocates for the closure
@@ -366,15 +432,15 @@ func (tl *Timeline) Layout(win *theme.Window, gtx layout.Context, cv *Canvas, fo
 
 func defaultSpanColor(spans ptrace.Spans) [2]colorIndex {
 	if spans.Len() == 1 {
-		return [2]colorIndex{stateColors[spans.At(0).State], 0}
+		return [2]colorIndex{stateColor(spans.At(0).State), 0}
 	} else {
 		// OPT(dh): this would benefit from iterators, for span selectors backed by data that isn't already made of
 		// ptrace.Span
 		spans := spans
-		c := stateColors[spans.At(0).State]
+		c := stateColor(spans.At(0).State)
 		for i := 1; i < spans.Len(); i++ {
 			s := spans.At(i)
-			cc := stateColors[s.State]
+			cc := stateColor(s.State)
 			if cc != c {
 				return [2]colorIndex{colorStateMerged, 0}
 			}
@@ -583,11 +649,14 @@ func (track *Track) Layout(win *theme.Window, gtx layout.Context, tl *Timeline,
 				track.clickedSpans = dspSpans
 			}
 			if trackContextMenuSpans {
+				var items []*theme.MenuItem
 				if track.spanContextMenu != nil {
-					win.SetContextMenu(track.spanContextMenu(dspSpans, cv))
+					items = track.spanContextMenu(dspSpans, cv)
 				} else {
-					win.SetContextMenu([]*theme.MenuItem{newZoomMenuItem(cv, dspSpans)})
+					items = []*theme.MenuItem{newZoomMenuItem(cv, dspSpans)}
 				}
+				items = append(items, newBookmarkMenuItems(win, cv, dspSpans.At(0).Start, LastSpan(dspSpans).End)...)
+				win.SetContextMenu(items)
 			}
 		}
 
@@ -607,7 +676,7 @@ func (track *Track) Layout(win *theme.Window, gtx layout.Context, tl *Timeline,
 		minP = f32.Pt((max(startPx, 0)), 0)
 		maxP = f32.Pt((min(endPx, float32(gtx.Constraints.Max.X))), float32(trackHeight))
 
-		highlighted := filter.Match(dspSpans, SpanContainer{Timeline: tl, Track: track}) || automaticFilter.Match(dspSpans, SpanContainer{Timeline: tl, Track: track})
+		highlighted := filter.Match(tr.Trace, dspSpans, SpanContainer{Timeline: tl, Track: track}) || automaticFilter.Match(tr.Trace, dspSpans, SpanContainer{Timeline: tl, Track: track})
 		if hovered {
 			highlightedPrimaryOutlinesPath.MoveTo(minP)
 			highlightedPrimaryOutlinesPath.LineTo(f32.Point{X: maxP.X, Y: minP.Y})
@@ -859,6 +928,24 @@ func (track *Track) Layout(win *theme.Window, gtx layout.Context, tl *Timeline,
 				}
 			}
 		}
+		fillRanges := func(rs []timeRange, c colorIndex) {
+			idx := sort.Search(len(rs), func(i int) bool {
+				return rs[i].end >= cv.start
+			})
+			for _, r := range rs[idx:] {
+				if r.start > cv.End() {
+					break
+				}
+				minX := max(cv.tsToPx(r.start), 0)
+				maxX := min(cv.tsToPx(r.end), visWidthPx)
+				paint.FillShape(gtx.Ops, colors[c], clip.FRect{Min: f32.Pt(minX, 0), Max: f32.Pt(maxX, float32(trackHeight))}.Op(gtx.Ops))
+			}
+		}
+		// Indicate parts of time where the timeline was forcibly inactive.
+		fillRanges(track.inactive, colorTimelineInactive)
+		// Indicate parts of time where the timeline was idle even though goroutines were waiting to run.
+		fillRanges(track.idle, colorTimelineIdle)
+
 		mid := float32(trackHeight) / 2
 		top := mid - 2
 		bottom := mid + 2
@@ -899,6 +986,43 @@ func (track *Track) Layout(win *theme.Window, gtx layout.Context, tl *Timeline,
 	}
 	paint.FillShape(gtx.Ops, rgba(0x000000DD), clip.Outline{Path: eventsPath.End()}.Op())
 
+	if cv.timeline.displayLogMarkers && track.kind == TrackKindUnspecified && len(track.events) > 0 {
+		// Draw a small triangle at the top of the track for every user log. Unlike event dots, these don't depend on
+		// spans being unmerged, so that logs remain visible at all zoom levels.
+		var logsPath clip.Path
+		logsPath.Begin(track.logsOps.get())
+
+		markerRadius := float32(gtx.Dp(3))
+		markerHeight := float32(gtx.Dp(5))
+		start := cv.start - trace.Timestamp(float64(markerRadius)*cv.nsPerPx)
+		end := cv.End() + trace.Timestamp(float64(markerRadius)*cv.nsPerPx)
+		first := sort.Search(len(track.events), func(i int) bool {
+			return tr.Event(track.events[i]).Ts >= start
+		})
+		prevPx := float32(math.Inf(-1))
+		for _, evID := range track.events[first:] {
+			ev := tr.Event(evID)
+			if ev.Ts > end {
+				break
+			}
+			if ev.Type != trace.EvUserLog {
+				continue
+			}
+			px := cv.tsToPx(ev.Ts)
+			if px-prevPx < 1 {
+				// Don't draw several markers on top of each other
+				continue
+			}
+			prevPx = px
+
+			logsPath.MoveTo(f32.Pt(px-markerRadius, 0))
+			logsPath.LineTo(f32.Pt(px+markerRadius, 0))
+			logsPath.LineTo(f32.Pt(px, markerHeight))
+			logsPath.Close()
+		}
+		paint.FillShape(gtx.Ops, colors[colorUserLogMarker], clip.Outline{Path: logsPath.End()}.Op())
+	}
+
 	// Finally print labels on top
 	labelsMacro.Stop().Add(gtx.Ops)
 
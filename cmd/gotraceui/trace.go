@@ -32,6 +32,8 @@ type Trace struct {
 	allProcessorSpanLabels   [][]string
 	allGoroutineFilterLabels [][]string
 	allProcessorFilterLabels [][]string
+
+	idleEpisodes *idleEpisodes
 }
 
 func (t *Trace) goroutineSpanLabels(g *ptrace.Goroutine) []string {
@@ -27,11 +27,15 @@ type Filter interface {
 }
 
 type ListWindow struct {
-	BuildFilter func(string) Filter
+	// BuildFilter parses the query entered by the user. If it returns an error, the error is displayed below the input
+	// and the previous results remain visible.
+	BuildFilter func(string) (Filter, error)
 
 	items []ListWindowItem
 
 	filtered []int
+	// The error returned by BuildFilter for the current input, if any
+	err error
 	// index of the selected item in the filtered list
 	index     int
 	done      bool
@@ -116,7 +120,16 @@ func (w *ListWindow) Layout(gtx layout.Context) layout.Dimensions {
 		}
 		editor := Editor(w.theme, &w.input, "")
 		editor.Editor.Focus()
-		return flex.Layout(gtx, layout.Rigid(editor.Layout), layout.Flexed(1, fn2))
+		if w.err != nil {
+			editor.Color = rgba(0xFF0000FF)
+		}
+		errFn := func(gtx layout.Context) layout.Dimensions {
+			if w.err == nil {
+				return layout.Dimensions{}
+			}
+			return widget.TextLine{Color: rgba(0xFF0000FF)}.Layout(gtx, w.theme.Shaper, font.Font{}, w.theme.TextSize, w.err.Error())
+		}
+		return flex.Layout(gtx, layout.Rigid(editor.Layout), layout.Rigid(errFn), layout.Flexed(1, fn2))
 	}()
 
 	// The editor widget selectively handles the up and down arrow keys, depending on the contents of the text field and
@@ -179,8 +192,13 @@ func (w *ListWindow) Layout(gtx layout.Context) layout.Dimensions {
 	for _, ev := range w.input.Events() {
 		switch ev.(type) {
 		case widget.ChangeEvent:
+			f, err := w.BuildFilter(w.input.Text())
+			w.err = err
+			if err != nil {
+				// Keep showing the previous results while the user is still typing.
+				continue
+			}
 			w.filtered = w.filtered[:0]
-			f := w.BuildFilter(w.input.Text())
 			for _, item := range w.items {
 				if f.Filter(item) {
 					w.filtered = append(w.filtered, item.index)
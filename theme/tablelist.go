@@ -4,7 +4,9 @@ import (
 	"honnef.co/go/gotraceui/widget"
 
 	"gioui.org/font"
+	"gioui.org/io/pointer"
 	"gioui.org/layout"
+	"gioui.org/op/clip"
 )
 
 type TableListColumn struct {
@@ -17,6 +19,9 @@ type TableListStyle struct {
 	Columns       []TableListColumn
 	List          *widget.List
 	ColumnPadding int
+	// If non-nil, ColumnClicks must have one element per column and makes the column headers clickable, e.g. for
+	// sorting by a column.
+	ColumnClicks []widget.PrimaryClickable
 }
 
 func (tbl *TableListStyle) Layout(
@@ -30,8 +35,19 @@ func (tbl *TableListStyle) Layout(
 
 	ourCellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
 		if row == 0 {
-			return widget.TextLine{Color: win.Theme.Palette.Foreground}.
-				Layout(gtx, win.Theme.Shaper, font.Font{Weight: font.Bold}, win.Theme.TextSize, tbl.Columns[col].Name)
+			header := func(gtx layout.Context) layout.Dimensions {
+				return widget.TextLine{Color: win.Theme.Palette.Foreground}.
+					Layout(gtx, win.Theme.Shaper, font.Font{Weight: font.Bold}, win.Theme.TextSize, tbl.Columns[col].Name)
+			}
+			if tbl.ColumnClicks == nil {
+				return header(gtx)
+			}
+			return tbl.ColumnClicks[col].Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				dims := header(gtx)
+				defer clip.Rect{Max: dims.Size}.Push(gtx.Ops).Pop()
+				pointer.CursorPointer.Add(gtx.Ops)
+				return dims
+			})
 		} else {
 			return cellFn(gtx, row-1, col)
 		}
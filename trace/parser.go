@@ -1532,19 +1532,26 @@ func (p *Parser) allocateStack(size uint64) []uint64 {
 }
 
 const (
-	ArgGCSweepDoneReclaimed = 1
-	ArgGCSweepDoneSwept     = 0
-	ArgGoCreateG            = 0
-	ArgGoCreateStack        = 1
-	ArgGoStartLabelLabelID  = 2
-	ArgGoUnblockG           = 0
-	ArgUserLogKeyID         = 1
-	ArgUserLogMessage       = 3
-	ArgUserRegionMode       = 1
-	ArgUserRegionTaskID     = 0
-	ArgUserRegionTypeID     = 2
-	ArgUserTaskCreateTaskID = 0
-	ArgUserTaskCreateTypeID = 2
-	ArgHeapAllocMem         = 0
-	ArgHeapGoalMem          = 0
+	ArgGCSTWStartKind         = 0
+	ArgGCStartSeq             = 0
+	ArgGCSweepDoneReclaimed   = 1
+	ArgGCSweepDoneSwept       = 0
+	ArgGoCreateG              = 0
+	ArgGoCreateStack          = 1
+	ArgGoStartLabelLabelID    = 2
+	ArgGoUnblockG             = 0
+	ArgGomaxprocsProcs        = 0
+	ArgProcStartThread        = 0
+	ArgUserLogKeyID           = 1
+	ArgUserLogMessage         = 3
+	ArgUserLogTaskID          = 0
+	ArgUserRegionMode         = 1
+	ArgUserRegionTaskID       = 0
+	ArgUserRegionTypeID       = 2
+	ArgUserTaskCreateParentID = 1
+	ArgUserTaskCreateTaskID   = 0
+	ArgUserTaskCreateTypeID   = 2
+	ArgUserTaskEndTaskID      = 0
+	ArgHeapAllocMem           = 0
+	ArgHeapGoalMem            = 0
 )
@@ -0,0 +1,185 @@
+package ptrace
+
+import (
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+func TestPopulateMachines(t *testing.T) {
+	procStart := func(ts trace.Timestamp, p int32, m uint64) trace.Event {
+		ev := trace.Event{Ts: ts, Type: trace.EvProcStart, P: p}
+		ev.Args[trace.ArgProcStartThread] = m
+		return ev
+	}
+	ev := func(ts trace.Timestamp, typ byte, p int32, g uint64) trace.Event {
+		return trace.Event{Ts: ts, Type: typ, P: p, G: g}
+	}
+
+	type machine struct {
+		spans      []Span
+		goroutines []Span
+	}
+	for _, test := range []struct {
+		name   string
+		events []trace.Event
+		want   map[int32]machine
+	}{
+		{
+			name: "running",
+			events: []trace.Event{
+				0: procStart(10, 0, 1),
+				1: ev(20, trace.EvGoStart, 0, 1),
+				2: ev(30, trace.EvGoBlockRecv, 0, 1),
+				3: ev(35, trace.EvGoStart, 0, 2),
+				4: ev(40, trace.EvProcStop, 0, 2),
+				5: ev(100, trace.EvGoUnblock, 1, 3),
+			},
+			want: map[int32]machine{
+				1: {
+					spans: []Span{{Start: 10, End: 40, State: StateRunningP, Event: 0}},
+					goroutines: []Span{
+						{Start: 20, End: 30, State: StateRunningG, Event: 1},
+						{Start: 35, End: 40, State: StateRunningG, Event: 3},
+					},
+				},
+			},
+		},
+		{
+			// The goroutine blocks in a syscall, and its M hands off the P to another M. Once the syscall returns,
+			// the goroutine continues on its original M, which acquires a different P.
+			name: "blocking syscall",
+			events: []trace.Event{
+				0:  procStart(10, 0, 1),
+				1:  ev(20, trace.EvGoStart, 0, 1),
+				2:  ev(25, trace.EvGoSysCall, 0, 1),
+				3:  ev(30, trace.EvGoSysBlock, 0, 1),
+				4:  ev(30, trace.EvProcStop, 0, 0),
+				5:  procStart(35, 0, 2),
+				6:  ev(50, trace.EvGoSysExit, trace.SyscallP, 1),
+				7:  procStart(55, 1, 1),
+				8:  ev(56, trace.EvGoStart, 1, 1),
+				9:  ev(90, trace.EvProcStop, 1, 0),
+				10: ev(100, trace.EvProcStop, 0, 0),
+			},
+			want: map[int32]machine{
+				1: {
+					spans: []Span{
+						{Start: 10, End: 30, State: StateRunningP, Event: 0},
+						{Start: 30, End: 50, State: StateBlockedSyscall, Event: 2},
+						{Start: 55, End: 90, State: StateRunningP, Event: 7},
+					},
+					goroutines: []Span{
+						{Start: 20, End: 30, State: StateRunningG, Event: 1},
+						{Start: 56, End: 90, State: StateRunningG, Event: 8},
+					},
+				},
+				2: {
+					spans: []Span{{Start: 35, End: 100, State: StateRunningP, Event: 5}},
+				},
+			},
+		},
+		{
+			// sysmon retakes the P of a goroutine that has been in a syscall for a while. The pair of EvGoSysBlock and
+			// EvProcStop is emitted by sysmon, long after the syscall started. The goroutine returns from the syscall
+			// on its original M and reacquires a P without the M having been handed one in between.
+			name: "sysmon retake",
+			events: []trace.Event{
+				0: procStart(10, 0, 1),
+				1: ev(20, trace.EvGoStart, 0, 1),
+				2: ev(25, trace.EvGoSysCall, 0, 1),
+				3: ev(60, trace.EvGoSysBlock, 0, 1),
+				4: ev(60, trace.EvProcStop, 0, 0),
+				5: ev(70, trace.EvGoSysExit, trace.SyscallP, 1),
+				6: procStart(75, 0, 1),
+				7: ev(76, trace.EvGoStart, 0, 1),
+				8: ev(100, trace.EvGoEnd, 0, 1),
+			},
+			want: map[int32]machine{
+				1: {
+					spans: []Span{
+						{Start: 10, End: 60, State: StateRunningP, Event: 0},
+						{Start: 60, End: 70, State: StateBlockedSyscall, Event: 2},
+						{Start: 75, End: 100, State: StateRunningP, Event: 6},
+					},
+					goroutines: []Span{
+						{Start: 20, End: 60, State: StateRunningG, Event: 1},
+						{Start: 76, End: 100, State: StateRunningG, Event: 7},
+					},
+				},
+			},
+		},
+		{
+			// The syscall returns before we see the M acquire a new P, so the goroutine never shows up as blocked.
+			name: "syscall without handoff",
+			events: []trace.Event{
+				0: procStart(10, 0, 1),
+				1: ev(20, trace.EvGoStart, 0, 1),
+				2: ev(25, trace.EvGoSysCall, 0, 1),
+				3: ev(26, trace.EvGoSysCall, 0, 1),
+				4: ev(40, trace.EvGoEnd, 0, 1),
+				5: ev(50, trace.EvProcStop, 0, 0),
+			},
+			want: map[int32]machine{
+				1: {
+					spans:      []Span{{Start: 10, End: 50, State: StateRunningP, Event: 0}},
+					goroutines: []Span{{Start: 20, End: 40, State: StateRunningG, Event: 1}},
+				},
+			},
+		},
+		{
+			// The M starts running a new P before we see its old P stop. The late EvProcStop of the old P must not
+			// end the new P's span, nor be attributed to the M that picked up the old P.
+			name: "new P before old P stops",
+			events: []trace.Event{
+				0: procStart(10, 0, 1),
+				1: procStart(20, 1, 1),
+				2: ev(25, trace.EvProcStop, 0, 0),
+				3: ev(30, trace.EvGoStart, 1, 1),
+				4: ev(40, trace.EvGoSched, 1, 1),
+				5: ev(100, trace.EvProcStop, 1, 0),
+			},
+			want: map[int32]machine{
+				1: {
+					spans: []Span{
+						{Start: 10, End: 20, State: StateRunningP, Event: 0},
+						{Start: 20, End: 100, State: StateRunningP, Event: 1},
+					},
+					goroutines: []Span{{Start: 30, End: 40, State: StateRunningG, Event: 3}},
+				},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tr := &Trace{
+				Trace:  trace.Trace{Events: test.events},
+				msByID: map[int32]*Machine{},
+			}
+			populateMachines(tr)
+
+			if len(tr.msByID) != len(test.want) {
+				t.Errorf("got %d machines, want %d", len(tr.msByID), len(test.want))
+			}
+			for mid, want := range test.want {
+				m, ok := tr.msByID[mid]
+				if !ok {
+					t.Errorf("missing machine %d", mid)
+					continue
+				}
+				checkSpans := func(kind string, got Spans, want []Span) {
+					if got.Len() != len(want) {
+						t.Errorf("M%d: got %d %s spans, want %d", mid, got.Len(), kind, len(want))
+						return
+					}
+					for i, w := range want {
+						if g := got.At(i); g != w {
+							t.Errorf("M%d: %s span %d: got %+v, want %+v", mid, kind, i, g, w)
+						}
+					}
+				}
+				checkSpans("machine", m.Spans, want.spans)
+				checkSpans("goroutine", m.Goroutines, want.goroutines)
+			}
+		})
+	}
+}
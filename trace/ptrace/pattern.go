@@ -2,7 +2,7 @@ package ptrace
 
 import "honnef.co/go/gotraceui/trace"
 
-type SpanTags uint8
+type SpanTags uint16
 
 const (
 	SpanTagNetwork SpanTags = 1 << iota
@@ -15,6 +15,9 @@ const (
 
 	// Used for spans of GC goroutines, used when choosing span colors for processor timelines.
 	SpanTagGC
+
+	// The first of MaxUserTags tags that are defined by user patterns.
+	SpanTagUser0
 )
 
 type pattern struct {
@@ -213,11 +216,20 @@ var patterns = [256][]pattern{
 	},
 }
 
-func applyPatterns(s Span, pcs map[uint64]trace.Frame, stack []uint64) Span {
+func applyPatterns(s Span, up *UserPatterns, pcs map[uint64]trace.Frame, stack []uint64) Span {
+	s = applyPatternList(s, patterns[s.State], pcs, stack)
+	if up != nil {
+		// User patterns apply to the state we ended up with, so that they can refine the states of built-in patterns.
+		s = applyPatternList(s, up.patterns[s.State], pcs, stack)
+	}
+	return s
+}
+
+func applyPatternList(s Span, patterns []pattern, pcs map[uint64]trace.Frame, stack []uint64) Span {
 	// OPT(dh): be better than O(n)
 
 patternLoop:
-	for _, p := range patterns[s.State] {
+	for _, p := range patterns {
 		if len(stack) < len(p.fns) {
 			continue
 		}
@@ -2,7 +2,6 @@
 package ptrace
 
 import (
-	"errors"
 	"fmt"
 	"runtime"
 	"sort"
@@ -14,11 +13,6 @@ import (
 	"honnef.co/go/gotraceui/trace"
 )
 
-// This boolean guards all code involving displaying machine timelines. That feature is currently broken, because the
-// trace parser only produces an event ordering that is consistent for goroutines, but not for machines. For example, it
-// may try to start a P on an M that is currently blocked on a syscall.
-const supportMachineTimelines = false
-
 type SchedulingState uint8
 
 const (
@@ -48,10 +42,11 @@ const (
 	StateGCMarkAssist
 	StateGCSweep
 
-	// Special states used by user regions and stack frames
+	// Special states used by user regions, tasks and stack frames
 	StateUserRegion
 	StateStack
 	StateCPUSample
+	StateTask
 
 	// Processor states
 	StateRunningG
@@ -59,7 +54,11 @@ const (
 	// Machine states
 	StateRunningP
 
-	StateLast
+	// The first of MaxUserStates goroutine states that are defined by user patterns. Each of them refines a built-in
+	// state, see Trace.BaseState.
+	StateUser0
+
+	StateLast = StateUser0 + MaxUserStates
 )
 
 type Point struct {
@@ -75,11 +74,18 @@ type Trace struct {
 	Functions  map[string]*Function
 	GC         Spans
 	STW        Spans
+	// One cycle per span in GC
+	GCCycles   []*GCCycle
 	Tasks      []*Task
 	HeapSize   []Point
 	HeapGoal   []Point
+	Gomaxprocs []Point
 	// Mapping from Goroutine ID to list of CPU sample events
 	CPUSamples map[uint64][]EventID
+	// All user log events, in chronological order
+	UserLogs []EventID
+	// The user-defined patterns that were applied to the trace, or nil
+	UserPatterns *UserPatterns
 
 	gsByID map[uint64]*Goroutine
 	// psByID and msById will be unset after parsing finishes
@@ -92,37 +98,47 @@ type Trace struct {
 
 type Statistics [StateLast]Statistic
 
-func (stat *Statistics) Blocked() time.Duration {
-	return stat[StateBlocked].Total +
-		stat[StateBlockedSend].Total +
-		stat[StateBlockedRecv].Total +
-		stat[StateBlockedSelect].Total +
-		stat[StateBlockedSync].Total +
-		stat[StateBlockedSyncOnce].Total +
-		stat[StateBlockedSyncTriggeringGC].Total +
-		stat[StateBlockedCond].Total +
-		stat[StateBlockedNet].Total +
-		stat[StateBlockedGC].Total +
-		stat[StateBlockedSyscall].Total +
-		stat[StateStuck].Total
+// sum returns the total time spent in states for which fn returns true. User-defined states are attributed to the
+// states they refine in tr.
+func (stat *Statistics) sum(tr *Trace, fn func(state SchedulingState) bool) time.Duration {
+	var d time.Duration
+	for state := range stat {
+		if fn(tr.BaseState(SchedulingState(state))) {
+			d += stat[state].Total
+		}
+	}
+	return d
 }
 
-func (stat *Statistics) Running() time.Duration {
-	return stat[StateActive].Total +
-		stat[StateGCDedicated].Total +
-		stat[StateGCIdle].Total
+func (stat *Statistics) Blocked(tr *Trace) time.Duration {
+	return stat.sum(tr, func(state SchedulingState) bool {
+		switch state {
+		case StateBlocked, StateBlockedSend, StateBlockedRecv, StateBlockedSelect, StateBlockedSync,
+			StateBlockedSyncOnce, StateBlockedSyncTriggeringGC, StateBlockedCond, StateBlockedNet, StateBlockedGC,
+			StateBlockedSyscall, StateStuck:
+			return true
+		default:
+			return false
+		}
+	})
 }
 
-func (stat *Statistics) Inactive() time.Duration {
-	return stat[StateInactive].Total +
-		stat[StateReady].Total +
-		stat[StateCreated].Total
+func (stat *Statistics) Running(tr *Trace) time.Duration {
+	return stat.sum(tr, func(state SchedulingState) bool {
+		return state == StateActive || state == StateGCDedicated || state == StateGCIdle
+	})
+}
 
+func (stat *Statistics) Inactive(tr *Trace) time.Duration {
+	return stat.sum(tr, func(state SchedulingState) bool {
+		return state == StateInactive || state == StateReady || state == StateCreated
+	})
 }
 
-func (stat *Statistics) GCAssist() time.Duration {
-	return stat[StateGCMarkAssist].Total +
-		stat[StateGCSweep].Total
+func (stat *Statistics) GCAssist(tr *Trace) time.Duration {
+	return stat.sum(tr, func(state SchedulingState) bool {
+		return state == StateGCMarkAssist || state == StateGCSweep
+	})
 }
 
 type Statistic struct {
@@ -150,6 +166,10 @@ type Goroutine struct {
 	Spans       Spans
 	UserRegions []Spans
 	Events      []EventID
+	// The goroutine that created this goroutine, or nil if it was created before the trace started
+	Parent *Goroutine
+	// Goroutines created by this goroutine, in order of creation
+	Children []*Goroutine
 
 	// Most goroutines are small enough that we can compute statistics on demand. For the rest, we compute them when
 	// parsing the trace and cache them here.
@@ -190,12 +210,80 @@ type Task struct {
 	SeqID int
 	Name  string
 	Event EventID
+	// The EvUserTaskEnd event, or 0 if the task didn't end before tracing stopped.
+	EndEvent EventID
+	// Start is 0 for tasks that were created before tracing began. End is the time of the trace's last event for tasks
+	// that hadn't ended yet.
+	Start trace.Timestamp
+	End   trace.Timestamp
+
+	// Parent is nil for tasks without a parent.
+	Parent   *Task
+	Children []*Task
+	// Goroutines that created the task, ended it, logged in it, or had user regions in it, sorted by ID.
+	Goroutines []*Goroutine
+	// All user regions belonging to the task, across all goroutines, sorted by start time.
+	Regions Spans
 }
 
 func (t *Task) Stub() bool {
 	return t.Event == 0
 }
 
+// STWKind describes the phase of the GC that stopped the world.
+type STWKind uint8
+
+const (
+	STWKindMarkTermination STWKind = iota
+	STWKindSweepTermination
+)
+
+func (k STWKind) String() string {
+	switch k {
+	case STWKindMarkTermination:
+		return "mark termination"
+	case STWKindSweepTermination:
+		return "sweep termination"
+	default:
+		return fmt.Sprintf("STWKind(%d)", k)
+	}
+}
+
+type STWPhase struct {
+	Span Span
+	Kind STWKind
+}
+
+type GCCycle struct {
+	// The sequence number of the cycle, counting all cycles since the process started, not just since tracing began.
+	Seq uint64
+	// The cycle's span in Trace.GC. End is the time of the trace's last event for cycles that hadn't ended yet.
+	Span Span
+	// The STW phases that are part of the cycle. The mark termination phase can end after the cycle itself.
+	STW []STWPhase
+	// Time spent in mark assist during the cycle, per goroutine.
+	MarkAssist      map[*Goroutine]time.Duration
+	MarkAssistTotal time.Duration
+	// Bytes swept and reclaimed while sweeping the cycle's spans, which happens after the cycle has ended and until the
+	// next cycle starts.
+	Swept     uint64
+	Reclaimed uint64
+	// Heap size and goal at the start and end of the cycle. Zero if the trace has no measurement for that point in time.
+	HeapSizeBefore uint64
+	HeapSizeAfter  uint64
+	HeapGoalBefore uint64
+	HeapGoalAfter  uint64
+}
+
+// STWDuration returns the total amount of time the cycle stopped the world.
+func (c *GCCycle) STWDuration() time.Duration {
+	var d time.Duration
+	for i := range c.STW {
+		d += c.STW[i].Span.Duration()
+	}
+	return d
+}
+
 type Span struct {
 	// The Span type is carefully laid out to optimize its size and to avoid pointers, the latter so that the garbage
 	// collector won't have to scan any memory of our millions of events.
@@ -291,16 +379,18 @@ func (g *Goroutine) Statistics() Statistics {
 	return ComputeStatistics(g.Spans)
 }
 
-func Parse(res trace.Trace, progress func(float64)) (*Trace, error) {
+// Parse processes a trace. up are user-defined patterns to apply in addition to the built-in ones, and may be nil.
+func Parse(res trace.Trace, up *UserPatterns, progress func(float64)) (*Trace, error) {
 	tr := &Trace{
-		Trace:      res,
-		Functions:  map[string]*Function{},
-		gsByID:     map[uint64]*Goroutine{},
-		psByID:     map[int32]*Processor{},
-		msByID:     map[int32]*Machine{},
-		CPUSamples: map[uint64][]EventID{},
-		GC:         make(spansSlice, 0),
-		STW:        make(spansSlice, 0),
+		Trace:        res,
+		UserPatterns: up,
+		Functions:    map[string]*Function{},
+		gsByID:       map[uint64]*Goroutine{},
+		psByID:       map[int32]*Processor{},
+		msByID:       map[int32]*Machine{},
+		CPUSamples:   map[uint64][]EventID{},
+		GC:           make(spansSlice, 0),
+		STW:          make(spansSlice, 0),
 	}
 
 	makeProgresser := func(stage int, numStages int) func(float64) {
@@ -315,10 +405,14 @@ func Parse(res trace.Trace, progress func(float64)) (*Trace, error) {
 	if err := processEvents(res, tr, makeProgresser(1, 5)); err != nil {
 		return nil, err
 	}
+	populateMachines(tr)
 
 	populateObjects(tr, makeProgresser(2, 5))
 	postProcessSpans(tr, makeProgresser(3, 5))
 	removeBogusCreatedSpans(tr)
+	populateCreationTree(tr)
+	populateTasks(tr)
+	populateGCCycles(tr)
 	computeGoroutineStatistics(tr.Goroutines, makeProgresser(5, 5))
 
 	tr.psByID = nil
@@ -360,27 +454,23 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 		tr.psByID[pid] = p
 		return p
 	}
-	getM := func(mid int32) *Machine {
-		if !supportMachineTimelines {
-			panic("getM was called despite supportmachineActivities == false")
-		}
-		m, ok := tr.msByID[mid]
+
+	getTask := func(id uint64) *Task {
+		idx, ok := tr.task(id)
 		if ok {
-			return m
+			return tr.Tasks[idx]
 		}
-		m = &Machine{ID: mid}
-		tr.msByID[mid] = m
-		return m
+		// The task with the given ID doesn't exist yet. Either this is the task's creation, or the task was created
+		// before tracing began, which can happen in well-formed traces. In the latter case, the task remains a stub.
+		t := &Task{ID: id, End: -1}
+		tr.Tasks = slices.Insert(tr.Tasks, idx, t)
+		return t
 	}
 
 	// map from gid to stack ID
 	lastSyscall := map[uint64]uint32{}
-	// map from P to last M it ran on
-	lastMPerP := map[int32]int32{}
 	// set of gids currently in mark assist
 	inMarkAssist := map[uint64]struct{}{}
-	blockingSyscallPerP := map[int32]EventID{}
-	blockingSyscallMPerG := map[uint64]int32{}
 
 	// FIXME(dh): rename function. or remove it outright
 	addEventToCurrentSpan := func(gid uint64, ev EventID) {
@@ -395,7 +485,6 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 	// Count the number of events per goroutine to get an estimate of spans per goroutine, to preallocate slices.
 	eventsPerG := map[uint64]int{}
 	eventsPerP := map[int32]int{}
-	eventsPerM := map[int32]int{}
 	for evID := range res.Events {
 		ev := &res.Events[evID]
 		var gid uint64
@@ -405,11 +494,6 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 		case trace.EvGoStart, trace.EvGoStartLabel:
 			eventsPerP[ev.P]++
 			gid = ev.G
-		case trace.EvProcStart:
-			if supportMachineTimelines {
-				eventsPerM[int32(ev.Args[0])]++
-			}
-			continue
 		case trace.EvHeapAlloc:
 			tr.HeapSize = append(tr.HeapSize, Point{
 				ev.Ts,
@@ -420,10 +504,16 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 				ev.Ts,
 				ev.Args[trace.ArgHeapGoalMem],
 			})
+		case trace.EvGomaxprocs:
+			tr.Gomaxprocs = append(tr.Gomaxprocs, Point{
+				ev.Ts,
+				ev.Args[trace.ArgGomaxprocsProcs],
+			})
+			continue
 		case trace.EvGCStart, trace.EvGCSTWStart, trace.EvGCDone, trace.EvGCSTWDone,
-			trace.EvGomaxprocs, trace.EvUserTaskCreate,
+			trace.EvUserTaskCreate,
 			trace.EvUserTaskEnd, trace.EvUserRegion, trace.EvUserLog, trace.EvCPUSample,
-			trace.EvProcStop, trace.EvGoSysCall:
+			trace.EvProcStart, trace.EvProcStop, trace.EvGoSysCall:
 			continue
 		default:
 			gid = ev.G
@@ -436,11 +526,6 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 	for pid, n := range eventsPerP {
 		getP(pid).Spans = spansSlice(make([]Span, 0, n))
 	}
-	if supportMachineTimelines {
-		for mid, n := range eventsPerM {
-			getM(mid).Spans = spansSlice(make([]Span, 0, n))
-		}
-	}
 
 	userRegionDepths := map[uint64]int{}
 	for evID := range res.Events {
@@ -583,11 +668,6 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 			pState = pStopG
 			state = StateBlockedSyscall
 
-			// EvGoSysblock will be followed by ProcStop. Leave a note for ProcStop to start a new span for the blocking
-			// syscall. Also record enough data for EvGoSysExit to finish that span.
-			blockingSyscallPerP[ev.P] = EventID(evID)
-			blockingSyscallMPerG[ev.G] = lastMPerP[ev.P]
-
 		case trace.EvGoInSyscall:
 			gid = ev.G
 			state = StateBlockedSyscall
@@ -595,52 +675,8 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 			gid = ev.G
 			state = StateReady
 
-			if supportMachineTimelines {
-				if mid, ok := blockingSyscallMPerG[ev.G]; ok {
-					delete(blockingSyscallMPerG, ev.G)
-					m := getM(mid)
-					span := m.Spans.AtPtr(m.Spans.Len() - 1)
-					switch span.State {
-					case StateBlockedSyscall:
-						// We didn't see an EvProcStart for this M
-						span.End = ev.Ts
-					case StateRunningP:
-						// We saw a EvProcStart for this M before we saw the EvGoSysExit. The blocking syscall span will be
-						// the second last span, and we'll have to slightly adjust its end time to not exceed the next
-						// span's start time.
-
-						// XXX guard against malformed traces
-						pspan := m.Spans.AtPtr(m.Spans.Len() - 2)
-						if pspan.State != StateBlockedSyscall {
-							return errors.New("malformed trace: EvGoSysExit was preceeded by more than one EvProcStart or other events")
-						}
-						pspan.End = span.Start
-					default:
-						panic(fmt.Sprintf("unexpected state %d", span.State))
-					}
-				}
-			}
-
-		case trace.EvProcStart:
-			if supportMachineTimelines {
-				mid := ev.Args[0]
-				m := getM(int32(mid))
-				m.Spans = append(m.Spans.(spansSlice), Span{Start: ev.Ts, End: -1, State: StateRunningP, Event: EventID(evID)})
-				lastMPerP[ev.P] = m.ID
-			}
-			continue
-		case trace.EvProcStop:
-			if supportMachineTimelines {
-				m := getM(lastMPerP[ev.P])
-				span := m.Spans.AtPtr(m.Spans.Len() - 1)
-				span.End = ev.Ts
-
-				if sevID, ok := blockingSyscallPerP[ev.P]; ok {
-					delete(blockingSyscallPerP, ev.P)
-					m.Spans = append(m.Spans.(spansSlice), Span{Start: ev.Ts, End: -1, State: StateBlockedSyscall, Event: sevID})
-				}
-			}
-
+		case trace.EvProcStart, trace.EvProcStop:
+			// Machine spans are computed separately, by populateMachines.
 			continue
 
 		case trace.EvGCMarkAssistStart:
@@ -682,6 +718,14 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 		case trace.EvGCSweepDone:
 			// The counterpart to EvGcSweepStart.
 
+			// Sweeping after a cycle has ended, until the next cycle starts, sweeps the spans of the most recent
+			// cycle. Sweeping before the first cycle in the trace belongs to a cycle we know nothing about.
+			if n := len(tr.GCCycles); n != 0 {
+				c := tr.GCCycles[n-1]
+				c.Swept += ev.Args[trace.ArgGCSweepDoneSwept]
+				c.Reclaimed += ev.Args[trace.ArgGCSweepDoneReclaimed]
+			}
+
 			if ev.G == 0 {
 				// See EvGCSweepStart for why.
 				continue
@@ -692,6 +736,8 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 
 		case trace.EvGCStart:
 			tr.GC = append(tr.GC.(spansSlice), Span{Start: ev.Ts, State: StateActive, Event: EventID(evID)})
+			// The remaining fields get populated by populateGCCycles, once all spans are final.
+			tr.GCCycles = append(tr.GCCycles, &GCCycle{Seq: ev.Args[trace.ArgGCStartSeq]})
 			continue
 
 		case trace.EvGCSTWStart:
@@ -719,18 +765,24 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 			continue
 
 		case trace.EvGomaxprocs:
-			// TODO(dh): graph GOMAXPROCS
+			// Already handled in the first pass
 			continue
 
 		case trace.EvUserTaskCreate:
-			t := &Task{
-				ID:    ev.Args[trace.ArgUserTaskCreateTaskID],
-				Name:  res.Strings[ev.Args[trace.ArgUserTaskCreateTypeID]],
-				Event: EventID(evID),
+			t := getTask(ev.Args[trace.ArgUserTaskCreateTaskID])
+			t.Name = res.Strings[ev.Args[trace.ArgUserTaskCreateTypeID]]
+			t.Event = EventID(evID)
+			t.Start = ev.Ts
+			if parentID := ev.Args[trace.ArgUserTaskCreateParentID]; parentID != 0 {
+				// The parent may have been created before tracing began, in which case getTask creates a stub for it.
+				t.Parent = getTask(parentID)
+				t.Parent.Children = append(t.Parent.Children, t)
 			}
-			tr.Tasks = append(tr.Tasks, t)
 			continue
 		case trace.EvUserTaskEnd:
+			t := getTask(ev.Args[trace.ArgUserTaskEndTaskID])
+			t.EndEvent = EventID(evID)
+			t.End = ev.Ts
 			continue
 
 		case trace.EvUserRegion:
@@ -767,15 +819,7 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 				userRegionDepths[gid]++
 
 				if taskID := ev.Args[trace.ArgUserRegionTaskID]; taskID != 0 {
-					idx, ok := tr.task(taskID)
-					if !ok {
-						// The task with the given ID doesn't exist. This can happen in well-formed traces when the task
-						// was created before tracing began.
-						task := &Task{
-							ID: taskID,
-						}
-						tr.Tasks = slices.Insert(tr.Tasks, idx, task)
-					}
+					getTask(taskID)
 				}
 			} else {
 				d := userRegionDepths[gid] - 1
@@ -788,7 +832,11 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 			continue
 
 		case trace.EvUserLog:
-			// TODO(dh): incorporate logs in per-goroutine timeline
+			if taskID := ev.Args[trace.ArgUserLogTaskID]; taskID != 0 {
+				// Make sure that logs referring to tasks created before the start of the trace can be resolved.
+				getTask(taskID)
+			}
+			tr.UserLogs = append(tr.UserLogs, EventID(evID))
 			addEventToCurrentSpan(ev.G, EventID(evID))
 			continue
 
@@ -808,7 +856,7 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 					// tracing if they're in a blocked state. This causes a transition from inactive to blocked, which we
 					// wouldn't normally permit.
 				} else {
-					prevState := s.At(s.Len() - 1).State
+					prevState := tr.BaseState(s.At(s.Len() - 1).State)
 					if !legalStateTransitions[prevState][state] {
 						panic(fmt.Sprintf("illegal state transition %d -> %d for goroutine %d, time %d", prevState, state, gid, ev.Ts))
 					}
@@ -842,23 +890,10 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 		case pRunG:
 			p := getP(ev.P)
 			p.Spans = append(p.Spans.(spansSlice), Span{Start: ev.Ts, State: StateRunningG, Event: EventID(evID)})
-			if supportMachineTimelines {
-				mid := lastMPerP[p.ID]
-				m := getM(mid)
-				m.Goroutines = append(m.Goroutines.(spansSlice), Span{Start: ev.Ts, Event: EventID(evID), State: StateRunningG})
-			}
 		case pStopG:
 			// XXX guard against malformed traces
 			p := getP(ev.P)
 			p.Spans.AtPtr(p.Spans.Len() - 1).End = ev.Ts
-			if supportMachineTimelines {
-				mid := lastMPerP[p.ID]
-				m := getM(mid)
-				if m.Goroutines.Len() == 0 {
-					return fmt.Errorf("malformed trace: g%d ran on m%d but M has no goroutine spans", ev.G, mid)
-				}
-				m.Goroutines.AtPtr(m.Goroutines.Len() - 1).End = ev.Ts
-			}
 		}
 	}
 
@@ -875,7 +910,7 @@ func postProcessSpans(tr *Trace, progress func(float64)) {
 			}
 
 			stack := tr.Stacks[tr.Events[s.Event].StkID]
-			s = applyPatterns(s, tr.PCs, stack)
+			s = applyPatterns(s, tr.UserPatterns, tr.PCs, stack)
 
 			// move s.At out of the runtime
 			for int(s.At+1) < len(stack) && s.At < 255 && strings.HasPrefix(tr.PCs[stack[s.At]].Fn, "runtime.") {
@@ -976,6 +1011,330 @@ evLoop:
 	}
 }
 
+// populateCreationTree links goroutines to the goroutines that created them.
+func populateCreationTree(tr *Trace) {
+	// The EvGoCreate events emitted when tracing starts describe goroutines that already existed. Like in
+	// removeBogusCreatedSpans, we skip them, as they're emitted by the goroutine that started tracing, which isn't the
+	// actual parent.
+	initial := true
+	for i := range tr.Events {
+		ev := &tr.Events[i]
+		switch ev.Type {
+		case trace.EvProcStart, trace.EvHeapAlloc, trace.EvGomaxprocs:
+			initial = false
+		case trace.EvGoCreate:
+			if initial || ev.G == 0 {
+				continue
+			}
+			parent, ok := tr.gsByID[ev.G]
+			if !ok {
+				continue
+			}
+			child, ok := tr.gsByID[ev.Args[trace.ArgGoCreateG]]
+			if !ok || child.Spans.Len() == 0 {
+				continue
+			}
+			child.Parent = parent
+			parent.Children = append(parent.Children, child)
+		}
+	}
+}
+
+func populateTasks(tr *Trace) {
+	if len(tr.Tasks) == 0 {
+		return
+	}
+
+	// OPT(dh): we could collect regions while processing events, but their end times are only final after
+	// postProcessSpans.
+	regions := map[*Task][]Span{}
+	goroutines := map[*Task]map[*Goroutine]struct{}{}
+	addG := func(t *Task, g *Goroutine) {
+		m := goroutines[t]
+		if m == nil {
+			m = map[*Goroutine]struct{}{}
+			goroutines[t] = m
+		}
+		m[g] = struct{}{}
+	}
+	for _, g := range tr.Goroutines {
+		for _, spans := range g.UserRegions {
+			for i := 0; i < spans.Len(); i++ {
+				s := spans.AtPtr(i)
+				taskID := tr.Event(s.Event).Args[trace.ArgUserRegionTaskID]
+				if taskID == 0 {
+					continue
+				}
+				t := tr.Task(taskID)
+				regions[t] = append(regions[t], *s)
+				addG(t, g)
+			}
+		}
+		for _, evID := range g.Events {
+			ev := tr.Event(evID)
+			if ev.Type != trace.EvUserLog {
+				continue
+			}
+			if taskID := ev.Args[trace.ArgUserLogTaskID]; taskID != 0 {
+				if idx, ok := tr.task(taskID); ok {
+					addG(tr.Tasks[idx], g)
+				}
+			}
+		}
+	}
+
+	end := tr.Events[len(tr.Events)-1].Ts
+	for _, t := range tr.Tasks {
+		if t.End == -1 {
+			t.End = end
+		}
+		for _, evID := range [...]EventID{t.Event, t.EndEvent} {
+			if evID == 0 {
+				continue
+			}
+			if g, ok := tr.gsByID[tr.Event(evID).G]; ok && g.Spans.Len() != 0 {
+				addG(t, g)
+			}
+		}
+
+		rs := regions[t]
+		slices.SortFunc(rs, func(a, b Span) bool { return a.Start < b.Start })
+		t.Regions = spansSlice(rs)
+
+		gs := make([]*Goroutine, 0, len(goroutines[t]))
+		for g := range goroutines[t] {
+			gs = append(gs, g)
+		}
+		slices.SortFunc(gs, func(a, b *Goroutine) bool { return a.ID < b.ID })
+		t.Goroutines = gs
+	}
+}
+
+func populateGCCycles(tr *Trace) {
+	if len(tr.GCCycles) == 0 {
+		return
+	}
+
+	end := tr.Events[len(tr.Events)-1].Ts
+	for i, c := range tr.GCCycles {
+		c.Span = tr.GC.At(i)
+		if c.Span.End == 0 {
+			c.Span.End = end
+		}
+		c.MarkAssist = map[*Goroutine]time.Duration{}
+	}
+
+	// cycleAt returns the cycle that was running at time ts, or the most recent one if no cycle was running. It
+	// returns nil if ts is before the first cycle.
+	cycleAt := func(ts trace.Timestamp) *GCCycle {
+		idx := sort.Search(len(tr.GCCycles), func(i int) bool {
+			return tr.GCCycles[i].Span.Start > ts
+		})
+		if idx == 0 {
+			return nil
+		}
+		return tr.GCCycles[idx-1]
+	}
+
+	for i := 0; i < tr.STW.Len(); i++ {
+		s := tr.STW.At(i)
+		if s.End == 0 {
+			s.End = end
+		}
+		// Sweep termination happens after the cycle's EvGCStart, and mark termination happens before its EvGCDone, so
+		// every STW phase starts during the cycle it belongs to.
+		if c := cycleAt(s.Start); c != nil {
+			kind := STWKind(tr.Event(s.Event).Args[trace.ArgGCSTWStartKind])
+			c.STW = append(c.STW, STWPhase{Span: s, Kind: kind})
+		}
+	}
+
+	for _, g := range tr.Goroutines {
+		for i := 0; i < g.Spans.Len(); i++ {
+			s := g.Spans.AtPtr(i)
+			if tr.BaseState(s.State) != StateGCMarkAssist {
+				continue
+			}
+			if c := cycleAt(s.Start); c != nil {
+				d := s.Duration()
+				c.MarkAssist[g] += d
+				c.MarkAssistTotal += d
+			}
+		}
+	}
+
+	// valueAt returns the last measurement at or before ts.
+	valueAt := func(points []Point, ts trace.Timestamp) uint64 {
+		idx := sort.Search(len(points), func(i int) bool {
+			return points[i].When > ts
+		})
+		if idx == 0 {
+			return 0
+		}
+		return points[idx-1].Value
+	}
+	for _, c := range tr.GCCycles {
+		c.HeapSizeBefore = valueAt(tr.HeapSize, c.Span.Start)
+		c.HeapSizeAfter = valueAt(tr.HeapSize, c.Span.End)
+		c.HeapGoalBefore = valueAt(tr.HeapGoal, c.Span.Start)
+		c.HeapGoalAfter = valueAt(tr.HeapGoal, c.Span.End)
+	}
+}
+
+// populateMachines computes the spans of machines.
+//
+// The order of events in the trace is only consistent for goroutines and processors, not for machines. In the global
+// order, an M may start a P before it stopped its previous P, or before it returned from a blocking syscall. Instead
+// of processing events in the global order, we attribute events to Ms, using the thread IDs of EvProcStart and the
+// fact that each P's events are in order, and then order each M's events by timestamp. Timestamps are monotonic for a
+// single thread, which makes the per-M order consistent.
+func populateMachines(tr *Trace) {
+	const (
+		mProcStart = iota
+		mProcStop
+		mGoStart
+		mGoStop
+		mSyscallBlock
+		mSyscallExit
+	)
+	type machineEvent struct {
+		ts   trace.Timestamp
+		kind uint8
+		ev   EventID
+	}
+
+	eventsPerM := map[int32][]machineEvent{}
+	// map from P to the M currently running it
+	mPerP := map[int32]int32{}
+	// map from M to the P it is currently running
+	pPerM := map[int32]int32{}
+	// map from G to the M that is blocked in a syscall on its behalf
+	syscallMPerG := map[uint64]int32{}
+	// map from G to its most recent EvGoSysCall event
+	syscallPerG := map[uint64]EventID{}
+
+	add := func(mid int32, kind uint8, evID int) {
+		eventsPerM[mid] = append(eventsPerM[mid], machineEvent{tr.Events[evID].Ts, kind, EventID(evID)})
+	}
+	for evID := range tr.Events {
+		ev := &tr.Events[evID]
+		switch ev.Type {
+		case trace.EvProcStart:
+			mid := int32(ev.Args[trace.ArgProcStartThread])
+			// The M might start a new P before we see its old P stop. The old P's EvProcStop must not end the new
+			// P's span.
+			if pid, ok := pPerM[mid]; ok && mPerP[pid] == mid {
+				delete(mPerP, pid)
+			}
+			mPerP[ev.P] = mid
+			pPerM[mid] = ev.P
+			add(mid, mProcStart, evID)
+		case trace.EvProcStop:
+			if mid, ok := mPerP[ev.P]; ok {
+				delete(mPerP, ev.P)
+				delete(pPerM, mid)
+				add(mid, mProcStop, evID)
+			}
+		case trace.EvGoStart, trace.EvGoStartLabel:
+			if mid, ok := mPerP[ev.P]; ok {
+				add(mid, mGoStart, evID)
+			}
+		case trace.EvGoStop, trace.EvGoEnd, trace.EvGoSched, trace.EvGoSleep, trace.EvGoPreempt,
+			trace.EvGoBlockSend, trace.EvGoBlockRecv, trace.EvGoBlockSelect, trace.EvGoBlockSync,
+			trace.EvGoBlockCond, trace.EvGoBlockNet, trace.EvGoBlockGC, trace.EvGoBlock:
+			if mid, ok := mPerP[ev.P]; ok {
+				add(mid, mGoStop, evID)
+			}
+		case trace.EvGoSysCall:
+			syscallPerG[ev.G] = EventID(evID)
+		case trace.EvGoSysBlock:
+			// EvGoSysBlock is followed by EvProcStop for the same P, which will start the M's syscall span.
+			if mid, ok := mPerP[ev.P]; ok {
+				add(mid, mGoStop, evID)
+				// EvGoSysBlock doesn't have a stack, but the EvGoSysCall that entered the syscall does, so we use that
+				// as the span's event.
+				sysEv := EventID(evID)
+				if id, ok := syscallPerG[ev.G]; ok {
+					sysEv = id
+				}
+				eventsPerM[mid] = append(eventsPerM[mid], machineEvent{ev.Ts, mSyscallBlock, sysEv})
+				syscallMPerG[ev.G] = mid
+			}
+			delete(syscallPerG, ev.G)
+		case trace.EvGoSysExit:
+			// EvGoSysExit happens on the fake SyscallP, but the goroutine returns from the syscall on the M that was
+			// blocked in it.
+			if mid, ok := syscallMPerG[ev.G]; ok {
+				delete(syscallMPerG, ev.G)
+				add(mid, mSyscallExit, evID)
+			}
+		}
+	}
+
+	end := tr.Events[len(tr.Events)-1].Ts
+	for mid, evs := range eventsPerM {
+		// The events were collected in the global order, and the sort is stable, so events with identical timestamps
+		// retain that order.
+		sort.SliceStable(evs, func(i, j int) bool { return evs[i].ts < evs[j].ts })
+
+		m := &Machine{ID: mid}
+		tr.msByID[mid] = m
+		var spans, goroutines []Span
+		// The event of the EvGoSysBlock that has yet to be followed by EvProcStop, or -1
+		pendingSyscall := EventID(-1)
+		openSpan := func() *Span {
+			if n := len(spans); n != 0 && spans[n-1].End == -1 {
+				return &spans[n-1]
+			}
+			return nil
+		}
+		closeGoroutine := func(ts trace.Timestamp) {
+			if n := len(goroutines); n != 0 && goroutines[n-1].End == -1 {
+				goroutines[n-1].End = ts
+			}
+		}
+		for _, mev := range evs {
+			switch mev.kind {
+			case mProcStart:
+				// Starting a P ends a blocking syscall, or a previous P that we didn't see stop.
+				if s := openSpan(); s != nil {
+					s.End = mev.ts
+				}
+				closeGoroutine(mev.ts)
+				pendingSyscall = -1
+				spans = append(spans, Span{Start: mev.ts, End: -1, State: StateRunningP, Event: mev.ev})
+			case mProcStop:
+				if s := openSpan(); s != nil && s.State == StateRunningP {
+					s.End = mev.ts
+				}
+				closeGoroutine(mev.ts)
+				if pendingSyscall != -1 {
+					spans = append(spans, Span{Start: mev.ts, End: -1, State: StateBlockedSyscall, Event: pendingSyscall})
+					pendingSyscall = -1
+				}
+			case mGoStart:
+				closeGoroutine(mev.ts)
+				goroutines = append(goroutines, Span{Start: mev.ts, End: -1, State: StateRunningG, Event: mev.ev})
+			case mGoStop:
+				closeGoroutine(mev.ts)
+			case mSyscallBlock:
+				pendingSyscall = mev.ev
+			case mSyscallExit:
+				if s := openSpan(); s != nil && s.State == StateBlockedSyscall {
+					s.End = mev.ts
+				}
+			}
+		}
+		if s := openSpan(); s != nil {
+			s.End = end
+		}
+		closeGoroutine(end)
+
+		m.Spans = spansSlice(spans)
+		m.Goroutines = spansSlice(goroutines)
+	}
+}
+
 func populateObjects(tr *Trace, progress func(float64)) {
 	// Note: There is no point populating gs and ps in parallel, because ps only contains a handful of items.
 	tr.Goroutines = make([]*Goroutine, 0, len(tr.gsByID))
@@ -995,11 +1354,6 @@ func populateObjects(tr *Trace, progress func(float64)) {
 	for _, m := range tr.msByID {
 		// OPT(dh): preallocate ms
 		tr.Machines = append(tr.Machines, m)
-		if m.Spans.Len() > 0 {
-			if last := m.Spans.AtPtr(m.Spans.Len() - 1); last.End == -1 {
-				last.End = tr.Events[len(tr.Events)-1].Ts
-			}
-		}
 	}
 	progress(3.0 / 5.0)
 
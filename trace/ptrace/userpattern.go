@@ -0,0 +1,199 @@
+package ptrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// The maximum number of distinct user-defined states and tags.
+const (
+	MaxUserStates = 16
+	MaxUserTags   = 8
+)
+
+// stateIdentifiers maps the names used in pattern files to goroutine states.
+var stateIdentifiers = map[string]SchedulingState{
+	"inactive":                   StateInactive,
+	"active":                     StateActive,
+	"blocked":                    StateBlocked,
+	"blocked-send":               StateBlockedSend,
+	"blocked-recv":               StateBlockedRecv,
+	"blocked-select":             StateBlockedSelect,
+	"blocked-sync":               StateBlockedSync,
+	"blocked-sync-once":          StateBlockedSyncOnce,
+	"blocked-sync-triggering-gc": StateBlockedSyncTriggeringGC,
+	"blocked-cond":               StateBlockedCond,
+	"blocked-net":                StateBlockedNet,
+	"blocked-gc":                 StateBlockedGC,
+	"blocked-syscall":            StateBlockedSyscall,
+	"stuck":                      StateStuck,
+	"ready":                      StateReady,
+	"gc-mark-assist":             StateGCMarkAssist,
+	"gc-sweep":                   StateGCSweep,
+}
+
+// UserPatterns are stack patterns that are loaded at runtime, to name library-specific blocking points. They are
+// applied after the built-in patterns.
+//
+// A pattern file is a JSON document of the following form:
+//
+//	{
+//		"rules": [
+//			{
+//				"state": "blocked-sync",
+//				"top": ["sync.(*Mutex).Lock"],
+//				"contains": ["database/sql.(*DB).conn"],
+//				"at": 1,
+//				"newState": "waiting for DB connection",
+//				"tags": ["DB"]
+//			}
+//		]
+//	}
+//
+// A rule matches spans in the given state. "top" lists functions that have to appear at the top of the stack, in
+// order, with empty strings matching any function. "contains" lists functions that have to appear anywhere in the
+// stack. "at" optionally sets the offset of the frame that caused the state. "newState" names a user-defined state
+// that replaces the span's state, and "tags" adds user-defined tags to the span. Rules that use the same new state or
+// tag name share the state or tag.
+type UserPatterns struct {
+	// Names of the user-defined states, indexed by state - StateUser0
+	StateNames []string
+	// The built-in states that the user-defined states refine
+	BaseStates []SchedulingState
+	// Names of user-defined tags, indexed by the tag's bit position - the position of SpanTagUser0
+	TagNames []string
+
+	patterns [256][]pattern
+}
+
+type userPatternFile struct {
+	Rules []struct {
+		State    string   `json:"state"`
+		Top      []string `json:"top"`
+		Contains []string `json:"contains"`
+		At       uint8    `json:"at"`
+		NewState string   `json:"newState"`
+		Tags     []string `json:"tags"`
+	} `json:"rules"`
+}
+
+// ParseUserPatterns parses a pattern file, as documented on UserPatterns.
+func ParseUserPatterns(r io.Reader) (*UserPatterns, error) {
+	var f userPatternFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+
+	up := &UserPatterns{}
+	for i, rule := range f.Rules {
+		state, ok := stateIdentifiers[rule.State]
+		if !ok {
+			return nil, fmt.Errorf("rule %d: unknown state %q", i+1, rule.State)
+		}
+		if len(rule.Top) == 0 && len(rule.Contains) == 0 {
+			return nil, fmt.Errorf("rule %d: rule needs at least one of \"top\" and \"contains\"", i+1)
+		}
+		if rule.NewState == "" && len(rule.Tags) == 0 && rule.At == 0 {
+			return nil, fmt.Errorf("rule %d: rule has no effect", i+1)
+		}
+
+		p := pattern{
+			state: state,
+			fns:   rule.Top,
+			at:    rule.At,
+		}
+		for _, fn := range rule.Contains {
+			p.relFns = append(p.relFns, []string{fn})
+		}
+
+		if rule.NewState != "" {
+			idx := -1
+			for j, name := range up.StateNames {
+				if name == rule.NewState {
+					idx = j
+					break
+				}
+			}
+			if idx == -1 {
+				if len(up.StateNames) == MaxUserStates {
+					return nil, fmt.Errorf("rule %d: too many user-defined states, the maximum is %d", i+1, MaxUserStates)
+				}
+				idx = len(up.StateNames)
+				up.StateNames = append(up.StateNames, rule.NewState)
+				up.BaseStates = append(up.BaseStates, state)
+			} else if up.BaseStates[idx] != state {
+				return nil, fmt.Errorf("rule %d: state %q is already used for spans in state %q", i+1, rule.NewState, stateIdentifier(up.BaseStates[idx]))
+			}
+			p.newState = StateUser0 + SchedulingState(idx)
+		}
+
+		for _, tag := range rule.Tags {
+			if tag == "" {
+				return nil, fmt.Errorf("rule %d: tag names must not be empty", i+1)
+			}
+			idx := -1
+			for j, name := range up.TagNames {
+				if name == tag {
+					idx = j
+					break
+				}
+			}
+			if idx == -1 {
+				if len(up.TagNames) == MaxUserTags {
+					return nil, fmt.Errorf("rule %d: too many user-defined tags, the maximum is %d", i+1, MaxUserTags)
+				}
+				idx = len(up.TagNames)
+				up.TagNames = append(up.TagNames, tag)
+			}
+			p.tags |= SpanTagUser0 << idx
+		}
+
+		up.patterns[state] = append(up.patterns[state], p)
+	}
+
+	return up, nil
+}
+
+// LookupState returns the state with the given identifier, as used in pattern files, or the user-defined state with
+// the given name.
+func (tr *Trace) LookupState(name string) (SchedulingState, bool) {
+	if state, ok := stateIdentifiers[name]; ok {
+		return state, true
+	}
+	if tr.UserPatterns != nil {
+		for i, s := range tr.UserPatterns.StateNames {
+			if s == name {
+				return StateUser0 + SchedulingState(i), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func stateIdentifier(state SchedulingState) string {
+	for name, s := range stateIdentifiers {
+		if s == state {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", state)
+}
+
+// IsUserState reports whether state is a user-defined state.
+func IsUserState(state SchedulingState) bool {
+	return state >= StateUser0 && state < StateUser0+MaxUserStates
+}
+
+// BaseState returns the built-in state that a user-defined state refines, according to the patterns that were applied
+// to the trace. For built-in states, it returns the state itself.
+func (tr *Trace) BaseState(state SchedulingState) SchedulingState {
+	if IsUserState(state) && tr.UserPatterns != nil {
+		if i := int(state - StateUser0); i < len(tr.UserPatterns.BaseStates) {
+			return tr.UserPatterns.BaseStates[i]
+		}
+	}
+	return state
+}
@@ -0,0 +1,132 @@
+package ptrace
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestParseUserPatterns(t *testing.T) {
+	// manyStates returns a pattern file with n rules, each defining its own state.
+	manyStates := func(n int) string {
+		var rules []string
+		for i := 0; i < n; i++ {
+			rules = append(rules, fmt.Sprintf(`{"state": "blocked-sync", "top": ["f%d"], "newState": "state %d"}`, i, i))
+		}
+		return `{"rules": [` + strings.Join(rules, ",") + `]}`
+	}
+	manyTags := func(n int) string {
+		var tags []string
+		for i := 0; i < n; i++ {
+			tags = append(tags, fmt.Sprintf(`"tag %d"`, i))
+		}
+		return `{"rules": [{"state": "blocked-net", "top": ["f"], "tags": [` + strings.Join(tags, ",") + `]}]}`
+	}
+
+	for _, test := range []struct {
+		name string
+		in   string
+		// A substring of the expected error, or the empty string if parsing should succeed
+		err        string
+		stateNames []string
+		baseStates []SchedulingState
+		tagNames   []string
+	}{
+		{
+			name: "valid",
+			in: `{"rules": [
+				{"state": "blocked-sync", "top": ["sync.(*Mutex).Lock"], "contains": ["database/sql.(*DB).conn"], "at": 1, "newState": "waiting for DB", "tags": ["DB"]},
+				{"state": "blocked-sync", "contains": ["database/sql.(*Tx).Commit"], "newState": "waiting for DB"},
+				{"state": "blocked-net", "top": ["net.(*conn).Read"], "tags": ["DB", "network"]}
+			]}`,
+			stateNames: []string{"waiting for DB"},
+			baseStates: []SchedulingState{StateBlockedSync},
+			tagNames:   []string{"DB", "network"},
+		},
+		{name: "empty", in: `{"rules": []}`},
+		{name: "malformed JSON", in: `{"rules": [`, err: "unexpected EOF"},
+		{name: "unknown field", in: `{"rules": [{"state": "blocked", "top": ["f"], "tag": ["x"]}]}`, err: `unknown field "tag"`},
+		{name: "unknown base state", in: `{"rules": [{"state": "sleeping", "top": ["f"], "newState": "x"}]}`, err: `rule 1: unknown state "sleeping"`},
+		{name: "missing base state", in: `{"rules": [{"top": ["f"], "newState": "x"}]}`, err: `rule 1: unknown state ""`},
+		{name: "no stack", in: `{"rules": [{"state": "blocked", "newState": "x"}]}`, err: `rule 1: rule needs at least one of "top" and "contains"`},
+		{name: "no effect", in: `{"rules": [{"state": "blocked", "top": ["f"]}]}`, err: "rule 1: rule has no effect"},
+		{
+			name: "conflicting base states",
+			in: `{"rules": [
+				{"state": "blocked-sync", "top": ["f"], "newState": "x"},
+				{"state": "blocked-net", "top": ["g"], "newState": "x"}
+			]}`,
+			err: `rule 2: state "x" is already used for spans in state "blocked-sync"`,
+		},
+		{name: "maximum states", in: manyStates(MaxUserStates)},
+		{name: "too many states", in: manyStates(MaxUserStates + 1), err: fmt.Sprintf("rule %d: too many user-defined states", MaxUserStates+1)},
+		{name: "maximum tags", in: manyTags(MaxUserTags)},
+		{name: "too many tags", in: manyTags(MaxUserTags + 1), err: "rule 1: too many user-defined tags"},
+		{name: "empty tag name", in: `{"rules": [{"state": "blocked", "top": ["f"], "tags": [""]}]}`, err: "rule 1: tag names must not be empty"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			up, err := ParseUserPatterns(strings.NewReader(test.in))
+			if test.err != "" {
+				if err == nil {
+					t.Fatalf("got no error, want one containing %q", test.err)
+				}
+				if !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %q, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if test.stateNames != nil && !slices.Equal(up.StateNames, test.stateNames) {
+				t.Errorf("got state names %q, want %q", up.StateNames, test.stateNames)
+			}
+			if test.baseStates != nil && !slices.Equal(up.BaseStates, test.baseStates) {
+				t.Errorf("got base states %v, want %v", up.BaseStates, test.baseStates)
+			}
+			if test.tagNames != nil && !slices.Equal(up.TagNames, test.tagNames) {
+				t.Errorf("got tag names %q, want %q", up.TagNames, test.tagNames)
+			}
+		})
+	}
+}
+
+func TestUserStates(t *testing.T) {
+	up, err := ParseUserPatterns(strings.NewReader(`{"rules": [
+		{"state": "blocked-sync", "top": ["f"], "newState": "waiting for DB"},
+		{"state": "blocked-net", "top": ["g"], "newState": "waiting for RPC"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &Trace{UserPatterns: up}
+	other := &Trace{}
+	for _, test := range []struct {
+		tr    *Trace
+		state SchedulingState
+		want  SchedulingState
+	}{
+		{tr, StateUser0, StateBlockedSync},
+		{tr, StateUser0 + 1, StateBlockedNet},
+		{tr, StateBlockedSend, StateBlockedSend},
+		// Traces parsed without patterns don't interpret user states.
+		{other, StateUser0, StateUser0},
+	} {
+		if got := test.tr.BaseState(test.state); got != test.want {
+			t.Errorf("BaseState(%d) = %d, want %d", test.state, got, test.want)
+		}
+	}
+
+	if state, ok := tr.LookupState("waiting for RPC"); !ok || state != StateUser0+1 {
+		t.Errorf("LookupState(%q) = %d, %t, want %d, true", "waiting for RPC", state, ok, StateUser0+1)
+	}
+	if state, ok := tr.LookupState("blocked-net"); !ok || state != StateBlockedNet {
+		t.Errorf("LookupState(%q) = %d, %t, want %d, true", "blocked-net", state, ok, StateBlockedNet)
+	}
+	if _, ok := other.LookupState("waiting for RPC"); ok {
+		t.Errorf("LookupState found a user state in a trace without patterns")
+	}
+}
@@ -53,7 +53,7 @@ func (b *Bool) Layout(gtx layout.Context, w layout.Widget) layout.Dimensions {
 	return dims
 }
 
-type BackedBit[T uint8 | uint16 | uint32 | uint64] struct {
+type BackedBit[T ~uint8 | ~uint16 | ~uint32 | ~uint64] struct {
 	Bits *T
 	Bit  int
 